@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/bot"
+	"github.com/mr-panta/go-logger"
+)
+
+func main() {
+	ctx := logger.GetContextWithLogID(context.Background(), "bot_main")
+	hostIP := flag.String("host", "127.0.0.1", "server ip")
+	count := flag.Int("n", 4, "number of bots")
+	namePrefix := flag.String("name", "bot", "prefix of bot names")
+	roomName := flag.String("room", "", "room to join, empty joins the default room")
+	joinPeriod := flag.Duration("join-period", 200*time.Millisecond, "wait between bots joining")
+	flag.Parse()
+	if *count <= 0 {
+		logger.Fatalf(ctx, "number of bots must be positive")
+	}
+	bots := make([]bot.Bot, 0, *count)
+	for i := 1; i <= *count; i++ {
+		b := bot.NewBot(*hostIP, *roomName, fmt.Sprintf("%s-%d", *namePrefix, i))
+		b.Start()
+		bots = append(bots, b)
+		time.Sleep(*joinPeriod)
+	}
+	logger.Infof(ctx, "started %d bots|host:%s", *count, *hostIP)
+	// Wait until interrupted
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	for _, b := range bots {
+		b.Close()
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
@@ -10,6 +11,8 @@ import (
 	"github.com/mr-panta/go-logger"
 )
 
+var replayFile = flag.String("replay", "", "play a replay file instead of joining a server")
+
 func run() {
 	ctx := logger.GetContextWithLogID(context.Background(), "client_main")
 	logPrinter, err := util.NewLogPrinter(config.LogFile)
@@ -21,9 +24,15 @@ func run() {
 	if err != nil {
 		logger.Fatalf(ctx, err.Error())
 	}
+	if *replayFile != "" {
+		if err := p.StartReplay(*replayFile); err != nil {
+			logger.Fatalf(ctx, err.Error())
+		}
+	}
 	p.Run()
 }
 
 func main() {
+	flag.Parse()
 	pixelgl.Run(run)
 }
@@ -2,19 +2,36 @@ package main
 
 import (
 	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/mr-panta/go-logger"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/processor/server"
+	"github.com/mr-panta/go-logger"
 )
 
 func main() {
 	ctx := logger.GetContextWithLogID(context.Background(), "server_main")
-	p, err := server.NewServerProcessor()
+	cfg := config.NewServerConfig()
+	if err := cfg.ParseFlags(flag.CommandLine, os.Args[1:]); err != nil {
+		logger.Fatalf(ctx, err.Error())
+	}
+	if err := cfg.Validate(); err != nil {
+		logger.Fatalf(ctx, err.Error())
+	}
+	p, err := server.NewServerProcessor(cfg)
 	if err != nil {
 		logger.Fatalf(ctx, err.Error())
 	}
-	go p.CleanWorld()
-	go p.UpdateWorld()
-	go p.BroadcastSnapshot()
+	p.Start()
+	// Clients are told about the shutdown instead of timing out
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		p.Shutdown()
+	}()
 	p.Wait()
 }
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+)
+
+const (
+	// same as the field size of the default world
+	botFieldSize       = 64
+	botAimRange        = 500
+	botMeleeRange      = 80
+	botItemRange       = 400
+	botKeepRange       = 200
+	botAimError        = 20
+	botArriveDist      = 32
+	botStuckDist       = 4
+	botStuckTime       = 500 * time.Millisecond
+	botMaxWanderTime   = 8 * time.Second
+	botDirThreshold    = 8
+	botFireProbability = 0.8
+)
+
+// botAI wanders around the field, walks to items it sees and shoots at
+// enemies which are visible to it.
+type botAI struct {
+	target     pixel.Vec
+	targetTime time.Time
+	lastPos    pixel.Vec
+	moveTime   time.Time
+	rng        *rand.Rand
+}
+
+func newBotAI() *botAI {
+	return &botAI{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (a *botAI) getInput(playerID string, snapshot *protocol.WorldSnapshot, now time.Time) *protocol.InputSnapshot {
+	input := &protocol.InputSnapshot{
+		CursorDir: util.V(1, 0),
+		ViewTime:  now.Add(-config.LerpPeriod).UnixNano(),
+		Time:      now.UnixNano(),
+	}
+	objectMap := make(map[string]*protocol.ObjectSnapshot)
+	for _, ss := range snapshot.ObjectSnapshots {
+		objectMap[ss.ID] = ss
+	}
+	me, exists := objectMap[playerID]
+	if !exists || me.Player == nil || !isAlive(me.Player, now) {
+		a.targetTime = time.Time{}
+		return input
+	}
+	pos := me.Player.Pos.Convert()
+	// Find enemy and item
+	var enemy *protocol.PlayerSnapshot
+	enemyDist := math.Inf(1)
+	itemPos := pixel.ZV
+	itemDist := math.Inf(1)
+	for _, ss := range snapshot.ObjectSnapshots {
+		switch {
+		case ss.Type == config.PlayerObject && ss.ID != playerID && ss.Player != nil:
+			if ss.Player.IsHidden || ss.Player.IsInvulnerable || !isAlive(ss.Player, now) {
+				continue
+			}
+			if dist := ss.Player.Pos.Convert().Sub(pos).Len(); dist < botAimRange && dist < enemyDist {
+				enemy = ss.Player
+				enemyDist = dist
+			}
+		case ss.Type == config.ItemObject && ss.Item != nil:
+			p, ok := getItemPos(ss.Item)
+			if !ok {
+				continue
+			}
+			if dist := p.Sub(pos).Len(); dist < botItemRange && dist < itemDist {
+				itemPos = p
+				itemDist = dist
+			}
+		}
+	}
+	// Decide where to go
+	var moveTarget pixel.Vec
+	switch {
+	case enemy != nil:
+		enemyPos := enemy.Pos.Convert()
+		aimError := pixel.V(a.rng.Float64()-0.5, a.rng.Float64()-0.5).Scaled(2 * botAimError)
+		input.CursorDir = util.ConvertVec(enemyPos.Sub(pos).Add(aimError))
+		moveTarget = enemyPos
+		if me.Player.WeaponID == "" {
+			input.Melee = enemyDist < botMeleeRange
+		} else {
+			if enemyDist < botKeepRange {
+				// Back off and keep shooting
+				moveTarget = pos.Sub(enemyPos.Sub(pos))
+			}
+			mag, ammo := getWeaponAmmo(objectMap[me.Player.WeaponID])
+			input.Reload = mag == 0 && ammo > 0
+			input.Fire = mag > 0 && a.rng.Float64() < botFireProbability
+		}
+	case itemDist < botItemRange:
+		moveTarget = itemPos
+	default:
+		moveTarget = a.getWanderTarget(snapshot, pos, now)
+	}
+	a.setMoveKeys(input, moveTarget.Sub(pos))
+	// Look where it's going
+	if dir := moveTarget.Sub(pos); enemy == nil && dir.Len() > 0 {
+		input.CursorDir = util.ConvertVec(dir)
+	}
+	return input
+}
+
+// getWanderTarget picks a random point and changes it once the bot arrives,
+// gets stuck or walks for too long.
+func (a *botAI) getWanderTarget(snapshot *protocol.WorldSnapshot, pos pixel.Vec, now time.Time) pixel.Vec {
+	isStuck := false
+	if pos.Sub(a.lastPos).Len() > botStuckDist {
+		a.lastPos = pos
+		a.moveTime = now
+	} else if now.Sub(a.moveTime) > botStuckTime {
+		isStuck = true
+	}
+	if a.targetTime.IsZero() || isStuck ||
+		a.target.Sub(pos).Len() < botArriveDist ||
+		now.Sub(a.targetTime) > botMaxWanderTime {
+		rect := pixel.R(
+			0,
+			0,
+			float64(snapshot.FieldWidth*botFieldSize),
+			float64(snapshot.FieldHeight*botFieldSize),
+		)
+		a.target = util.RandomVec(a.rng, rect)
+		a.targetTime = now
+		a.moveTime = now
+	}
+	return a.target
+}
+
+func (a *botAI) setMoveKeys(input *protocol.InputSnapshot, dir pixel.Vec) {
+	if dir.Y > botDirThreshold {
+		input.Up = true
+	} else if dir.Y < -botDirThreshold {
+		input.Down = true
+	}
+	if dir.X > botDirThreshold {
+		input.Right = true
+	} else if dir.X < -botDirThreshold {
+		input.Left = true
+	}
+}
+
+func isAlive(ss *protocol.PlayerSnapshot, now time.Time) bool {
+	return now.UnixNano() > ss.RespawnTime
+}
+
+func getItemPos(ss *protocol.ItemSnapshot) (pixel.Vec, bool) {
+	switch {
+	case ss.Weapon != nil:
+		return ss.Weapon.Pos.Convert(), true
+	case ss.Ammo != nil:
+		return ss.Ammo.Pos.Convert(), true
+	case ss.AmmoSM != nil:
+		return ss.AmmoSM.Pos.Convert(), true
+	case ss.Armor != nil:
+		return ss.Armor.Pos.Convert(), true
+	case ss.Skull != nil && ss.Skull.PlayerID == "":
+		return ss.Skull.Pos.Convert(), true
+	}
+	// Land mines are never worth walking to
+	return pixel.ZV, false
+}
+
+func getWeaponAmmo(ss *protocol.ObjectSnapshot) (mag, ammo int) {
+	if ss == nil || ss.Weapon == nil {
+		return 0, 0
+	}
+	w := ss.Weapon
+	switch {
+	case w.M4 != nil:
+		return w.M4.Mag, w.M4.Ammo
+	case w.Shotgun != nil:
+		return w.Shotgun.Mag, w.Shotgun.Ammo
+	case w.Sniper != nil:
+		return w.Sniper.Mag, w.Sniper.Ammo
+	case w.Pistol != nil:
+		return w.Pistol.Mag, w.Pistol.Ammo
+	case w.SMG != nil:
+		return w.SMG.Mag, w.SMG.Ammo
+	}
+	return 0, 0
+}
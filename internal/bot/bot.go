@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/clientnet"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
+	"github.com/mr-panta/go-logger"
+	"golang.org/x/time/rate"
+)
+
+const (
+	botRetryPeriod  = 3 * time.Second
+	botMaxSendError = 10
+)
+
+type Bot interface {
+	Start()
+	Close()
+}
+
+// bot plays through the same network client as a real player, it only
+// doesn't have a window.
+type bot struct {
+	hostIP       string
+	roomName     string
+	playerName   string
+	clock        *ticktime.Clock
+	client       clientnet.ClientNetwork
+	ai           *botAI
+	playerID     string
+	sessionToken string
+	worldID      string
+	snapshot     *protocol.WorldSnapshot
+	lock         sync.RWMutex
+	// flags are written and read by different goroutines
+	isClosed int32
+	// set when the server pushes a disconnect, the bot joins again
+	isDisconnected int32
+}
+
+func NewBot(hostIP, roomName, playerName string) Bot {
+	return &bot{
+		hostIP:     hostIP,
+		roomName:   roomName,
+		playerName: playerName,
+		// Each bot has its own clock, rooms tick from their own start time
+		clock: ticktime.NewClock(),
+	}
+}
+
+func (b *bot) Start() {
+	go b.run()
+}
+
+func (b *bot) Close() {
+	atomic.StoreInt32(&b.isClosed, 1)
+}
+
+func (b *bot) run() {
+	ctx := logger.GetContextWithLogID(context.Background(), b.playerName)
+	for atomic.LoadInt32(&b.isClosed) == 0 {
+		if err := b.join(); err != nil {
+			logger.Errorf(ctx, err.Error())
+			time.Sleep(botRetryPeriod)
+			continue
+		}
+		logger.Infof(ctx, "joined world|world_id:%s|player_id:%s", b.worldID, b.playerID)
+		b.play()
+		if atomic.LoadInt32(&b.isClosed) == 1 {
+			b.leave()
+		}
+		if err := b.client.Close(); err != nil {
+			logger.Debugf(ctx, err.Error())
+		}
+	}
+}
+
+func (b *bot) join() error {
+	b.client = clientnet.NewClientNetwork(b.hostIP)
+	if err := b.client.Start(); err != nil {
+		return err
+	}
+	handshakeResp, err := b.client.Handshake(protocol.GetSupportedCodecs())
+	if err == nil && handshakeResp.ErrorCode != protocol.ErrorCodeNone {
+		err = errors.New(protocol.GetErrorMessage(handshakeResp.ErrorCode))
+	}
+	if err != nil {
+		_ = b.client.Close()
+		return err
+	}
+	resp, ping, err := b.client.RegisterPlayer(b.roomName, b.playerName)
+	if err == nil && resp.ErrorCode != protocol.ErrorCodeNone {
+		err = errors.New(protocol.GetErrorMessage(resp.ErrorCode))
+	}
+	if err != nil {
+		_ = b.client.Close()
+		return err
+	}
+	b.clock.SetServerTime(time.Unix(0, resp.ServerTime), ping)
+	b.clock.SetServerStartTime(time.Unix(0, resp.StartTime))
+	b.playerID = resp.PlayerID
+	b.sessionToken = resp.SessionToken
+	b.worldID = resp.WorldSnapshot.ID
+	atomic.StoreInt32(&b.isDisconnected, 0)
+	b.ai = newBotAI()
+	b.setSnapshot(resp.WorldSnapshot)
+	go b.consumeWorldSnapshot()
+	return nil
+}
+
+func (b *bot) consumeWorldSnapshot() {
+	for cmdData := range b.client.Listen() {
+		switch cmdData.Cmd {
+		case protocol.CmdAddWorldSnapshot:
+			data := cmdData.Data.(*protocol.AddWorldSnapshotRequest)
+			b.setSnapshot(data.WorldSnapshot)
+		case protocol.CmdDisconnect:
+			data := cmdData.Data.(*protocol.DisconnectRequest)
+			logger.Infof(context.Background(), "disconnected|player_id:%s|reason:%s",
+				b.playerID, protocol.GetDisconnectMessage(data.Reason))
+			atomic.StoreInt32(&b.isDisconnected, 1)
+		}
+	}
+}
+
+// leave removes the player of the bot right away
+func (b *bot) leave() {
+	if err := b.client.Leave(); err != nil {
+		logger.Debugf(context.Background(), err.Error())
+	}
+}
+
+func (b *bot) setSnapshot(snapshot *protocol.WorldSnapshot) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.snapshot = snapshot
+}
+
+func (b *bot) getSnapshot() *protocol.WorldSnapshot {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.snapshot
+}
+
+// play sends inputs until the world ends or the server stops answering
+func (b *bot) play() {
+	ctx := logger.GetContextWithLogID(context.Background(), b.playerName)
+	limiter := rate.NewLimiter(rate.Limit(config.ClientSyncRate), 1)
+	var seq int64
+	var inputTime time.Time
+	prevInputSSList := []*protocol.InputSnapshot{}
+	sendErrorCount := 0
+	for atomic.LoadInt32(&b.isClosed) == 0 && atomic.LoadInt32(&b.isDisconnected) == 0 &&
+		sendErrorCount < botMaxSendError {
+		_ = limiter.Wait(ctx)
+		snapshot := b.getSnapshot()
+		if snapshot.ID != b.worldID {
+			return
+		}
+		now := b.clock.GetServerTime()
+		inputSS := b.ai.getInput(b.playerID, snapshot, now)
+		if !inputTime.IsZero() {
+			inputSS.Duration = now.Sub(inputTime).Nanoseconds()
+		}
+		inputTime = now
+		seq++
+		_, err := b.client.Send(protocol.CmdSetPlayerInput, &protocol.SetPlayerInputRequest{
+			SessionToken:       b.sessionToken,
+			InputSnapshot:      inputSS,
+			AckTick:            b.client.GetAckTick(),
+			AckEventSeq:        b.client.GetAckEventSeq(),
+			Seq:                seq,
+			PrevInputSnapshots: prevInputSSList,
+		})
+		if err != nil {
+			logger.Debugf(ctx, err.Error())
+			sendErrorCount++
+		} else {
+			sendErrorCount = 0
+		}
+		// Keep latest inputs to resend
+		prevInputSSList = append(prevInputSSList, inputSS)
+		if len(prevInputSSList) > config.InputRedundancy {
+			prevInputSSList = prevInputSSList[1:]
+		}
+	}
+}
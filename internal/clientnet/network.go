@@ -0,0 +1,249 @@
+package clientnet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/network"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+type ClientNetwork interface {
+	Start() error
+	Wait()
+	Close() error
+	Send(cmd int, req interface{}) (resp interface{}, err error)
+	Listen() <-chan *protocol.CmdData
+	SetCodec(codecType int)
+	GetAckTick() int64
+	GetAckEventSeq() int64
+	Handshake(codecs []int) (resp *protocol.HandshakeResponse, err error)
+	RegisterPlayer(roomName, playerName string) (resp *protocol.RegisterPlayerResponse, ping time.Duration, err error)
+	ResumeSession(sessionToken string) (resp *protocol.ResumeSessionResponse, ping time.Duration, err error)
+	CreateRoom(name string) (resp *protocol.CreateRoomResponse, err error)
+	ListRooms() (resp *protocol.ListRoomsResponse, err error)
+	Leave() error
+}
+
+type clientNetwork struct {
+	buffer          chan *protocol.CmdData
+	client          network.Client
+	codec           protocol.Codec
+	snapshotHistory *protocol.WorldSnapshotHistory
+	ackTick         int64
+	ackEventSeq     int64
+	ackTickLock     sync.RWMutex
+	isClosed        bool
+	lock            sync.RWMutex
+}
+
+func NewClientNetwork(hostIP string) ClientNetwork {
+	var client network.Client
+	cfg := config.GetConfig()
+	switch cfg.Transport {
+	case config.TransportUDP:
+		client = network.NewUDPClient(hostIP+config.UDPPort, cfg.UDPLossRate)
+	case config.TransportTCP:
+		client = network.NewClient(hostIP+config.TCPPortA, hostIP+config.TCPPortB)
+	default:
+		client = network.NewMuxClient(hostIP + config.MuxPort)
+	}
+	return &clientNetwork{
+		buffer: make(chan *protocol.CmdData),
+		client: client,
+		codec:  protocol.GetCodec(protocol.CodecJSON),
+		// Keep more than the server, it may use a baseline acked a while ago
+		snapshotHistory: protocol.NewWorldSnapshotHistory(2 * config.DeltaBaselineHistory),
+	}
+}
+
+func (c *clientNetwork) Start() error {
+	go c.translateCmdData()
+	return c.client.Start()
+}
+
+func (c *clientNetwork) Wait() {
+	c.client.Wait()
+}
+
+func (c *clientNetwork) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// Reconnect may close a client which is closed again later
+	if c.isClosed {
+		return nil
+	}
+	c.isClosed = true
+	close(c.buffer)
+	return c.client.Close()
+}
+
+func (c *clientNetwork) Listen() <-chan *protocol.CmdData {
+	return c.buffer
+}
+
+func (c *clientNetwork) SetCodec(codecType int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.codec = protocol.GetCodec(codecType)
+}
+
+func (c *clientNetwork) GetAckTick() int64 {
+	c.ackTickLock.RLock()
+	defer c.ackTickLock.RUnlock()
+	return c.ackTick
+}
+
+// GetAckEventSeq returns the seq of the last game event received, the events
+// came with a snapshot so they share the ack lock.
+func (c *clientNetwork) GetAckEventSeq() int64 {
+	c.ackTickLock.RLock()
+	defer c.ackTickLock.RUnlock()
+	return c.ackEventSeq
+}
+
+func (c *clientNetwork) setAckEventSeq(seq int64) {
+	c.ackTickLock.Lock()
+	defer c.ackTickLock.Unlock()
+	c.ackEventSeq = seq
+}
+
+func (c *clientNetwork) setAckTick(tick int64) {
+	c.ackTickLock.Lock()
+	defer c.ackTickLock.Unlock()
+	c.ackTick = tick
+}
+
+func (c *clientNetwork) translateCmdData() {
+	for reqBytes := range c.client.Listen() {
+		c.lock.RLock()
+		if c.isClosed {
+			c.lock.RUnlock()
+			break
+		}
+		if cmdData := c.translate(reqBytes); cmdData != nil {
+			// Passing
+			c.buffer <- cmdData
+		}
+		c.lock.RUnlock()
+	}
+}
+
+func (c *clientNetwork) translate(reqBytes []byte) *protocol.CmdData {
+	// Prepare
+	ctx := context.Background()
+	wrappedData := &protocol.WrappedData{}
+	// Read req
+	if err := protocol.DetectCodec(reqBytes).Unmarshal(reqBytes, wrappedData); err != nil {
+		logger.Errorf(ctx, err.Error())
+		return nil
+	}
+	// Command routing
+	var data interface{}
+	switch wrappedData.Cmd {
+	case protocol.CmdAddWorldSnapshot:
+		req := wrappedData.AddWorldSnapshot
+		if req == nil || req.WorldSnapshot == nil {
+			return nil
+		}
+		if err := c.applyDelta(req); err != nil {
+			logger.Debugf(ctx, err.Error())
+			return nil
+		}
+		data = req
+	case protocol.CmdDisconnect:
+		if wrappedData.Disconnect == nil {
+			return nil
+		}
+		data = wrappedData.Disconnect
+	default:
+		return nil
+	}
+	return &protocol.CmdData{
+		Cmd:  wrappedData.Cmd,
+		Data: data,
+	}
+}
+
+// applyDelta replaces a delta snapshot with the full one, a delta whose
+// baseline is gone resets the ack so the server sends a full snapshot.
+func (c *clientNetwork) applyDelta(req *protocol.AddWorldSnapshotRequest) error {
+	if req.WorldSnapshot.IsDelta {
+		base, exists := c.snapshotHistory.Get(req.WorldSnapshot.BaseTick)
+		if !exists {
+			c.setAckTick(0)
+			return fmt.Errorf("delta baseline not found, base_tick=%d", req.WorldSnapshot.BaseTick)
+		}
+		full, err := protocol.PatchWorldSnapshot(base, req.WorldSnapshot)
+		if err != nil {
+			c.setAckTick(0)
+			return err
+		}
+		req.WorldSnapshot = full
+	}
+	c.snapshotHistory.Add(req.Tick, req.WorldSnapshot)
+	c.setAckTick(req.Tick)
+	c.setAckEventSeq(req.EventSeq)
+	return nil
+}
+
+func (c *clientNetwork) Send(cmd int, req interface{}) (resp interface{}, err error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.isClosed {
+		return nil, fmt.Errorf("processor network client is closed")
+	}
+	wrappedData := &protocol.WrappedData{
+		Cmd: cmd,
+	}
+	// Command routing
+	switch cmd {
+	case protocol.CmdRegisterPlayer:
+		wrappedData.RegisterPlayer = req.(*protocol.RegisterPlayerRequest)
+		resp = &protocol.RegisterPlayerResponse{}
+	case protocol.CmdSetPlayerInput:
+		wrappedData.SetPlayerInput = req.(*protocol.SetPlayerInputRequest)
+		resp = &protocol.SetPlayerInputResponse{}
+	case protocol.CmdResumeSession:
+		wrappedData.ResumeSession = req.(*protocol.ResumeSessionRequest)
+		resp = &protocol.ResumeSessionResponse{}
+	case protocol.CmdHandshake:
+		wrappedData.Handshake = req.(*protocol.HandshakeRequest)
+		resp = &protocol.HandshakeResponse{}
+	case protocol.CmdDisconnect:
+		wrappedData.Disconnect = req.(*protocol.DisconnectRequest)
+		resp = &protocol.DisconnectResponse{}
+	case protocol.CmdTimeSync:
+		wrappedData.TimeSync = req.(*protocol.TimeSyncRequest)
+		resp = &protocol.TimeSyncResponse{}
+	case protocol.CmdListRooms:
+		wrappedData.ListRooms = req.(*protocol.ListRoomsRequest)
+		resp = &protocol.ListRoomsResponse{}
+	case protocol.CmdCreateRoom:
+		wrappedData.CreateRoom = req.(*protocol.CreateRoomRequest)
+		resp = &protocol.CreateRoomResponse{}
+	}
+	// Send and receive data
+	reqBytes, err := c.codec.Marshal(wrappedData)
+	if err != nil {
+		return nil, err
+	}
+	var respBytes []byte
+	if cmd == protocol.CmdSetPlayerInput {
+		// Lost input is replaced by the next one
+		respBytes, err = c.client.SendUnreliable(reqBytes)
+	} else {
+		respBytes, err = c.client.Send(reqBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = protocol.DetectCodec(respBytes).Unmarshal(respBytes, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
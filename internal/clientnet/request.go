@@ -0,0 +1,99 @@
+package clientnet
+
+import (
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// Handshake agrees on a protocol version and features with the server, the
+// selected codec is used from then on unless the server returns an error code.
+// The client needs sessions, so it doesn't offer the legacy version.
+func (c *clientNetwork) Handshake(codecs []int) (resp *protocol.HandshakeResponse, err error) {
+	r, err := c.Send(
+		protocol.CmdHandshake,
+		&protocol.HandshakeRequest{
+			MinVersion:   protocol.ProtocolVersionSessions,
+			MaxVersion:   protocol.ProtocolVersion,
+			GameVersion:  config.Version,
+			Codecs:       codecs,
+			Capabilities: protocol.GetSupportedCapabilities(),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	resp = r.(*protocol.HandshakeResponse)
+	if resp.ErrorCode == protocol.ErrorCodeNone && resp.Version < protocol.ProtocolVersionSessions {
+		resp.ErrorCode = protocol.ErrorCodeUnsupportedVersion
+	}
+	if resp.ErrorCode == protocol.ErrorCodeNone {
+		c.SetCodec(resp.Codec)
+	}
+	return resp, nil
+}
+
+// RegisterPlayer joins the room, ping is how long the server took to answer
+// so the clock can be set from the response.
+func (c *clientNetwork) RegisterPlayer(roomName, playerName string) (
+	resp *protocol.RegisterPlayerResponse, ping time.Duration, err error) {
+	now := time.Now()
+	r, err := c.Send(
+		protocol.CmdRegisterPlayer,
+		&protocol.RegisterPlayerRequest{
+			PlayerName: playerName,
+			RoomName:   roomName,
+		},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.(*protocol.RegisterPlayerResponse), time.Since(now), nil
+}
+
+// ResumeSession takes the player of the session over to this connection
+func (c *clientNetwork) ResumeSession(sessionToken string) (
+	resp *protocol.ResumeSessionResponse, ping time.Duration, err error) {
+	now := time.Now()
+	r, err := c.Send(
+		protocol.CmdResumeSession,
+		&protocol.ResumeSessionRequest{
+			SessionToken: sessionToken,
+		},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r.(*protocol.ResumeSessionResponse), time.Since(now), nil
+}
+
+func (c *clientNetwork) CreateRoom(name string) (resp *protocol.CreateRoomResponse, err error) {
+	r, err := c.Send(
+		protocol.CmdCreateRoom,
+		&protocol.CreateRoomRequest{
+			Name: name,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.(*protocol.CreateRoomResponse), nil
+}
+
+func (c *clientNetwork) ListRooms() (resp *protocol.ListRoomsResponse, err error) {
+	r, err := c.Send(protocol.CmdListRooms, &protocol.ListRoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return r.(*protocol.ListRoomsResponse), nil
+}
+
+// Leave tells the server that the player is gone, so it doesn't wait for
+// the player to time out.
+func (c *clientNetwork) Leave() error {
+	_, err := c.Send(protocol.CmdDisconnect, &protocol.DisconnectRequest{
+		Reason: protocol.DisconnectReasonQuit,
+	})
+	return err
+}
@@ -1,48 +1,85 @@
 package common
 
 import (
+	"image/color"
+	"math/rand"
 	"time"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/animation"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 )
 
 // Menu
 
 type Menu interface {
 	UpdateAndRender()
+	SetMessage(message string)
 }
 
 // World
 
+// World is a game world, the server only uses its simulation part
 type World interface {
+	WorldSimulation
+	WorldPresentation
+}
+
+// WorldSimulation runs the game, it doesn't need a window or audio device
+type WorldSimulation interface {
 	// Common
 	GetID() string
 	GetType() int
+	GetClock() *ticktime.Clock
+	GetTime() time.Time
+	GetRand() *rand.Rand
+	GetWorldConfig() *config.WorldConfig
 	GetObjectDB() ObjectDB
 	GetSize() (width, height int)
 	CheckCollision(id string, prevCollider, nextCollider pixel.Rect) (
 		obj Object, staticAdjust, dynamicAdjust pixel.Vec)
+	// Server
+	ServerUpdate(tick int64) (exists bool)
+	SpawnPlayer(playerID string, playerName string)
+	SpawnBot() (playerID string)
+	RemoveBot(playerID string)
+	GetSnapshot(all bool) (tick int64, snapshot *protocol.WorldSnapshot)
+	FilterSnapshot(playerID string, snapshot *protocol.WorldSnapshot) *protocol.WorldSnapshot
+	SetInputSnapshot(playerID string, seq int64, snapshot *protocol.InputSnapshot)
+	AddGameEvent(event *protocol.GameEvent)
+	GetGameEvents(afterSeq int64) (events []*protocol.GameEvent, lastSeq int64)
+	Destroy()
+}
+
+// WorldPresentation shows a world in the game window on the client
+type WorldPresentation interface {
 	GetHud() Hud
-	// Client
 	Render()
-	GetWindow() *pixelgl.Window
+	GetWindow() Window
 	ClientUpdate() (exists bool)
 	SetMainPlayerID(playerID string)
 	GetMainPlayerID() string
 	GetMainPlayer() Player
 	SetSnapshot(tick int64, snapshot *protocol.WorldSnapshot)
-	GetInputSnapshot() *protocol.InputSnapshot
+	GetInputSnapshot() (seq int64, snapshot *protocol.InputSnapshot)
+	SetGameEvents(events []*protocol.GameEvent)
 	GetCameraViewPos() pixel.Vec
 	GetScope() Scope
-	// Server
-	ServerUpdate(tick int64) (exists bool)
-	SpawnPlayer(playerID string, playerName string)
-	GetSnapshot(all bool) (tick int64, snapshot *protocol.WorldSnapshot)
-	SetInputSnapshot(playerID string, snapshot *protocol.InputSnapshot)
-	Destroy()
+}
+
+// Window is the game window, the client implements it over pixelgl
+type Window interface {
+	pixel.Target
+	Bounds() pixel.Rect
+	Clear(c color.Color)
+	MousePosition() pixel.Vec
+	MouseInsideWindow() bool
+	Smooth() bool
+	SetSmooth(smooth bool)
+	GetRawInput() *RawInput
+	ToggleFullScreen()
 }
 
 // Processors
@@ -51,31 +88,40 @@ type ClientProcessor interface {
 	Restart()
 	ToggleFPSLimit()
 	Close()
-	GetWindow() *pixelgl.Window
+	GetWindow() Window
 	Run()
-	StartWorld(hostIP, playerName string) (err error)
+	StartWorld(hostIP, roomName, playerName string) (err error)
+	ListRooms(hostIP string) (rooms []*protocol.RoomInfo, err error)
+	StartReplay(fileName string) (err error)
 }
 
 type ServerProcessor interface {
+	Start()
 	Wait()
-	UpdateWorld()
-	BroadcastSnapshot()
-	CleanWorld()
+	Shutdown()
 }
 
 // Objects
 
 type Object interface {
+	ObjectSimulation
+	ObjectPresentation
+}
+
+type ObjectSimulation interface {
 	GetID() string
 	GetType() int
 	Destroy()
 	Exists() bool
 	GetShape() pixel.Rect
 	GetCollider() (pixel.Rect, bool)
-	GetRenderObjects() []RenderObject
 	GetSnapshot(tick int64) *protocol.ObjectSnapshot
 	SetSnapshot(tick int64, snapshot *protocol.ObjectSnapshot)
 	ServerUpdate(tick int64)
+}
+
+type ObjectPresentation interface {
+	GetRenderObjects() []RenderObject
 	ClientUpdate()
 }
 
@@ -84,13 +130,19 @@ type Player interface {
 	GetPos() pixel.Vec
 	SetPos(pos pixel.Vec)
 	SetMainPlayer()
+	SetBot()
+	SetExtraItemSlots()
+	IsBot() bool
 	GetPivot() pixel.Vec
 	SetInput(input *protocol.InputSnapshot)
+	AddInput(seq int64, input *protocol.InputSnapshot)
 	GetMeleeWeapon() Weapon
 	SetMeleeWeapon(w Weapon)
 	GetWeapon() Weapon
 	SetWeapon(w Weapon)
 	GetCursorDir() pixel.Vec
+	GetViewDelay() time.Duration
+	GetShapeByTime(t time.Time) pixel.Rect
 	Die(firingPlayerID string, weaponID string)
 	IncreaseKill()
 	GetStats() (kill, death, streak, maxStreak int)
@@ -166,6 +218,13 @@ type Hud interface {
 	GetKillFeedSnapshot() *protocol.KillFeedSnapshot
 	SetKillFeedSnapshot(snapshot *protocol.KillFeedSnapshot)
 	AddKillFeedRow(killerPlayerID, victimPlayerID, weaponID string)
+	HandleGameEvent(event *protocol.GameEvent)
+}
+
+// GameEventHandler is an object which reacts to its game events on the
+// client, like a weapon playing its fire sound.
+type GameEventHandler interface {
+	HandleGameEvent(event *protocol.GameEvent)
 }
 
 type Scope interface {
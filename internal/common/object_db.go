@@ -1,6 +1,8 @@
 package common
 
 import (
+	"math/rand"
+
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 )
 
@@ -14,10 +16,12 @@ type ObjectDB interface {
 
 type objectDB struct {
 	inMemDB util.InMemDB
+	rng     *rand.Rand
 }
 
-func NewObjectDB() ObjectDB {
-	return &objectDB{inMemDB: util.NewInMemDB()}
+// NewObjectDB returns an empty db, new ids are drawn from rng
+func NewObjectDB(rng *rand.Rand) ObjectDB {
+	return &objectDB{inMemDB: util.NewInMemDB(), rng: rng}
 }
 
 func (db *objectDB) SelectOne(id string) (p Object, exists bool) {
@@ -44,7 +48,7 @@ func (db *objectDB) Delete(id string) {
 
 func (db *objectDB) GetAvailableID() string {
 	for {
-		id := util.GenerateID()
+		id := util.GenerateIDFrom(db.rng)
 		if _, exists := db.inMemDB.SelectOne(id); !exists {
 			return id
 		}
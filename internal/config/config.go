@@ -1,11 +1,16 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 type Config struct {
 	WindowWidth  float64
 	WindowHeight float64
 	RefreshRate  int64
+	Transport    string
+	UDPLossRate  float64
 }
 
 var config *Config
@@ -16,6 +21,8 @@ func GetConfig() *Config {
 			WindowWidth:  DefaultWindowWidth,
 			WindowHeight: DefaultWindowHeight,
 			RefreshRate:  DefaultRefreshRate,
+			Transport:    EnvTransport(),
+			UDPLossRate:  EnvUDPLossRate(),
 		}
 	}
 	return config
@@ -33,3 +40,22 @@ func EnvGorun() bool {
 func EnvDebug() bool {
 	return os.Getenv("DEBUG") != ""
 }
+
+// EnvJSONCodec keeps the client on the json codec, which is easier to debug.
+func EnvJSONCodec() bool {
+	return os.Getenv("JSON_CODEC") != ""
+}
+
+func EnvTransport() string {
+	switch transport := os.Getenv("TRANSPORT"); transport {
+	case TransportTCP, TransportUDP:
+		return transport
+	}
+	return TransportMux
+}
+
+// EnvUDPLossRate drops outgoing udp packets to test bad connections
+func EnvUDPLossRate() float64 {
+	rate, _ := strconv.ParseFloat(os.Getenv("UDP_LOSS"), 64)
+	return rate
+}
@@ -4,11 +4,10 @@ import (
 	"image/color"
 	"time"
 
-	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/colornames"
 )
 
-const Version = "0.2.0"
+const Version = "0.4.0"
 
 // default
 const (
@@ -20,23 +19,38 @@ const (
 
 // settings
 const (
-	Timestep        = 15 * time.Millisecond
+	Timestep = 15 * time.Millisecond
+	// LerpPeriod is the initial lerp period, clients adapt it
 	LerpPeriod      = 100 * time.Millisecond
+	MaxRewindPeriod = 200 * time.Millisecond
 	ServerSyncRate  = 30
 	ClientSyncRate  = 30
 	ClientInputRate = 256
 	Title           = "Sirimongkol Project V2"
 	IDLength        = 8
-	RespawnTime     = 3 * time.Second
-	PlayerTimeOut   = 10 * time.Second
+	TokenLength     = 16
 	BufferSize      = 1000
 	LogFile         = "data.log"
 )
 
 // world
 const (
-	MinWindowRenderZ     = 1000
-	DefaultWorldInitTime = 60 * time.Second
+	MinWindowRenderZ              = 1000
+	DefaultWorldInitTime          = 60 * time.Second
+	DefaultWorldFieldWidth        = 20
+	DefaultWorldFieldHeight       = 20
+	DefaultWorldTreeAmount        = 16
+	DefaultWorldTerrainAmount     = 8
+	DefaultWorldMinNextItemPeriod = 10 * time.Second
+	DefaultWorldMaxNextItemPeriod = 20 * time.Second
+	DefaultRespawnTime            = 3 * time.Second
+	DefaultPlayerTimeOut          = 10 * time.Second
+	// rooms
+	DefaultRoomName   = "lobby"
+	DefaultMaxRooms   = 16
+	MaxRoomNameLength = 16
+	// rooms created by players close when nobody played for this long
+	RoomIdleTimeOut = time.Minute
 )
 
 // network
@@ -45,26 +59,49 @@ const (
 	// TCPIP    = ""
 	TCPPortA = ":4999"
 	TCPPortB = ":4998"
-)
-
-// key
-const (
-	FireKey          = pixelgl.MouseButton1
-	MeleeKey         = pixelgl.MouseButton2
-	UpKey            = pixelgl.KeyW
-	LeftKey          = pixelgl.KeyA
-	DownKey          = pixelgl.KeyS
-	RightKey         = pixelgl.KeyD
-	ReloadKey        = pixelgl.KeyR
-	DropKey          = pixelgl.KeyG
-	Use1stItemKey    = pixelgl.Key1
-	Use2ndItemKey    = pixelgl.Key2
-	Use3rdItemKey    = pixelgl.Key3
-	ToggleMuteKey    = pixelgl.KeyM
-	VolumeUpKey      = pixelgl.KeyUp
-	VolumeDownKey    = pixelgl.KeyDown
-	ToggleFullScreen = pixelgl.KeyF10
-	ToggleFPSLimit   = pixelgl.KeyF9
+	UDPPort  = ":4997"
+	MuxPort  = ":4996"
+	// transports, tcp is the old dual port mode
+	TransportMux = "mux"
+	TransportTCP = "tcp"
+	TransportUDP = "udp"
+	// snapshots older than this are not used as delta baselines
+	DeltaBaselineHistory = ServerSyncRate
+	// delta differs of worlds which sent nothing for this long are dropped
+	WorldDifferTimeOut = time.Second
+	// game events kept for clients which haven't acked them
+	GameEventHistory = 1024
+	// inputs resent with each input request
+	InputRedundancy = 3
+	// connection limits
+	DefaultMaxFrameSize        = 1 << 20
+	DefaultMaxDecompressedSize = 8 << 20
+	DefaultIdleTimeOut         = 30 * time.Second
+	DefaultIOTimeOut           = 5 * time.Second
+	DefaultSlowClientTimeOut   = 3 * time.Second
+	// the server logs connection errors and slow clients every period
+	NetworkStatsPeriod = 30 * time.Second
+	// peers of a mux connection send a heartbeat every period
+	HeartbeatPeriod         = time.Second
+	DefaultHeartbeatTimeOut = 5 * time.Second
+	// clock sync sends a burst of samples after joining, then one per period
+	TimeSyncPeriod      = time.Second
+	TimeSyncBurstPeriod = 100 * time.Millisecond
+	TimeSyncSamples     = 8
+	// samples with rtt above this factor of the median are outliers
+	TimeSyncOutlierFactor = 1.5
+	// the offset moves by this fraction of the error per sample, it only
+	// jumps when the error is above the threshold
+	TimeSyncSmoothing     = 0.2
+	TimeSyncJumpThreshold = 250 * time.Millisecond
+	// the lerp period adapts to snapshot jitter and loss within these bounds,
+	// the max is MaxRewindPeriod so hits are still rewound to what was seen
+	MinLerpPeriod    = 50 * time.Millisecond
+	MaxLerpPeriod    = MaxRewindPeriod
+	LerpPeriodStep   = time.Millisecond
+	LerpJitterFactor = 3
+	LerpLossFactor   = 4
+	MaxExtrapolation = 150 * time.Millisecond
 )
 
 // color
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ServerConfig struct {
+	Transport     string   `json:"transport,omitempty"`
+	MuxAddr       string   `json:"mux_addr,omitempty"`
+	TCPAddrA      string   `json:"tcp_addr_a,omitempty"`
+	TCPAddrB      string   `json:"tcp_addr_b,omitempty"`
+	UDPAddr       string   `json:"udp_addr,omitempty"`
+	UDPLossRate   float64  `json:"udp_loss_rate,omitempty"`
+	PlayerTimeOut Duration `json:"player_time_out,omitempty"`
+	// connection limits
+	MaxFrameSize        int      `json:"max_frame_size,omitempty"`
+	MaxDecompressedSize int      `json:"max_decompressed_size,omitempty"`
+	IdleTimeOut         Duration `json:"idle_time_out,omitempty"`
+	IOTimeOut           Duration `json:"io_time_out,omitempty"`
+	SlowClientTimeOut   Duration `json:"slow_client_time_out,omitempty"`
+	HeartbeatTimeOut    Duration `json:"heartbeat_time_out,omitempty"`
+	// every world is recorded to this directory, empty disables recording
+	ReplayDir string `json:"replay_dir,omitempty"`
+	// rooms which are always open, players join the first one when they
+	// don't pick a room
+	Rooms    StringList  `json:"rooms,omitempty"`
+	MaxRooms int         `json:"max_rooms,omitempty"`
+	World    WorldConfig `json:"world,omitempty"`
+}
+
+type WorldConfig struct {
+	FieldWidth        int      `json:"field_width,omitempty"`
+	FieldHeight       int      `json:"field_height,omitempty"`
+	TreeAmount        int      `json:"tree_amount,omitempty"`
+	TerrainAmount     int      `json:"terrain_amount,omitempty"`
+	MinNextItemPeriod Duration `json:"min_next_item_period,omitempty"`
+	MaxNextItemPeriod Duration `json:"max_next_item_period,omitempty"`
+	RespawnTime       Duration `json:"respawn_time,omitempty"`
+	InitTime          Duration `json:"init_time,omitempty"`
+	// bots are added while there are fewer players than this
+	MinPlayers int `json:"min_players,omitempty"`
+	// worlds with the same seed and inputs play the same game, a random
+	// seed is used when it's 0
+	Seed int64 `json:"seed,omitempty"`
+}
+
+func NewServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Transport:           TransportMux,
+		MuxAddr:             MuxPort,
+		TCPAddrA:            TCPPortA,
+		TCPAddrB:            TCPPortB,
+		UDPAddr:             UDPPort,
+		PlayerTimeOut:       Duration{DefaultPlayerTimeOut},
+		MaxFrameSize:        DefaultMaxFrameSize,
+		MaxDecompressedSize: DefaultMaxDecompressedSize,
+		IdleTimeOut:         Duration{DefaultIdleTimeOut},
+		IOTimeOut:           Duration{DefaultIOTimeOut},
+		SlowClientTimeOut:   Duration{DefaultSlowClientTimeOut},
+		HeartbeatTimeOut:    Duration{DefaultHeartbeatTimeOut},
+		Rooms:               StringList{DefaultRoomName},
+		MaxRooms:            DefaultMaxRooms,
+		World:               *NewWorldConfig(),
+	}
+}
+
+func NewWorldConfig() *WorldConfig {
+	return &WorldConfig{
+		FieldWidth:        DefaultWorldFieldWidth,
+		FieldHeight:       DefaultWorldFieldHeight,
+		TreeAmount:        DefaultWorldTreeAmount,
+		TerrainAmount:     DefaultWorldTerrainAmount,
+		MinNextItemPeriod: Duration{DefaultWorldMinNextItemPeriod},
+		MaxNextItemPeriod: Duration{DefaultWorldMaxNextItemPeriod},
+		RespawnTime:       Duration{DefaultRespawnTime},
+		InitTime:          Duration{DefaultWorldInitTime},
+	}
+}
+
+// LoadFile overrides the config with values from a json file,
+// fields missing from the file keep their current values. Json is the only
+// format, other files are rejected by their extension.
+func (c *ServerConfig) LoadFile(fileName string) error {
+	if ext := strings.ToLower(filepath.Ext(fileName)); ext != ".json" {
+		return errors.New("config file must be a .json file")
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, c)
+}
+
+// ParseFlags parses args into the config. The json file given by -config is
+// loaded in between, so flags take precedence over the file.
+func (c *ServerConfig) ParseFlags(fs *flag.FlagSet, args []string) error {
+	configFile := fs.String("config", "", "path to a json config file, json is the only format, flags override its values")
+	c.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configFile == "" {
+		return nil
+	}
+	if err := c.LoadFile(*configFile); err != nil {
+		return err
+	}
+	// Apply flags again so they take precedence over the file
+	return fs.Parse(args)
+}
+
+func (c *ServerConfig) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Transport, "transport", c.Transport, "network transport, mux, tcp or udp")
+	fs.StringVar(&c.MuxAddr, "mux-addr", c.MuxAddr, "listen address for mux transport")
+	fs.StringVar(&c.TCPAddrA, "tcp-addr-a", c.TCPAddrA, "listen address for request/response connections")
+	fs.StringVar(&c.TCPAddrB, "tcp-addr-b", c.TCPAddrB, "listen address for broadcast connections")
+	fs.StringVar(&c.UDPAddr, "udp-addr", c.UDPAddr, "listen address for udp transport")
+	fs.Float64Var(&c.UDPLossRate, "udp-loss-rate", c.UDPLossRate, "simulated outgoing packet loss for udp transport")
+	fs.Var(&c.PlayerTimeOut, "player-time-out", "remove a player after no input for this duration")
+	fs.IntVar(&c.MaxFrameSize, "max-frame-size", c.MaxFrameSize, "largest frame in bytes which a connection may send")
+	fs.IntVar(&c.MaxDecompressedSize, "max-decompressed-size", c.MaxDecompressedSize, "largest request in bytes after decompression")
+	fs.Var(&c.IdleTimeOut, "idle-time-out", "close a connection which sends nothing for this duration")
+	fs.Var(&c.IOTimeOut, "io-time-out", "close a connection which takes longer to send or receive a frame")
+	fs.Var(&c.SlowClientTimeOut, "slow-client-time-out", "evict a client which stays behind on snapshots for this duration")
+	fs.Var(&c.HeartbeatTimeOut, "heartbeat-time-out", "close a mux connection which misses heartbeats for this duration")
+	fs.StringVar(&c.ReplayDir, "replay-dir", c.ReplayDir, "record every world to a replay file in this directory")
+	fs.Var(&c.Rooms, "rooms", "comma separated names of rooms which are always open, the first is the default")
+	fs.IntVar(&c.MaxRooms, "max-rooms", c.MaxRooms, "most rooms open at once, including the ones players create")
+	fs.IntVar(&c.World.FieldWidth, "field-width", c.World.FieldWidth, "world width in fields")
+	fs.IntVar(&c.World.FieldHeight, "field-height", c.World.FieldHeight, "world height in fields")
+	fs.IntVar(&c.World.TreeAmount, "tree-amount", c.World.TreeAmount, "number of trees")
+	fs.IntVar(&c.World.TerrainAmount, "terrain-amount", c.World.TerrainAmount, "number of terrains")
+	fs.Var(&c.World.MinNextItemPeriod, "min-next-item-period", "minimum period between item spawns")
+	fs.Var(&c.World.MaxNextItemPeriod, "max-next-item-period", "maximum period between item spawns")
+	fs.Var(&c.World.RespawnTime, "respawn-time", "player respawn duration")
+	fs.Var(&c.World.InitTime, "init-time", "skull holding time needed to win")
+	fs.IntVar(&c.World.MinPlayers, "min-players", c.World.MinPlayers, "fill the world with bots up to this many players")
+	fs.Int64Var(&c.World.Seed, "seed", c.World.Seed, "random seed of worlds, 0 picks one")
+}
+
+func (c *ServerConfig) Validate() error {
+	switch c.Transport {
+	case TransportMux:
+		if c.MuxAddr == "" {
+			return errors.New("mux address must not be empty")
+		}
+	case TransportTCP:
+		if c.TCPAddrA == "" || c.TCPAddrB == "" {
+			return errors.New("tcp addresses must not be empty")
+		}
+		if c.TCPAddrA == c.TCPAddrB {
+			return errors.New("tcp addresses must be different")
+		}
+	case TransportUDP:
+		if c.UDPAddr == "" {
+			return errors.New("udp address must not be empty")
+		}
+		if c.UDPLossRate < 0 || c.UDPLossRate >= 1 {
+			return errors.New("udp loss rate must be in [0, 1)")
+		}
+	default:
+		return errors.New("transport must be mux, tcp or udp")
+	}
+	if c.PlayerTimeOut.Duration <= 0 {
+		return errors.New("player time out must be positive")
+	}
+	if c.MaxFrameSize <= 0 || c.MaxDecompressedSize <= 0 {
+		return errors.New("frame sizes must be positive")
+	}
+	if c.IdleTimeOut.Duration <= 0 || c.IOTimeOut.Duration <= 0 || c.SlowClientTimeOut.Duration <= 0 {
+		return errors.New("connection time outs must be positive")
+	}
+	if c.HeartbeatTimeOut.Duration <= HeartbeatPeriod {
+		return errors.New("heartbeat time out must be longer than the heartbeat period")
+	}
+	if len(c.Rooms) == 0 || len(c.Rooms) > c.MaxRooms {
+		return errors.New("there must be at least one room and no more than max rooms")
+	}
+	for i, name := range c.Rooms {
+		if !IsValidRoomName(name) {
+			return errors.New("room names must be non-empty and short")
+		}
+		for _, other := range c.Rooms[:i] {
+			if strings.EqualFold(name, other) {
+				return errors.New("room names must be unique")
+			}
+		}
+	}
+	return c.World.Validate()
+}
+
+func (c *WorldConfig) Validate() error {
+	if c.FieldWidth <= 0 || c.FieldHeight <= 0 {
+		return errors.New("field size must be positive")
+	}
+	if c.TreeAmount < 0 || c.TerrainAmount < 0 {
+		return errors.New("prop amounts must not be negative")
+	}
+	if c.MinNextItemPeriod.Duration <= 0 || c.MaxNextItemPeriod.Duration < c.MinNextItemPeriod.Duration {
+		return errors.New("item periods must be positive and max must not be less than min")
+	}
+	if c.RespawnTime.Duration <= 0 {
+		return errors.New("respawn time must be positive")
+	}
+	if c.InitTime.Duration <= 0 {
+		return errors.New("init time must be positive")
+	}
+	if c.MinPlayers < 0 {
+		return errors.New("min players must not be negative")
+	}
+	return nil
+}
+
+// IsValidRoomName is true for a short name without surrounding spaces
+func IsValidRoomName(name string) bool {
+	return len(name) > 0 && len(name) <= MaxRoomNameLength && name == strings.TrimSpace(name)
+}
+
+// Duration is a time.Duration which is written as "10s" in json files and flags.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+func (d *Duration) Set(s string) (err error) {
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// StringList is a list which is written as "a,b" in flags.
+type StringList []string
+
+func (l StringList) String() string {
+	return strings.Join(l, ",")
+}
+
+func (l *StringList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		*l = append(*l, strings.TrimSpace(v))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTestFile writes data to name in a new temp dir, which is removed by
+// the returned func
+func writeTestFile(t *testing.T, name, data string) (fileName string, remove func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName = filepath.Join(dir, name)
+	if err := ioutil.WriteFile(fileName, []byte(data), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return fileName, func() { os.RemoveAll(dir) }
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(c *ServerConfig)
+		valid bool
+	}{
+		{"default", func(c *ServerConfig) {}, true},
+		{"tcp", func(c *ServerConfig) { c.Transport = TransportTCP }, true},
+		{"udp", func(c *ServerConfig) { c.Transport = TransportUDP; c.UDPLossRate = 0.5 }, true},
+		{"unknown transport", func(c *ServerConfig) { c.Transport = "quic" }, false},
+		{"no mux address", func(c *ServerConfig) { c.MuxAddr = "" }, false},
+		{"same tcp addresses", func(c *ServerConfig) { c.Transport = TransportTCP; c.TCPAddrB = c.TCPAddrA }, false},
+		{"udp loss rate of 1", func(c *ServerConfig) { c.Transport = TransportUDP; c.UDPLossRate = 1 }, false},
+		{"no player time out", func(c *ServerConfig) { c.PlayerTimeOut.Duration = 0 }, false},
+		{"no frame size", func(c *ServerConfig) { c.MaxFrameSize = 0 }, false},
+		{"no io time out", func(c *ServerConfig) { c.IOTimeOut.Duration = 0 }, false},
+		{"heartbeat time out of a period", func(c *ServerConfig) { c.HeartbeatTimeOut.Duration = HeartbeatPeriod }, false},
+		{"no rooms", func(c *ServerConfig) { c.Rooms = nil }, false},
+		{"more rooms than max", func(c *ServerConfig) { c.MaxRooms = 1; c.Rooms = StringList{"a", "b"} }, false},
+		{"padded room name", func(c *ServerConfig) { c.Rooms = StringList{" a"} }, false},
+		{"same room names", func(c *ServerConfig) { c.Rooms = StringList{"main", "MAIN"} }, false},
+		{"no field", func(c *ServerConfig) { c.World.FieldWidth = 0 }, false},
+		{"negative trees", func(c *ServerConfig) { c.World.TreeAmount = -1 }, false},
+		{"max item period below min", func(c *ServerConfig) {
+			c.World.MaxNextItemPeriod.Duration = c.World.MinNextItemPeriod.Duration - 1
+		}, false},
+		{"no respawn time", func(c *ServerConfig) { c.World.RespawnTime.Duration = 0 }, false},
+		{"no init time", func(c *ServerConfig) { c.World.InitTime.Duration = 0 }, false},
+		{"negative min players", func(c *ServerConfig) { c.World.MinPlayers = -1 }, false},
+	}
+	for _, c := range cases {
+		cfg := NewServerConfig()
+		c.edit(cfg)
+		if err := cfg.Validate(); (err == nil) != c.valid {
+			t.Fatalf("%s: got %v", c.name, err)
+		}
+	}
+}
+
+func TestLoadFileKeepsMissingFields(t *testing.T) {
+	fileName, remove := writeTestFile(t, "server.json", `{
+		"transport": "udp",
+		"player_time_out": "3s",
+		"rooms": ["a", "b"],
+		"world": {"min_players": 2}
+	}`)
+	defer remove()
+	cfg := NewServerConfig()
+	if err := cfg.LoadFile(fileName); err != nil {
+		t.Fatal(err)
+	}
+	want := NewServerConfig()
+	want.Transport = TransportUDP
+	want.PlayerTimeOut.Duration = 3 * time.Second
+	want.Rooms = StringList{"a", "b"}
+	want.World.MinPlayers = 2
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestLoadFileRejectsOtherFormats(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"server.yaml", "transport: udp\n"},
+		{"server.yml", "transport: udp\n"},
+		{"server.json", "transport: udp\n"},
+		{"server.json", `{"player_time_out": 3}`},
+	}
+	for _, c := range cases {
+		fileName, remove := writeTestFile(t, c.name, c.data)
+		cfg := NewServerConfig()
+		err := cfg.LoadFile(fileName)
+		remove()
+		if err == nil {
+			t.Fatalf("%s was loaded from %q", c.name, c.data)
+		}
+		if cfg.Transport != TransportMux {
+			t.Fatalf("%s changed the config", c.name)
+		}
+	}
+}
+
+func TestFlagsOverrideFile(t *testing.T) {
+	fileName, remove := writeTestFile(t, "server.json", `{
+		"transport": "udp",
+		"udp_addr": ":1",
+		"player_time_out": "3s",
+		"rooms": ["a", "b"],
+		"world": {"seed": 5, "min_players": 2}
+	}`)
+	defer remove()
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{"flags after file", []string{"-config", fileName, "-udp-addr", ":2", "-rooms", "c", "-min-players", "4"}},
+		{"flags before file", []string{"-udp-addr", ":2", "-rooms", "c", "-min-players", "4", "-config", fileName}},
+	}
+	for _, c := range cases {
+		cfg := NewServerConfig()
+		fs := flag.NewFlagSet("server", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if err := cfg.ParseFlags(fs, c.args); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		want := NewServerConfig()
+		// from the file
+		want.Transport = TransportUDP
+		want.PlayerTimeOut.Duration = 3 * time.Second
+		want.World.Seed = 5
+		// from flags
+		want.UDPAddr = ":2"
+		want.Rooms = StringList{"c"}
+		want.World.MinPlayers = 4
+		if !reflect.DeepEqual(cfg, want) {
+			t.Fatalf("%s: got %+v, want %+v", c.name, cfg, want)
+		}
+	}
+	// Without a file only flags apply
+	cfg := NewServerConfig()
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := cfg.ParseFlags(fs, []string{"-player-time-out", "4s"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PlayerTimeOut.Duration != 4*time.Second || cfg.Transport != TransportMux {
+		t.Fatalf("got %+v", cfg)
+	}
+}
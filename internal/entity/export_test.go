@@ -0,0 +1,14 @@
+package entity
+
+import (
+	"github.com/faiface/pixel"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// PredictPos returns where the client replay of the inputs after ss ends
+func PredictPos(p common.Player, ss *protocol.PlayerSnapshot) pixel.Vec {
+	pl := p.(*player)
+	pl.predict(ss, pl.world.GetTime())
+	return pl.predictPos
+}
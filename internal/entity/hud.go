@@ -13,7 +13,7 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
 	"golang.org/x/image/colornames"
 )
 
@@ -33,8 +33,12 @@ var (
 	hudInventoryMargin           = pixel.V(48, 64)
 	hudFirstItem                 = pixel.V(47, 118)
 	hudSecondItem                = pixel.V(98, 108)
+	hudThirdItem                 = pixel.V(149, 98)
 	// crosshair
 	crosshairColor = colornames.Red
+	// the crosshair flashes when the main player damages someone
+	hitMarkerColor = colornames.White
+	hitMarkerTime  = 150 * time.Millisecond
 	// kill feed
 	killFeedLimit           = 32
 	killFeedLifeTime        = 10 * time.Second
@@ -71,6 +75,7 @@ type Hud struct {
 	armorTxt            *text.Text
 	ammoTxt             *text.Text
 	respawnCountdownTxt *text.Text
+	hitMarkerTime       time.Time
 }
 
 func NewHud(world common.World) common.Hud {
@@ -95,13 +100,34 @@ func NewHud(world common.World) common.Hud {
 
 func (h *Hud) AddKillFeedRow(killerPlayerID, victimPlayerID, weaponID string) {
 	h.killFeedRows = append(h.killFeedRows, &killFeedRow{
-		createTime:     ticktime.GetServerTime(),
+		createTime:     h.world.GetTime(),
 		killerPlayerID: killerPlayerID,
 		victimPlayerID: victimPlayerID,
 		weaponID:       weaponID,
 	})
 }
 
+// HandleGameEvent plays what the main player should notice wherever it
+// happened, like its kills and pickups.
+func (h *Hud) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayerID := h.world.GetMainPlayerID()
+	if mainPlayerID == "" || event.PlayerID != mainPlayerID {
+		return
+	}
+	switch event.Type {
+	case protocol.GameEventKilled:
+		if event.ObjectID != mainPlayerID {
+			sound.PlayCommonKill()
+		}
+	case protocol.GameEventPickedUp:
+		sound.PlayCommonPickup()
+	case protocol.GameEventDamaged:
+		if event.ObjectID != mainPlayerID {
+			h.hitMarkerTime = time.Now()
+		}
+	}
+}
+
 func (h *Hud) ClientUpdate() {
 	h.updateAmmo()
 	h.updateArmorHP()
@@ -171,7 +197,7 @@ func (h *Hud) updateAmmo() {
 func (h *Hud) updateRespawnCountdown() {
 	countdown := 0
 	if player := h.getPlayer(); player != nil {
-		now := ticktime.GetServerTime()
+		now := h.world.GetTime()
 		if d := player.GetRespawnTime().Sub(now); d > 0 {
 			countdown = int(math.Ceil(d.Seconds()))
 		}
@@ -182,7 +208,7 @@ func (h *Hud) updateRespawnCountdown() {
 
 func (h *Hud) updateKillFeed() {
 	rows := []*killFeedRow{}
-	now := ticktime.GetServerTime()
+	now := h.world.GetTime()
 	for _, r := range h.killFeedRows {
 		if now.Sub(r.createTime) <= killFeedLifeTime {
 			rows = append(rows, r)
@@ -263,6 +289,12 @@ func (h *Hud) renderItems(target pixel.Target) {
 			icon.Draw(target)
 		}
 	}
+	if item := items[2]; item != nil {
+		if icon := item.GetIcon(); icon != nil {
+			icon.Pos = hudThirdItem
+			icon.Draw(target)
+		}
+	}
 }
 
 func (h *Hud) renderHP(target pixel.Target) {
@@ -319,6 +351,9 @@ func (h *Hud) renderRespawnCountdown(target pixel.Target) {
 func (h *Hud) renderCursor(target pixel.Target) {
 	h.crosshair.Pos = h.world.GetWindow().MousePosition()
 	h.crosshair.Color = crosshairColor
+	if time.Since(h.hitMarkerTime) < hitMarkerTime {
+		h.crosshair.Color = hitMarkerColor
+	}
 	h.crosshair.Draw(target)
 }
 
@@ -332,14 +367,19 @@ func (h *Hud) renderKillFeed(target pixel.Target) {
 }
 
 func (h *Hud) renderFPS(target pixel.Target) {
-	fps := ticktime.GetFPS()
-	ping := ticktime.GetPing() / 1000000
+	fps := h.world.GetClock().GetFPS()
+	ping := h.world.GetClock().GetPing() / 1000000
+	lerp := h.world.GetClock().GetLerpPeriod() / 1000000
+	stats := fmt.Sprintf("PING:%d LERP:%d FPS:%d", ping, lerp, fps)
+	if extrapolation := h.world.GetClock().GetExtrapolation() / 1000000; extrapolation > 0 {
+		stats = fmt.Sprintf("EXTRAP:%d %s", extrapolation, stats)
+	}
 	win := h.world.GetWindow()
 	animation.DrawShadowTextRight(
 		h.gameStatsText,
 		target,
 		win.Bounds().Vertices()[2].Sub(hudGameStatsOffset),
-		fmt.Sprintf("PING:%d FPS:%d", ping, fps),
+		stats,
 		1,
 	)
 }
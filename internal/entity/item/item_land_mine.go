@@ -10,7 +10,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 )
 
@@ -48,7 +47,7 @@ func NewItemLandMine(world common.World, id string) *ItemLandMine {
 		id:         id,
 		world:      world,
 		pos:        util.GetHighVec(),
-		createTime: ticktime.GetServerTime(),
+		createTime: world.GetTime(),
 		effect:     animation.NewEffectExplosion(),
 	}
 }
@@ -68,7 +67,7 @@ func (o *ItemLandMine) Exists() bool {
 // set pos and reset
 func (o *ItemLandMine) SetPos(pos pixel.Vec) {
 	o.playerID = ""
-	o.createTime = ticktime.GetServerTime()
+	o.createTime = o.world.GetTime()
 	o.pos = pos
 }
 
@@ -111,7 +110,7 @@ func (o *ItemLandMine) GetSnapshot(tick int64) (ss *protocol.ObjectSnapshot) {
 }
 
 func (o *ItemLandMine) ServerUpdate(tick int64) {
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	if o.isAcitve && !o.isExploded {
 		isTriggered := false
 		players := []common.Player{}
@@ -139,9 +138,15 @@ func (o *ItemLandMine) ServerUpdate(tick int64) {
 			}
 			o.isExploded = true
 			o.deleteTime = now.Add(itemLandMineEffectTime + config.LerpPeriod*2)
+			o.world.AddGameEvent(&protocol.GameEvent{
+				Type:     protocol.GameEventExploded,
+				ObjectID: o.id,
+				PlayerID: o.playerID,
+				Pos:      util.ConvertVec(o.pos),
+			})
 		}
 	}
-	if (!ticktime.IsZeroTime(o.deleteTime) && now.Sub(o.deleteTime) > 0) ||
+	if (!o.world.GetClock().IsZeroTime(o.deleteTime) && now.Sub(o.deleteTime) > 0) ||
 		(now.Sub(o.createTime) > itemLifeTime && o.playerID == "" && !o.isAcitve) {
 		o.world.GetObjectDB().Delete(o.id)
 	}
@@ -155,19 +160,24 @@ func (o *ItemLandMine) ClientUpdate() {
 	o.playerID = ss.PlayerID
 	o.slotIndex = ss.SlotIndex
 	o.isAcitve = ss.IsActive
-	if !o.isExploded && ss.IsExploded {
-		o.isExploded = true
-		o.effect.Start()
-		if mainPlayer := o.world.GetMainPlayer(); mainPlayer != nil {
-			dist := o.world.GetMainPlayer().GetPivot().Sub(o.pos).Len()
-			sound.PlayItemExplosion(dist)
-		}
-	}
+	o.isExploded = ss.IsExploded
 	collider, _ := o.GetCollider()
 	o.isVisible = o.world.GetScope().Intersects(collider)
 	o.cleanTickSnapshots()
 }
 
+// HandleGameEvent starts the explosion where the mine is rendered
+func (o *ItemLandMine) HandleGameEvent(event *protocol.GameEvent) {
+	if event.Type != protocol.GameEventExploded {
+		return
+	}
+	o.effect.Start()
+	if mainPlayer := o.world.GetMainPlayer(); mainPlayer != nil {
+		dist := mainPlayer.GetPivot().Sub(o.pos).Len()
+		sound.PlayItemExplosion(dist)
+	}
+}
+
 func (o *ItemLandMine) UsedBy(p common.Player) (ok bool) {
 	if o.playerID == "" || o.isAcitve {
 		return false
@@ -204,8 +214,8 @@ func (o *ItemLandMine) cleanTickSnapshots() {
 	if len(o.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := o.world.GetTime().Add(-o.world.GetClock().GetLerpPeriod() * 2)
+	tick := o.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range o.tickSnapshots {
 		if ts.Tick >= tick {
@@ -256,13 +266,13 @@ func (o *ItemLandMine) render(target pixel.Target, viewPos pixel.Vec) {
 }
 
 func (o *ItemLandMine) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return o.getSnapshotsByTime(ticktime.GetLerpTime())
+	return o.getSnapshotsByTime(o.world.GetClock().GetLerpTime())
 }
 
 func (o *ItemLandMine) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	o.lock.RLock()
 	defer o.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, o.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(o.world.GetClock(), t, o.tickSnapshots)
 	if b == nil {
 		b = o.getCurrentSnapshot()
 	}
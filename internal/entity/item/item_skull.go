@@ -13,7 +13,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -134,7 +133,7 @@ func (o *ItemSkull) ServerUpdate(tick int64) {
 	if player := o.getPlayer(""); player != nil {
 		if player.IsAlive() {
 			if record, exists := o.getRecord(o.playerID); exists {
-				now := ticktime.GetServerTime()
+				now := o.world.GetTime()
 				if remainingTime := record.remainingTime - now.Sub(record.pickupTime); remainingTime <= 0 {
 					o.world.Destroy()
 				}
@@ -142,7 +141,7 @@ func (o *ItemSkull) ServerUpdate(tick int64) {
 			o.pos = player.GetPos().Sub(pixel.V(0, 1))
 		} else {
 			if record, exists := o.getRecord(o.playerID); exists {
-				now := ticktime.GetServerTime()
+				now := o.world.GetTime()
 				record.dropTime = now
 				record.remainingTime -= now.Sub(record.pickupTime)
 			}
@@ -155,7 +154,7 @@ func (o *ItemSkull) ServerUpdate(tick int64) {
 }
 
 func (o *ItemSkull) ClientUpdate() {
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	ss := o.getLastSnapshot().Item.Skull
 	oldPlayerID := o.playerID
 	o.playerID = ss.PlayerID
@@ -197,10 +196,10 @@ func (o *ItemSkull) UsedBy(player common.Player) (ok bool) {
 	record, exists := o.getRecord(o.playerID)
 	if !exists {
 		record = &itemSkullRecord{
-			remainingTime: config.DefaultWorldInitTime,
+			remainingTime: o.world.GetWorldConfig().InitTime.Duration,
 		}
 	}
-	record.pickupTime = ticktime.GetServerTime()
+	record.pickupTime = o.world.GetTime()
 	o.setRecord(o.playerID, record)
 	player.SetVisibleCause(o.GetID(), true)
 	return true
@@ -252,7 +251,7 @@ func (o *ItemSkull) GetIcon() *animation.Icon {
 func (o *ItemSkull) GetRemainingTimeMap() map[string]time.Duration {
 	o.recordLock.RLock()
 	defer o.recordLock.RUnlock()
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	remainingTimeMap := make(map[string]time.Duration)
 	for playerID, record := range o.recordMap {
 		remainingTime := record.remainingTime
@@ -273,8 +272,8 @@ func (o *ItemSkull) cleanTickSnapshots() {
 	if len(o.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := o.world.GetTime().Add(-o.world.GetClock().GetLerpPeriod() * 2)
+	tick := o.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range o.tickSnapshots {
 		if ts.Tick >= tick {
@@ -342,7 +341,7 @@ func (o *ItemSkull) renderIcon(target pixel.Target, viewPos pixel.Vec) {
 				pos = v.Sub(itemSkullIconOutScreenOffsets[i])
 			}
 		}
-		ratio := uint8((ticktime.GetServerTimeMS() / itemSkullBlinkDiv) % 256)
+		ratio := uint8((o.world.GetClock().GetServerTimeMS() / itemSkullBlinkDiv) % 256)
 		c = &color.RGBA{R: ratio, G: ratio, B: ratio, A: ratio}
 	} else if player := o.getPlayer(""); player == nil {
 		return
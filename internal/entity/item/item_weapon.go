@@ -9,7 +9,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 )
 
@@ -34,7 +33,7 @@ func NewItemWeapon(world common.World, id string, weaponID string) *ItemWeapon {
 		weaponID:   weaponID,
 		world:      world,
 		pos:        util.GetHighVec(),
-		createTime: ticktime.GetServerTime(),
+		createTime: world.GetTime(),
 	}
 }
 
@@ -95,7 +94,7 @@ func (o *ItemWeapon) GetSnapshot(tick int64) (ss *protocol.ObjectSnapshot) {
 func (o *ItemWeapon) ServerUpdate(tick int64) {
 	o.SetSnapshot(tick, o.getCurrentSnapshot())
 	o.cleanTickSnapshots()
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	if now.Sub(o.createTime) > itemLifeTime {
 		o.world.GetObjectDB().Delete(o.id)
 		o.world.GetObjectDB().Delete(o.weaponID)
@@ -144,8 +143,8 @@ func (o *ItemWeapon) cleanTickSnapshots() {
 	if len(o.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := o.world.GetTime().Add(-o.world.GetClock().GetLerpPeriod() * 2)
+	tick := o.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range o.tickSnapshots {
 		if ts.Tick >= tick {
@@ -178,13 +177,13 @@ func (o *ItemWeapon) render(target pixel.Target, viewPos pixel.Vec) {
 }
 
 func (o *ItemWeapon) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return o.getSnapshotsByTime(ticktime.GetLerpTime())
+	return o.getSnapshotsByTime(o.world.GetClock().GetLerpTime())
 }
 
 func (o *ItemWeapon) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	o.lock.RLock()
 	defer o.lock.RUnlock()
-	a, b, d := protocol.GetSnapshotByTime(t, o.tickSnapshots)
+	a, b, d := protocol.GetSnapshotByTime(o.world.GetClock(), t, o.tickSnapshots)
 	if a == nil || b == nil {
 		a = o.getCurrentSnapshot()
 		b = o.getCurrentSnapshot()
@@ -13,8 +13,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/entity/item"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 )
 
@@ -31,7 +29,6 @@ const (
 	playerMaxHP              = 100
 	playerInitArmor          = 0
 	playerMaxArmor           = 300
-	playerRespawnTime        = 3 * time.Second
 	playerMeleeTime          = 500 * time.Millisecond
 	playerHitHeightlightTime = 100 * time.Millisecond
 	playerVisibleTime        = 1000 * time.Millisecond
@@ -41,11 +38,14 @@ const (
 	playerRegenRate          = 5
 	playerSpeedCooldown      = 300 * time.Millisecond
 	playerMaxPosError        = 250
+	playerMaxMoveBudget      = 500 * time.Millisecond
+	playerMaxInputs          = 64
 	playerNameOffset         = 8
 	playerInvulnerableTime   = 3 * time.Second
 	playerDropInitArmor      = 30
 	playerDropArmorRate      = 10
 	playerItemSlotLen        = 2
+	playerMaxItemSlotLen     = 3
 	playerItemDropRadius     = 50
 )
 
@@ -56,7 +56,8 @@ type player struct {
 	playerSubfix       string
 	meleeWeaponID      string
 	weaponID           string
-	itemIDs            [playerItemSlotLen]string
+	itemIDs            [playerMaxItemSlotLen]string
+	itemSlotLen        int
 	playerNameTxt      *text.Text
 	tickSnapshots      []*protocol.TickSnapshot
 	visibleCauseMap    map[string]bool
@@ -77,25 +78,43 @@ type player struct {
 	triggerVisibleTime time.Duration
 	isDestroyed        bool
 	isMainPlayer       bool
+	isBot              bool
 	isMeleeing         bool
 	isDropping         bool
 	isTriggering       bool
 	isReloading        bool
 	isInvulnerable     bool
 	isVisible          bool
-	isUsingItems       [playerItemSlotLen]bool
+	isUsingItems       [playerMaxItemSlotLen]bool
 	hp                 float64
 	armor              float64
 	maxMoveSpeed       float64
 	moveSpeed          float64
 	moveDir            pixel.Vec
 	cursorDir          pixel.Vec
+	viewTime           time.Time
+	inputs             []*playerInput
+	lastInputSeq       int64
+	inputSeq           int64
+	inputTime          time.Time
+	moveBudget         time.Duration
+	predictSnapshot    *protocol.PlayerSnapshot
+	predictSeq         int64
+	predictPos         pixel.Vec
 	lock               sync.RWMutex
+	inputLock          sync.Mutex
 	visibleCauseLock   sync.RWMutex
 	colliderImd        *imdraw.IMDraw
 	shapeImd           *imdraw.IMDraw
 }
 
+type playerInput struct {
+	seq   int64
+	input *protocol.InputSnapshot
+	// server time the input was sampled, the move slows down by it
+	time time.Time
+}
+
 func NewPlayer(world common.World, id string) common.Player {
 	return &player{
 		id:              id,
@@ -103,14 +122,15 @@ func NewPlayer(world common.World, id string) common.Player {
 		playerNameTxt:   animation.NewText(),
 		pos:             util.GetHighVec(),
 		maxMoveSpeed:    playerBaseMoveSpeed,
-		updateTime:      ticktime.GetServerTime(),
+		updateTime:      world.GetTime(),
 		colliderImd:     imdraw.New(nil),
 		shapeImd:        imdraw.New(nil),
 		hp:              playerInitHP,
 		armor:           playerInitArmor,
-		respawnTime:     ticktime.GetServerTime(),
+		respawnTime:     world.GetTime(),
 		visibleCauseMap: make(map[string]bool),
 		isInvulnerable:  true,
+		itemSlotLen:     playerItemSlotLen,
 	}
 }
 
@@ -127,7 +147,7 @@ func (p *player) Exists() bool {
 }
 
 func (p *player) IsAlive() bool {
-	return ticktime.GetServerTime().After(p.respawnTime)
+	return p.world.GetTime().After(p.respawnTime)
 }
 
 func (p *player) IncreaseKill() {
@@ -194,16 +214,12 @@ func (p *player) SetSnapshot(tick int64, snapshot *protocol.ObjectSnapshot) {
 		Tick:     tick,
 		Snapshot: snapshot,
 	})
-	if p.isMainPlayer {
-		p.posError = p.pos.Sub(snapshot.Player.Pos.Convert())
-		p.errorTime = ticktime.GetServerTime()
-	}
 }
 
 func (p *player) ServerUpdate(tick int64) {
-	now := ticktime.GetServerTime()
-	if ticktime.IsZeroTime(p.pickupTime) {
-		p.pickupTime = ticktime.GetServerTime()
+	now := p.world.GetTime()
+	if p.world.GetClock().IsZeroTime(p.pickupTime) {
+		p.pickupTime = p.world.GetTime()
 	}
 	// Check respawn
 	preRespawnTime := p.respawnTime.Add(-config.LerpPeriod)
@@ -217,6 +233,11 @@ func (p *player) ServerUpdate(tick int64) {
 		(p.respawnTime.Before(p.meleeTime) && p.meleeTime.Sub(p.respawnTime) < playerInvulnerableTime) {
 		p.isInvulnerable = false
 	}
+	// Apply inputs
+	if p.moveBudget += now.Sub(p.updateTime); p.moveBudget > playerMaxMoveBudget {
+		p.moveBudget = playerMaxMoveBudget
+	}
+	p.applyInputs()
 	if p.IsAlive() {
 		// Check item
 		for _, o := range p.world.GetObjectDB().SelectAll() {
@@ -228,12 +249,14 @@ func (p *player) ServerUpdate(tick int64) {
 			case config.InstanceUsedItem:
 				if ok := item.UsedBy(p); ok {
 					p.pickupTime = now
+					p.addPickedUpEvent(item)
 				}
 			case config.CollectibleItem:
-				for i, itemID := range p.itemIDs {
+				for i, itemID := range p.itemIDs[:p.itemSlotLen] {
 					if itemID == "" && item.CollectedBy(p, i) {
 						p.itemIDs[i] = item.GetID()
 						p.pickupTime = now
+						p.addPickedUpEvent(item)
 						break
 					}
 				}
@@ -287,20 +310,8 @@ func (p *player) ServerUpdate(tick int64) {
 			p.triggerVisibleTime = playerVisibleTime
 		}
 		p.hitVisibleTime = playerVisibleTime
-		// Update position
-		moveSpeed := p.moveSpeed
-		if now.Sub(p.triggerTime) < playerSpeedCooldown ||
-			now.Sub(p.meleeTime) < playerSpeedCooldown {
-			moveSpeed /= 2
-		}
-		pos := p.pos
-		diff := now.Sub(p.updateTime).Seconds()
-		diffDist := p.moveDir.Unit().Scaled(moveSpeed * diff)
-		pos = pos.Add(diffDist)
-		// Check collision
-		_, _, dynamicAdjust := p.world.CheckCollision(p.id, p.getCollider(), p.getColliderByPos(pos))
-		p.pos = pos.Sub(dynamicAdjust)
 		// Update HP
+		diff := now.Sub(p.updateTime).Seconds()
 		if now.Sub(p.hitTime) > playerStartRegenTime {
 			if p.hp += diff * playerRegenRate; p.hp > playerMaxHP {
 				p.hp = playerMaxHP
@@ -314,46 +325,20 @@ func (p *player) ServerUpdate(tick int64) {
 }
 
 func (p *player) ClientUpdate() {
-	now := ticktime.GetServerTime()
+	now := p.world.GetTime()
 	if p.isMainPlayer {
 		// Set weapon
 		ss := p.getLastSnapshot().Player
 		p.meleeWeaponID = ss.MeleeWeaponID
 		p.weaponID = ss.WeaponID
+		p.maxMoveSpeed = ss.MaxMoveSpeed
 		// Update position
-		moveSpeed := p.moveSpeed
-		if now.Sub(p.triggerTime) < playerSpeedCooldown {
-			moveSpeed /= 2
-		}
-		pos := p.pos
-		diff := now.Sub(p.updateTime).Seconds()
-		diffDist := p.moveDir.Unit().Scaled(moveSpeed * diff)
-		pos = pos.Add(diffDist)
-		// Correct error
-		if ss.Pos.Convert().Sub(pos).Len() >= playerMaxPosError {
-			pos = ss.Pos.Convert()
-		} else {
-			ms := 1000.0
-			d := time.Duration(1.5*ms/config.ServerSyncRate) * time.Millisecond
-			if now.Sub(p.errorTime) <= d {
-				errorCorrectionDist := p.posError.Scaled(diff / d.Seconds())
-				pos = pos.Sub(errorCorrectionDist)
-			}
-		}
-		// Check collision
-		_, _, dynamicAdjust := p.world.CheckCollision(p.id, p.getCollider(), p.getColliderByPos(pos))
-		p.pos = pos.Sub(dynamicAdjust)
-		// Play sound
-		if p.streak < ss.Streak {
-			sound.PlayCommonKill()
-		}
-		if !ticktime.IsZeroTime(p.pickupTime) && !p.pickupTime.Equal(time.Unix(0, ss.PickupTime)) {
-			sound.PlayCommonPickup()
-		}
+		p.pos = p.predict(ss, now)
 		p.updateTime = now
 
 	} else {
-		ss := p.getLerpSnapshot().Player
+		t := p.world.GetClock().GetLerpTime()
+		ss := p.extrapolate(p.getSnapshotsByTime(t).Player, t)
 		// Set weapon
 		p.meleeWeaponID = ss.MeleeWeaponID
 		p.weaponID = ss.WeaponID
@@ -383,6 +368,7 @@ func (p *player) ClientUpdate() {
 	p.isInvulnerable = lastSS.IsInvulnerable
 	p.isVisible = lastSS.IsVisible
 	p.playerName = lastSS.PlayerName
+	p.isBot = lastSS.IsBot
 	// Update weapon
 	if weapon := p.GetWeapon(); weapon != nil {
 		weapon.SetPos(p.GetPivot())
@@ -407,8 +393,157 @@ func (p *player) SetInput(input *protocol.InputSnapshot) {
 	if input == nil || !p.IsAlive() {
 		return
 	}
-	var moveSpeed float64
-	moveDir := pixel.ZV
+	p.moveDir, p.moveSpeed = p.getMove(input)
+	p.cursorDir = input.CursorDir.Convert()
+	p.isDropping = input.Drop
+	p.isTriggering = input.Fire
+	p.isReloading = input.Reload
+	p.isMeleeing = input.Melee
+	p.isUsingItems[0] = input.Use1stItem
+	p.isUsingItems[1] = input.Use2ndItem
+	p.isUsingItems[2] = input.Use3rdItem
+	if input.ViewTime != 0 {
+		p.viewTime = time.Unix(0, input.ViewTime)
+	}
+}
+
+// AddInput queues an input by seq, duplicated and old inputs are ignored.
+// The server applies queued inputs in ServerUpdate, the main player keeps
+// them until the server acks them so they can be replayed.
+func (p *player) AddInput(seq int64, input *protocol.InputSnapshot) {
+	// Inputs come from clients, one without a cursor can't be applied
+	if input == nil || input.CursorDir == nil {
+		return
+	}
+	p.inputLock.Lock()
+	defer p.inputLock.Unlock()
+	if seq <= p.lastInputSeq {
+		return
+	}
+	p.lastInputSeq = seq
+	p.inputTime = p.world.GetTime()
+	// The client and the server move by the same time, it can't be later
+	// than the input arrived
+	t := p.inputTime
+	if sampleTime := time.Unix(0, input.Time); input.Time != 0 && sampleTime.Before(t) {
+		t = sampleTime
+	}
+	p.inputs = append(p.inputs, &playerInput{
+		seq:   seq,
+		input: input,
+		time:  t,
+	})
+	if len(p.inputs) > playerMaxInputs {
+		p.inputs = p.inputs[len(p.inputs)-playerMaxInputs:]
+	}
+}
+
+// applyInputs moves the player by each queued input for as long as it was
+// held on the client, the total can't go over the time which passed. Inputs
+// move at their sample time like predict does.
+func (p *player) applyInputs() {
+	p.inputLock.Lock()
+	inputs := p.inputs
+	p.inputs = nil
+	p.inputLock.Unlock()
+	if len(inputs) == 0 {
+		return
+	}
+	// Keep one-shot actions from all inputs
+	isDropping := false
+	isReloading := false
+	isUsingItems := [playerMaxItemSlotLen]bool{}
+	for _, in := range inputs {
+		p.SetInput(in.input)
+		isDropping = isDropping || p.isDropping
+		isReloading = isReloading || p.isReloading
+		for i := range isUsingItems {
+			isUsingItems[i] = isUsingItems[i] || p.isUsingItems[i]
+		}
+		d := time.Duration(in.input.Duration)
+		if d < 0 {
+			d = 0
+		} else if d > p.moveBudget {
+			d = p.moveBudget
+		}
+		p.moveBudget -= d
+		if p.IsAlive() {
+			p.pos = p.move(p.pos, p.moveDir, p.moveSpeed, d, in.time)
+		}
+		p.inputSeq = in.seq
+	}
+	p.isDropping = isDropping
+	p.isReloading = isReloading
+	p.isUsingItems = isUsingItems
+}
+
+// predict returns where the main player should be drawn, the server
+// position is moved by the inputs which the server has not applied yet.
+func (p *player) predict(ss *protocol.PlayerSnapshot, now time.Time) pixel.Vec {
+	p.inputLock.Lock()
+	for len(p.inputs) > 0 && p.inputs[0].seq <= ss.InputSeq {
+		p.inputs = p.inputs[1:]
+	}
+	inputs := p.inputs
+	inputTime := p.inputTime
+	p.inputLock.Unlock()
+	lastSeq := ss.InputSeq
+	if len(inputs) > 0 {
+		lastSeq = inputs[len(inputs)-1].seq
+	}
+	// Replay inputs
+	isChanged := ss != p.predictSnapshot || lastSeq != p.predictSeq
+	if isChanged {
+		pos := ss.Pos.Convert()
+		if p.IsAlive() {
+			for _, in := range inputs {
+				moveDir, moveSpeed := p.getMove(in.input)
+				pos = p.move(pos, moveDir, moveSpeed, time.Duration(in.input.Duration), in.time)
+			}
+		}
+		p.predictSnapshot = ss
+		p.predictSeq = lastSeq
+		p.predictPos = pos
+	}
+	// Move by the input which is not sampled yet
+	pos := p.predictPos
+	if p.IsAlive() {
+		d := now.Sub(inputTime)
+		if d > playerMaxMoveBudget {
+			d = playerMaxMoveBudget
+		}
+		pos = p.move(pos, p.moveDir, p.moveSpeed, d, now)
+	}
+	// Smooth out correction
+	if isChanged {
+		p.posError = pixel.ZV
+		if posError := p.pos.Sub(pos); posError.Len() < playerMaxPosError {
+			p.posError = posError
+		}
+		p.errorTime = now
+	}
+	ms := 1000.0
+	d := time.Duration(1.5*ms/config.ServerSyncRate) * time.Millisecond
+	if diff := now.Sub(p.errorTime); diff < d {
+		pos = pos.Add(p.posError.Scaled(1 - diff.Seconds()/d.Seconds()))
+	}
+	return pos
+}
+
+// move is shared by the server and the client replay so both end up at the
+// same position for the same inputs.
+func (p *player) move(pos, moveDir pixel.Vec, moveSpeed float64, d time.Duration, t time.Time) pixel.Vec {
+	if t.Sub(p.triggerTime) < playerSpeedCooldown ||
+		t.Sub(p.meleeTime) < playerSpeedCooldown {
+		moveSpeed /= 2
+	}
+	next := pos.Add(moveDir.Unit().Scaled(moveSpeed * d.Seconds()))
+	// Check collision
+	_, _, dynamicAdjust := p.world.CheckCollision(p.id, p.getColliderByPos(pos), p.getColliderByPos(next))
+	return next.Sub(dynamicAdjust)
+}
+
+func (p *player) getMove(input *protocol.InputSnapshot) (moveDir pixel.Vec, moveSpeed float64) {
 	if input.Up {
 		moveDir.Y = 1
 	} else if input.Down {
@@ -422,21 +557,48 @@ func (p *player) SetInput(input *protocol.InputSnapshot) {
 	if !moveDir.Eq(pixel.ZV) {
 		moveSpeed = p.maxMoveSpeed
 	}
-	p.moveSpeed = moveSpeed
-	p.moveDir = moveDir
-	p.cursorDir = input.CursorDir.Convert()
-	p.isDropping = input.Drop
-	p.isTriggering = input.Fire
-	p.isReloading = input.Reload
-	p.isMeleeing = input.Melee
-	p.isUsingItems[0] = input.Use1stItem
-	p.isUsingItems[1] = input.Use2ndItem
+	return moveDir, moveSpeed
+}
+
+// GetViewDelay returns how far in the past the player is seeing others,
+// it's capped so old inputs can't rewind too far.
+func (p *player) GetViewDelay() time.Duration {
+	if p.world.GetClock().IsZeroTime(p.viewTime) {
+		return 0
+	}
+	delay := p.world.GetTime().Sub(p.viewTime)
+	if delay < 0 {
+		return 0
+	}
+	if delay > config.MaxRewindPeriod {
+		return config.MaxRewindPeriod
+	}
+	return delay
+}
+
+func (p *player) GetShapeByTime(t time.Time) pixel.Rect {
+	ss := p.getSnapshotsByTime(t).Player
+	return p.getShapeByPos(ss.Pos.Convert())
 }
 
 func (p *player) SetMainPlayer() {
 	p.isMainPlayer = true
 }
 
+func (p *player) SetBot() {
+	p.isBot = true
+}
+
+func (p *player) IsBot() bool {
+	return p.isBot
+}
+
+// SetExtraItemSlots gives the player every item slot, only clients which
+// can show them get it.
+func (p *player) SetExtraItemSlots() {
+	p.itemSlotLen = playerMaxItemSlotLen
+}
+
 func (p *player) GetMeleeWeapon() common.Weapon {
 	if p.meleeWeaponID == "" {
 		return nil
@@ -481,6 +643,12 @@ func (p *player) AddDamage(firingPlayerID string, weaponID string, damage float6
 	if p.isInvulnerable {
 		return
 	}
+	p.world.AddGameEvent(&protocol.GameEvent{
+		Type:     protocol.GameEventDamaged,
+		ObjectID: p.id,
+		PlayerID: firingPlayerID,
+		Value:    damage,
+	})
 	if armor := p.armor; armor > 0 {
 		armor -= damage
 		if armor >= 0 {
@@ -492,7 +660,7 @@ func (p *player) AddDamage(firingPlayerID string, weaponID string, damage float6
 		p.armor = armor
 	}
 	p.hp -= damage
-	p.hitTime = ticktime.GetServerTime()
+	p.hitTime = p.world.GetTime()
 	if p.hp <= 0 {
 		p.Die(firingPlayerID, weaponID)
 	}
@@ -579,7 +747,7 @@ func (p *player) SetVisibleCause(id string, visible bool) {
 }
 
 func (p *player) IsVisible() bool {
-	now := ticktime.GetServerTime()
+	now := p.world.GetTime()
 	return !p.IsAlive() ||
 		now.Sub(p.hitTime) <= p.hitVisibleTime ||
 		now.Sub(p.triggerTime) <= p.triggerVisibleTime ||
@@ -645,7 +813,7 @@ func (p *player) renderPlayerName(target pixel.Target, viewPos pixel.Vec) {
 }
 
 func (p *player) render(target pixel.Target, viewPos pixel.Vec) {
-	now := ticktime.GetServerTime()
+	now := p.world.GetTime()
 	pos := p.GetShape().Center()
 	base := pos.Sub(viewPos)
 	anim := animation.NewCharacter()
@@ -661,7 +829,7 @@ func (p *player) render(target pixel.Target, viewPos pixel.Vec) {
 	}
 	anim.Invulnerable = p.isInvulnerable
 	anim.Shadow = true
-	anim.DieTime = p.respawnTime.Add(-playerRespawnTime)
+	anim.DieTime = p.respawnTime.Add(-p.world.GetWorldConfig().RespawnTime.Duration)
 	if p.respawnTime.After(now) {
 		anim.State = animation.CharacterDieState
 	} else if p.moveSpeed == 0 {
@@ -759,19 +927,55 @@ func (p *player) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (p *player) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return p.getSnapshotsByTime(ticktime.GetLerpTime())
+	return p.getSnapshotsByTime(p.world.GetClock().GetLerpTime())
+}
+
+// extrapolate moves a remote player on by its last move when the lerp time
+// is past its last snapshot, so it doesn't freeze while snapshots are late.
+// It's capped as the guess gets wrong quickly.
+func (p *player) extrapolate(ss *protocol.PlayerSnapshot, t time.Time) *protocol.PlayerSnapshot {
+	lastTime, exists := p.getLastSnapshotTime()
+	if !exists || ss.IsHidden {
+		return ss
+	}
+	d := t.Sub(lastTime)
+	if d <= 0 {
+		return ss
+	}
+	if d > config.MaxExtrapolation {
+		d = config.MaxExtrapolation
+	}
+	p.world.GetClock().SetExtrapolation(d)
+	if ss.MoveSpeed > 0 {
+		pos := p.move(ss.Pos.Convert(), ss.MoveDir.Convert(), ss.MoveSpeed, d, t)
+		ss.Pos = util.ConvertVec(pos)
+	}
+	return ss
+}
+
+func (p *player) getLastSnapshotTime() (t time.Time, exists bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if len(p.tickSnapshots) == 0 {
+		return t, false
+	}
+	return p.world.GetClock().GetTickTime(p.tickSnapshots[len(p.tickSnapshots)-1].Tick), true
 }
 
 func (p *player) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	a, b, d := protocol.GetSnapshotByTime(t, p.tickSnapshots)
+	a, b, d := protocol.GetSnapshotByTime(p.world.GetClock(), t, p.tickSnapshots)
 	if a == nil || b == nil {
 		a = p.getCurrentSnapshot()
 		b = p.getCurrentSnapshot()
 	}
 	ssA := a.Player
 	ssB := b.Player
+	// Don't slide from or to the hidden position
+	if ssA.IsHidden || ssB.IsHidden {
+		ssA = ssB
+	}
 	return &protocol.ObjectSnapshot{
 		ID:   p.id,
 		Type: config.PlayerObject,
@@ -800,6 +1004,9 @@ func (p *player) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 			TriggerVisibleMS: ssB.TriggerVisibleMS,
 			IsInvulnerable:   ssB.IsInvulnerable,
 			IsVisible:        ssB.IsVisible,
+			IsHidden:         ssB.IsHidden,
+			InputSeq:         ssB.InputSeq,
+			IsBot:            ssB.IsBot,
 		},
 	}
 }
@@ -810,8 +1017,9 @@ func (p *player) cleanTickSnapshots() {
 	if len(p.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	// Keep enough for rewinding hitboxes
+	t := p.world.GetTime().Add(-p.world.GetClock().GetLerpPeriod() - config.MaxRewindPeriod)
+	tick := p.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range p.tickSnapshots {
 		if ts.Tick >= tick {
@@ -826,21 +1034,26 @@ func (p *player) cleanTickSnapshots() {
 
 func (p *player) getItemIDs() []string {
 	itemIDs := []string{}
-	for _, itemID := range p.itemIDs {
+	for _, itemID := range p.itemIDs[:p.itemSlotLen] {
 		itemIDs = append(itemIDs, itemID)
 	}
 	return itemIDs
 }
 
 func (p *player) setItemIDs(itemIDs []string) {
-	itemIDArray := [playerItemSlotLen]string{}
-	for i := 0; i < playerItemSlotLen; i++ {
+	itemIDArray := [playerMaxItemSlotLen]string{}
+	itemSlotLen := playerItemSlotLen
+	for i := 0; i < playerMaxItemSlotLen; i++ {
 		if len(itemIDs) <= i {
 			break
 		}
 		itemIDArray[i] = itemIDs[i]
+		if i >= itemSlotLen {
+			itemSlotLen = i + 1
+		}
 	}
 	p.itemIDs = itemIDArray
+	p.itemSlotLen = itemSlotLen
 }
 
 func (p *player) getCurrentSnapshot() *protocol.ObjectSnapshot {
@@ -872,18 +1085,26 @@ func (p *player) getCurrentSnapshot() *protocol.ObjectSnapshot {
 			TriggerVisibleMS: int(p.triggerVisibleTime.Seconds() * 1000),
 			IsInvulnerable:   p.isInvulnerable,
 			IsVisible:        p.isVisible,
+			InputSeq:         p.inputSeq,
+			IsBot:            p.isBot,
 		},
 	}
 }
 
 func (p *player) Die(firingPlayerID string, weaponID string) {
+	p.world.AddGameEvent(&protocol.GameEvent{
+		Type:     protocol.GameEventKilled,
+		ObjectID: p.id,
+		PlayerID: firingPlayerID,
+		Pos:      util.ConvertVec(p.GetPivot()),
+	})
 	streak := p.streak
 	// Set status
 	p.death++
 	p.streak = 0
 	p.hp = playerInitHP
 	p.armor = playerInitArmor
-	p.respawnTime = ticktime.GetServerTime().Add(playerRespawnTime)
+	p.respawnTime = p.world.GetTime().Add(p.world.GetWorldConfig().RespawnTime.Duration)
 	// Drop armor
 	armor := float64(streak*playerDropArmorRate + playerDropInitArmor)
 	itemID := p.world.GetObjectDB().GetAvailableID()
@@ -915,6 +1136,14 @@ func (p *player) Die(firingPlayerID string, weaponID string) {
 	}
 }
 
+func (p *player) addPickedUpEvent(item common.Item) {
+	p.world.AddGameEvent(&protocol.GameEvent{
+		Type:     protocol.GameEventPickedUp,
+		ObjectID: item.GetID(),
+		PlayerID: p.id,
+	})
+}
+
 func (p *player) getRandomNearPos() pixel.Vec {
 	rect := pixel.R(
 		p.pos.X-playerItemDropRadius,
@@ -922,5 +1151,5 @@ func (p *player) getRandomNearPos() pixel.Vec {
 		p.pos.X+playerItemDropRadius,
 		p.pos.Y+playerItemDropRadius,
 	)
-	return util.RandomVec(rect)
+	return util.RandomVec(p.world.GetRand(), rect)
 }
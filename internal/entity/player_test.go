@@ -0,0 +1,66 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/entity"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/world"
+)
+
+// The server applies inputs late, they must still end where the client
+// predicted them when the melee slowed the player down.
+func TestPredictMatchesServerInputs(t *testing.T) {
+	cfg := config.NewWorldConfig()
+	cfg.Seed = 1
+	clock := ticktime.NewClock()
+	clock.SetServerStartTime(time.Unix(1600000000, 0))
+	w := world.NewDefaultWorld(nil, clock, "world-1", cfg)
+	playerID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(playerID, "player")
+	o, _ := w.GetObjectDB().SelectOne(playerID)
+	p := o.(common.Player)
+	w.ServerUpdate(0)
+	// Away from the field so nothing is in the way
+	p.SetPos(pixel.V(-5000, -5000))
+	w.ServerUpdate(1)
+	p.AddInput(1, &protocol.InputSnapshot{
+		CursorDir: util.V(1, 0),
+		Melee:     true,
+		Time:      clock.GetTickTime(2).UnixNano(),
+	})
+	w.ServerUpdate(2)
+	// The inputs are sampled and arrive during the slow down, the server
+	// applies them after it is over
+	const arriveTick = 10
+	for tick := int64(3); tick <= arriveTick; tick++ {
+		w.ServerUpdate(tick)
+	}
+	ss := p.GetSnapshot(arriveTick).Player
+	start := ss.Pos.Convert()
+	const inputCount = arriveTick - 2
+	for i := int64(0); i < inputCount; i++ {
+		p.AddInput(2+i, &protocol.InputSnapshot{
+			CursorDir: util.V(1, 0),
+			Right:     true,
+			Duration:  config.Timestep.Nanoseconds(),
+			Time:      clock.GetTickTime(3 + i).UnixNano(),
+		})
+	}
+	predicted := entity.PredictPos(p, ss)
+	const applyTick = 40
+	w.ServerUpdate(applyTick)
+	if got := p.GetPos(); got.Sub(predicted).Len() > 1e-9 {
+		t.Fatalf("server moved to %v, client predicted %v", got, predicted)
+	}
+	fullSpeed := p.GetSnapshot(applyTick).Player.MaxMoveSpeed
+	if d := p.GetPos().Sub(start).Len(); d == 0 || d >= fullSpeed*(inputCount*config.Timestep).Seconds() {
+		t.Fatalf("moved %v, the melee didn't slow down", d)
+	}
+}
@@ -25,6 +25,7 @@ var (
 	defaultScoreboardLineHeight       = 16.
 	defaultScoreboardLimit            = 10
 	defaultScoreboardPlayerNameLength = 10
+	defaultScoreboardBotTag           = "[BOT]"
 	defaultScoreboardBGColor          = color.RGBA{0, 0, 0, 127}
 )
 
@@ -78,18 +79,19 @@ func (s *DefaultScoreboard) ClientUpdate() {
 		}
 	}
 	remainingTimeMap := s.getRemainingTimeMap()
+	initTime := s.world.GetWorldConfig().InitTime.Duration
 	sort.Slice(players, func(i, j int) bool {
 		// i
 		iName := players[i].GetPlayerName()
 		iRemainingTime, exists := remainingTimeMap[players[i].GetID()]
 		if !exists {
-			iRemainingTime = config.DefaultWorldInitTime
+			iRemainingTime = initTime
 		}
 		// j
 		jName := players[j].GetPlayerName()
 		jRemainingTime, exists := remainingTimeMap[players[j].GetID()]
 		if !exists {
-			jRemainingTime = config.DefaultWorldInitTime
+			jRemainingTime = initTime
 		}
 		// compare
 		if iRemainingTime != jRemainingTime {
@@ -126,6 +128,7 @@ func (s *DefaultScoreboard) getScoreboard() (players []common.Player, mainPlayer
 
 func (s *DefaultScoreboard) renderScoreboard(target pixel.Target) {
 	remainingTimeMap := s.getRemainingTimeMap()
+	initTime := s.world.GetWorldConfig().InitTime.Duration
 	win := s.world.GetWindow()
 	players, mainPlayer, mainPlayerPlace := s.getScoreboard()
 	{
@@ -164,14 +167,11 @@ func (s *DefaultScoreboard) renderScoreboard(target pixel.Target) {
 			-defaultScoreboardPadding-defaultScoreboardMarginTop,
 		))
 		pos = pos.Add(pixel.V(0, -float64(i+2)*defaultScoreboardLineHeight))
-		playerName := player.GetPlayerName()
-		if len(playerName) > defaultScoreboardPlayerNameLength {
-			playerName = playerName[:defaultScoreboardPlayerNameLength] + "..."
-		}
+		playerName := getPlayerName(player)
 		animation.DrawShadowTextLeft(s.scoreboardNameTxts[i+1], target, pos, fmt.Sprintf("%d. %s", i+1, playerName), 1)
 		remainingTime, exists := remainingTimeMap[player.GetID()]
 		if !exists {
-			remainingTime = config.DefaultWorldInitTime
+			remainingTime = initTime
 		}
 		t := int(math.Ceil(remainingTime.Seconds()))
 		pos = pos.Add(pixel.V(defaultScoreboardWidth, 0))
@@ -185,17 +185,25 @@ func (s *DefaultScoreboard) renderScoreboard(target pixel.Target) {
 			-defaultScoreboardPadding-defaultScoreboardMarginTop,
 		))
 		pos = pos.Add(pixel.V(0, -float64(playerLen+2)*defaultScoreboardLineHeight))
-		playerName := mainPlayer.GetPlayerName()
-		if len(playerName) > defaultScoreboardPlayerNameLength {
-			playerName = playerName[:defaultScoreboardPlayerNameLength] + "..."
-		}
+		playerName := getPlayerName(mainPlayer)
 		animation.DrawShadowTextLeft(s.scoreboardNameTxts[defaultScoreboardLimit+1], target, pos, fmt.Sprintf("%d. %s", mainPlayerPlace, playerName), 1)
 		remainingTime, exists := remainingTimeMap[mainPlayer.GetID()]
 		if !exists {
-			remainingTime = config.DefaultWorldInitTime
+			remainingTime = initTime
 		}
 		t := int(math.Ceil(remainingTime.Seconds()))
 		pos = pos.Add(pixel.V(defaultScoreboardWidth, 0))
 		animation.DrawShadowTextRight(s.scoreboardScoreTxts[defaultScoreboardLimit+1], target, pos, fmt.Sprint(t), 1)
 	}
 }
+
+func getPlayerName(player common.Player) string {
+	playerName := player.GetPlayerName()
+	if len(playerName) > defaultScoreboardPlayerNameLength {
+		playerName = playerName[:defaultScoreboardPlayerNameLength] + "..."
+	}
+	if player.IsBot() {
+		playerName = defaultScoreboardBotTag + " " + playerName
+	}
+	return playerName
+}
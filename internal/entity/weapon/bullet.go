@@ -9,7 +9,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -40,6 +39,7 @@ type Bullet struct {
 	length     float64
 	fireTime   time.Time
 	deleteTime time.Time
+	viewDelay  time.Duration
 	// calculated fields
 	pos         pixel.Vec
 	prevPos     pixel.Vec
@@ -130,15 +130,21 @@ func (o *Bullet) SetSnapshot(tick int64, snapshot *protocol.ObjectSnapshot) {
 }
 
 func (o *Bullet) ServerUpdate(tick int64) {
-	now := ticktime.GetServerTime()
-	if !ticktime.IsZeroTime(o.deleteTime) {
+	now := o.world.GetTime()
+	if !o.world.GetClock().IsZeroTime(o.deleteTime) {
 		if now.Sub(o.deleteTime) > config.LerpPeriod*2 {
 			o.world.GetObjectDB().Delete(o.id)
 		}
 	} else {
-		o.calculatePosByTime(ticktime.GetServerTime())
+		o.calculatePosByTime(o.world.GetTime())
 		if obj := o.checkObjectCollision(); obj != nil && obj.GetID() != o.playerID {
 			o.deleteTime = now
+			o.world.AddGameEvent(&protocol.GameEvent{
+				Type:     protocol.GameEventHit,
+				ObjectID: o.id,
+				PlayerID: o.playerID,
+				Pos:      util.ConvertVec(o.pos),
+			})
 			if obj.GetType() == config.PlayerObject {
 				player := obj.(common.Player)
 				player.AddDamage(o.playerID, o.weaponID, o.damage)
@@ -150,17 +156,17 @@ func (o *Bullet) ServerUpdate(tick int64) {
 func (o *Bullet) ClientUpdate() {
 	isDestroyed := o.isDestroyed
 	if o.playerID == o.world.GetMainPlayerID() {
-		if !ticktime.IsZeroTime(o.deleteTime) {
+		if !o.world.GetClock().IsZeroTime(o.deleteTime) {
 			isDestroyed = true
 		} else {
-			o.calculatePosByTime(ticktime.GetServerTime())
+			o.calculatePosByTime(o.world.GetTime())
 		}
-	} else if ticktime.GetLerpTime().After(o.fireTime) {
-		if !ticktime.IsZeroTime(o.deleteTime) &&
-			ticktime.GetLerpTime().After(o.deleteTime) {
+	} else if o.world.GetClock().GetLerpTime().After(o.fireTime) {
+		if !o.world.GetClock().IsZeroTime(o.deleteTime) &&
+			o.world.GetClock().GetLerpTime().After(o.deleteTime) {
 			isDestroyed = true
 		} else {
-			o.calculatePosByTime(ticktime.GetLerpTime())
+			o.calculatePosByTime(o.world.GetClock().GetLerpTime())
 			isDestroyed = false
 		}
 	} else {
@@ -178,7 +184,8 @@ func (o *Bullet) Fire(playerID, weaponID string, initPos, dir pixel.Vec, speed,
 	o.maxRange = maxRange
 	o.damage = damage
 	o.length = length
-	o.fireTime = ticktime.GetServerTime()
+	o.fireTime = o.world.GetTime()
+	o.viewDelay = getViewDelay(o.world, playerID)
 	o.isDestroyed = false
 }
 
@@ -223,7 +230,7 @@ func (o *Bullet) calculatePosByTime(t time.Time) {
 	diff := t.Sub(o.fireTime)
 	dist := o.speed * diff.Seconds()
 	if dist > o.maxRange {
-		o.deleteTime = ticktime.GetServerTime()
+		o.deleteTime = o.world.GetTime()
 	} else {
 		o.pos = o.initPos.Add(o.dir.Unit().Scaled(dist))
 	}
@@ -233,22 +240,27 @@ func (o *Bullet) calculatePosByTime(t time.Time) {
 func (o *Bullet) checkObjectCollision() common.Object {
 	prevCollider := o.getColliderByPos(o.prevPos)
 	currCollider := o.getColliderByPos(o.pos)
+	// Players are where the shooter saw them
+	viewTime := o.world.GetTime().Add(-o.viewDelay)
 	for _, obj := range o.world.GetObjectDB().SelectAll() {
 		if !obj.Exists() || obj.GetID() == o.id {
 			continue
 		}
 		if obj.GetType() == config.PlayerObject {
-			if player := obj.(common.Player); !player.IsAlive() {
+			player := obj.(common.Player)
+			if !player.IsAlive() {
 				continue
 			}
 			staticAdjust, _ := util.CheckCollision(
-				obj.GetShape(),
+				player.GetShapeByTime(viewTime),
 				prevCollider,
 				currCollider,
 			)
 			if staticAdjust.Len() > 0 {
 				return obj
 			}
+			// Not where they are now, that would undo the rewind
+			continue
 		}
 		if collider, exists := obj.GetCollider(); exists {
 			staticAdjust, _ := util.CheckCollision(
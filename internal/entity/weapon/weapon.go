@@ -1,9 +1,10 @@
 package weapon
 
 import (
-	"math/rand"
+	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 )
 
@@ -53,7 +54,7 @@ func Random(world common.World, id string) common.Weapon {
 	for _, dropRate := range dropRates {
 		totalDropRate += dropRate
 	}
-	n := int(rand.Uint32()) % totalDropRate
+	n := int(world.GetRand().Uint32()) % totalDropRate
 	lower := 0
 	for i, fn := range newFnList {
 		upper := lower + dropRates[i]
@@ -64,3 +65,11 @@ func Random(world common.World, id string) common.Weapon {
 	}
 	return NewWeaponPistol(world, id)
 }
+
+// getViewDelay returns how far to rewind other players for hits made by the player
+func getViewDelay(world common.World, playerID string) time.Duration {
+	if o, exists := world.GetObjectDB().SelectOne(playerID); exists && o.GetType() == config.PlayerObject {
+		return o.(common.Player).GetViewDelay()
+	}
+	return 0
+}
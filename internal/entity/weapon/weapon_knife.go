@@ -11,7 +11,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 )
 
@@ -121,6 +120,8 @@ func (o *WeaponKnife) SetSnapshot(tick int64, snapshot *protocol.ObjectSnapshot)
 }
 
 func (o *WeaponKnife) checkPlayerCollision() common.Player {
+	// Players are where the holder saw them
+	viewTime := o.world.GetTime().Add(-getViewDelay(o.world, o.playerID))
 	for _, obj := range o.world.GetObjectDB().SelectAll() {
 		if !obj.Exists() || obj.GetID() == o.GetID() {
 			continue
@@ -130,7 +131,7 @@ func (o *WeaponKnife) checkPlayerCollision() common.Player {
 			if !player.IsAlive() || player.GetID() == o.playerID {
 				continue
 			}
-			if player.GetShape().Intersects(o.GetShape()) {
+			if player.GetShapeByTime(viewTime).Intersects(o.GetShape()) {
 				return player
 			}
 		}
@@ -143,6 +144,12 @@ func (o *WeaponKnife) ServerUpdate(tick int64) {
 	if o.radius <= knifeTriggerMinRange {
 		o.isHit = false
 	} else if player := o.checkPlayerCollision(); !o.isHit && player != nil {
+		o.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventHit,
+			ObjectID: o.id,
+			PlayerID: o.playerID,
+			Pos:      util.ConvertVec(player.GetPivot()),
+		})
 		player.AddDamage(o.playerID, o.GetID(), knifeDamage)
 		o.isHit = true
 	}
@@ -161,17 +168,20 @@ func (o *WeaponKnife) ClientUpdate() {
 	}
 	o.playerID = ss.PlayerID
 	o.triggerTime = time.Unix(0, ss.TriggerTime)
-	radius := o.radius
 	o.updateRadius()
-	if mainPlayer := o.world.GetMainPlayer(); mainPlayer != nil {
-		dist := o.world.GetMainPlayer().GetPivot().Sub(o.pos).Len()
-		if radius < o.radius && radius == knifeTriggerMinRange {
-			sound.PlayWeaponKnifeStab(dist)
-		}
-	}
 	o.cleanTickSnapshots()
 }
 
+// HandleGameEvent plays the stab where the knife is rendered
+func (o *WeaponKnife) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayer := o.world.GetMainPlayer()
+	if mainPlayer == nil || event.Type != protocol.GameEventFired {
+		return
+	}
+	dist := mainPlayer.GetPivot().Sub(o.pos).Len()
+	sound.PlayWeaponKnifeStab(dist)
+}
+
 func (o *WeaponKnife) getLastSnapshot() *protocol.ObjectSnapshot {
 	o.lock.RLock()
 	defer o.lock.RUnlock()
@@ -182,13 +192,13 @@ func (o *WeaponKnife) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (o *WeaponKnife) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return o.getSnapshotsByTime(ticktime.GetLerpTime())
+	return o.getSnapshotsByTime(o.world.GetClock().GetLerpTime())
 }
 
 func (o *WeaponKnife) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	o.lock.RLock()
 	defer o.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, o.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(o.world.GetClock(), t, o.tickSnapshots)
 	if b == nil {
 		b = o.getCurrentSnapshot()
 	}
@@ -207,7 +217,7 @@ func (o *WeaponKnife) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 
 func (o *WeaponKnife) updateRadius() {
 	radius := 0.0
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	rng := float64(knifeTriggerMaxRange - knifeTriggerMinRange)
 	diff := float64(now.Sub(o.triggerTime))
 	cooldown := float64(knifeTriggerCooldown)
@@ -226,8 +236,8 @@ func (o *WeaponKnife) cleanTickSnapshots() {
 	if len(o.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := o.world.GetTime().Add(-o.world.GetClock().GetLerpPeriod() * 2)
+	tick := o.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range o.tickSnapshots {
 		if ts.Tick >= tick {
@@ -285,9 +295,14 @@ func (o *WeaponKnife) GetAmmo() (mag, ammo int) {
 }
 
 func (o *WeaponKnife) Trigger() bool {
-	now := ticktime.GetServerTime()
+	now := o.world.GetTime()
 	if now.Sub(o.triggerTime) > knifeTriggerCooldown {
 		o.triggerTime = now
+		o.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventFired,
+			ObjectID: o.id,
+			PlayerID: o.playerID,
+		})
 		return true
 	}
 	return false
@@ -2,7 +2,6 @@ package weapon
 
 import (
 	"math"
-	"math/rand"
 	"sync"
 	"time"
 
@@ -13,7 +12,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -100,9 +98,9 @@ func (m *WeaponPistol) GetRenderObjects() []common.RenderObject {
 }
 
 func (m *WeaponPistol) Render(target pixel.Target, viewPos pixel.Vec) {
-	now := ticktime.GetLerpTime()
+	now := m.world.GetClock().GetLerpTime()
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 	}
 	anim := animation.NewWeaponPistol()
 	anim.Pos = m.pos.Sub(viewPos)
@@ -147,17 +145,17 @@ func (m *WeaponPistol) renderDir(target pixel.Target, viewPos pixel.Vec) { // Fo
 }
 
 func (m *WeaponPistol) ServerUpdate(tick int64) {
-	if ticktime.IsZeroTime(m.triggerTime) {
-		m.triggerTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.triggerTime) {
+		m.triggerTime = m.world.GetTime()
 	}
-	if ticktime.IsZeroTime(m.reloadTime) {
-		m.reloadTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.reloadTime) {
+		m.reloadTime = m.world.GetTime()
 	}
 	if m.playerID == "" {
 		m.isReloading = false
-		m.reloadTime = ticktime.GetServerStartTime()
+		m.reloadTime = m.world.GetClock().GetServerStartTime()
 	} else {
-		now := ticktime.GetServerTime()
+		now := m.world.GetTime()
 		m.isTriggering = now.Sub(m.triggerTime) < pistolTriggerCooldown
 		isReloading := now.Sub(m.reloadTime) < pistolReloadCooldown
 		if !isReloading && m.isReloading {
@@ -175,16 +173,14 @@ func (m *WeaponPistol) ClientUpdate() {
 	var now time.Time
 	var ss *protocol.WeaponPistolSnapshot
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 		snapshot := m.getLastSnapshot()
 		ss = snapshot.Weapon.Pistol
 	} else {
-		now = ticktime.GetLerpTime()
+		now = m.world.GetClock().GetLerpTime()
 		snapshot := m.getLerpSnapshot()
 		ss = snapshot.Weapon.Pistol
 	}
-	prevTriggerTime := m.triggerTime
-	prevReloadTime := m.reloadTime
 	m.playerID = ss.PlayerID
 	m.mag = ss.Mag
 	m.ammo = ss.Ammo
@@ -192,19 +188,25 @@ func (m *WeaponPistol) ClientUpdate() {
 	m.reloadTime = time.Unix(0, ss.ReloadTime)
 	m.isTriggering = now.Sub(m.triggerTime) < pistolTriggerCooldown
 	m.isReloading = now.Sub(m.reloadTime) < pistolReloadCooldown
-	if mainPlayer := m.world.GetMainPlayer(); mainPlayer != nil {
-		dist := m.world.GetMainPlayer().GetPivot().Sub(m.pos).Len()
-		if !ticktime.IsZeroTime(prevTriggerTime) && prevTriggerTime.Before(m.triggerTime) {
-			sound.PlayWeaponPistolFire(dist)
-		}
-		if !ticktime.IsZeroTime(prevReloadTime) && prevReloadTime.Before(m.reloadTime) {
-			sound.PlayWeaponPistolReload(dist)
-		}
-	}
 	// Clean snapshot
 	m.cleanTickSnapshots()
 }
 
+// HandleGameEvent plays the sounds of the weapon where it's rendered
+func (m *WeaponPistol) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayer := m.world.GetMainPlayer()
+	if mainPlayer == nil {
+		return
+	}
+	dist := mainPlayer.GetPivot().Sub(m.pos).Len()
+	switch event.Type {
+	case protocol.GameEventFired:
+		sound.PlayWeaponPistolFire(dist)
+	case protocol.GameEventReloaded:
+		sound.PlayWeaponPistolReload(dist)
+	}
+}
+
 func (m *WeaponPistol) GetSnapshot(tick int64) (snapshot *protocol.ObjectSnapshot) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -235,7 +237,7 @@ func (m *WeaponPistol) Trigger() (ok bool) {
 	ok = false
 	if !m.isTriggering && m.mag > 0 && !m.isReloading {
 		bullet := NewBullet(m.world, m.world.GetObjectDB().GetAvailableID())
-		recoilAngle := rand.Float64()*pistolRecoilAngle - pistolRecoilAngle/2
+		recoilAngle := m.world.GetRand().Float64()*pistolRecoilAngle - pistolRecoilAngle/2
 		dir := m.dir.Rotated(recoilAngle)
 		bullet.Fire(
 			m.playerID,
@@ -248,9 +250,14 @@ func (m *WeaponPistol) Trigger() (ok bool) {
 			pistolBulletLength,
 		)
 		m.world.GetObjectDB().Set(bullet)
-		m.triggerTime = ticktime.GetServerTime()
+		m.triggerTime = m.world.GetTime()
 		m.mag--
 		ok = true
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventFired,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 	}
 	if !m.isReloading && m.mag == 0 {
 		_ = m.Reload()
@@ -260,7 +267,12 @@ func (m *WeaponPistol) Trigger() (ok bool) {
 
 func (m *WeaponPistol) Reload() bool {
 	if !m.isReloading && m.mag < pistolMag && m.ammo > 0 {
-		m.reloadTime = ticktime.GetServerTime()
+		m.reloadTime = m.world.GetTime()
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventReloaded,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 		return true
 	}
 	return false
@@ -268,7 +280,7 @@ func (m *WeaponPistol) Reload() bool {
 
 func (m *WeaponPistol) StopReloading() {
 	m.isReloading = false
-	m.reloadTime = ticktime.GetServerStartTime()
+	m.reloadTime = m.world.GetClock().GetServerStartTime()
 }
 
 func (m *WeaponPistol) SetPlayerID(playerID string) {
@@ -330,13 +342,13 @@ func (m *WeaponPistol) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (m *WeaponPistol) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return m.getSnapshotsByTime(ticktime.GetLerpTime())
+	return m.getSnapshotsByTime(m.world.GetClock().GetLerpTime())
 }
 
 func (m *WeaponPistol) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, m.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(m.world.GetClock(), t, m.tickSnapshots)
 	if b == nil {
 		b = m.getCurrentSnapshot()
 	}
@@ -378,8 +390,8 @@ func (m *WeaponPistol) cleanTickSnapshots() {
 	if len(m.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := m.world.GetTime().Add(-m.world.GetClock().GetLerpPeriod() * 2)
+	tick := m.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range m.tickSnapshots {
 		if ts.Tick >= tick {
@@ -2,7 +2,6 @@ package weapon
 
 import (
 	"math"
-	"math/rand"
 	"sync"
 	"time"
 
@@ -13,7 +12,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -101,9 +99,9 @@ func (m *WeaponShotgun) GetRenderObjects() []common.RenderObject {
 }
 
 func (m *WeaponShotgun) Render(target pixel.Target, viewPos pixel.Vec) {
-	now := ticktime.GetLerpTime()
+	now := m.world.GetClock().GetLerpTime()
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 	}
 	anim := animation.NewWeaponShotgun()
 	anim.Pos = m.pos.Sub(viewPos)
@@ -148,17 +146,17 @@ func (m *WeaponShotgun) renderDir(target pixel.Target, viewPos pixel.Vec) { // F
 }
 
 func (m *WeaponShotgun) ServerUpdate(tick int64) {
-	if ticktime.IsZeroTime(m.triggerTime) {
-		m.triggerTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.triggerTime) {
+		m.triggerTime = m.world.GetTime()
 	}
-	if ticktime.IsZeroTime(m.reloadTime) {
-		m.reloadTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.reloadTime) {
+		m.reloadTime = m.world.GetTime()
 	}
 	if m.playerID == "" {
 		m.isReloading = false
-		m.reloadTime = ticktime.GetServerStartTime()
+		m.reloadTime = m.world.GetClock().GetServerStartTime()
 	} else {
-		now := ticktime.GetServerTime()
+		now := m.world.GetTime()
 		m.isTriggering = now.Sub(m.triggerTime) < shotgunTriggerCooldown
 		isReloading := now.Sub(m.reloadTime) < shotgunReloadCooldown
 		if !isReloading && m.isReloading {
@@ -175,16 +173,14 @@ func (m *WeaponShotgun) ClientUpdate() {
 	var now time.Time
 	var ss *protocol.WeaponShotgunSnapshot
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 		snapshot := m.getLastSnapshot()
 		ss = snapshot.Weapon.Shotgun
 	} else {
-		now = ticktime.GetLerpTime()
+		now = m.world.GetClock().GetLerpTime()
 		snapshot := m.getLerpSnapshot()
 		ss = snapshot.Weapon.Shotgun
 	}
-	prevTriggerTime := m.triggerTime
-	prevReloadTime := m.reloadTime
 	m.playerID = ss.PlayerID
 	m.mag = ss.Mag
 	m.ammo = ss.Ammo
@@ -192,20 +188,26 @@ func (m *WeaponShotgun) ClientUpdate() {
 	m.reloadTime = time.Unix(0, ss.ReloadTime)
 	m.isTriggering = now.Sub(m.triggerTime) < shotgunTriggerCooldown
 	m.isReloading = now.Sub(m.reloadTime) < shotgunReloadCooldown
-	if mainPlayer := m.world.GetMainPlayer(); mainPlayer != nil {
-		dist := m.world.GetMainPlayer().GetPivot().Sub(m.pos).Len()
-		if !ticktime.IsZeroTime(prevTriggerTime) && prevTriggerTime.Before(m.triggerTime) {
-			sound.PlayWeaponShotgunFire(dist)
-		}
-		if !ticktime.IsZeroTime(prevReloadTime) && prevReloadTime.Before(m.reloadTime) {
-			sound.PlayWeaponShotgunReload(dist)
-		}
-	}
 
 	// Clean snapshot
 	m.cleanTickSnapshots()
 }
 
+// HandleGameEvent plays the sounds of the weapon where it's rendered
+func (m *WeaponShotgun) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayer := m.world.GetMainPlayer()
+	if mainPlayer == nil {
+		return
+	}
+	dist := mainPlayer.GetPivot().Sub(m.pos).Len()
+	switch event.Type {
+	case protocol.GameEventFired:
+		sound.PlayWeaponShotgunFire(dist)
+	case protocol.GameEventReloaded:
+		sound.PlayWeaponShotgunReload(dist)
+	}
+}
+
 func (m *WeaponShotgun) GetSnapshot(tick int64) (snapshot *protocol.ObjectSnapshot) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -237,7 +239,7 @@ func (m *WeaponShotgun) Trigger() (ok bool) {
 	if !m.isTriggering && m.mag > 0 && !m.isReloading {
 		for i := 0; i < shotgunBulletAmount; i++ {
 			bullet := NewBullet(m.world, m.world.GetObjectDB().GetAvailableID())
-			recoilAngle := rand.Float64()*shotgunRecoilAngle - shotgunRecoilAngle/2
+			recoilAngle := m.world.GetRand().Float64()*shotgunRecoilAngle - shotgunRecoilAngle/2
 			dir := m.dir.Rotated(recoilAngle)
 			bullet.Fire(
 				m.playerID,
@@ -251,9 +253,14 @@ func (m *WeaponShotgun) Trigger() (ok bool) {
 			)
 			m.world.GetObjectDB().Set(bullet)
 		}
-		m.triggerTime = ticktime.GetServerTime()
+		m.triggerTime = m.world.GetTime()
 		m.mag--
 		ok = true
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventFired,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 	}
 	if !m.isReloading && m.mag == 0 {
 		_ = m.Reload()
@@ -263,7 +270,12 @@ func (m *WeaponShotgun) Trigger() (ok bool) {
 
 func (m *WeaponShotgun) Reload() bool {
 	if !m.isReloading && m.mag < shotgunMag && m.ammo > 0 {
-		m.reloadTime = ticktime.GetServerTime()
+		m.reloadTime = m.world.GetTime()
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventReloaded,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 		return true
 	}
 	return false
@@ -271,7 +283,7 @@ func (m *WeaponShotgun) Reload() bool {
 
 func (m *WeaponShotgun) StopReloading() {
 	m.isReloading = false
-	m.reloadTime = ticktime.GetServerStartTime()
+	m.reloadTime = m.world.GetClock().GetServerStartTime()
 }
 
 func (m *WeaponShotgun) SetPlayerID(playerID string) {
@@ -333,13 +345,13 @@ func (m *WeaponShotgun) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (m *WeaponShotgun) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return m.getSnapshotsByTime(ticktime.GetLerpTime())
+	return m.getSnapshotsByTime(m.world.GetClock().GetLerpTime())
 }
 
 func (m *WeaponShotgun) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, m.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(m.world.GetClock(), t, m.tickSnapshots)
 	if b == nil {
 		b = m.getCurrentSnapshot()
 	}
@@ -381,8 +393,8 @@ func (m *WeaponShotgun) cleanTickSnapshots() {
 	if len(m.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := m.world.GetTime().Add(-m.world.GetClock().GetLerpPeriod() * 2)
+	tick := m.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range m.tickSnapshots {
 		if ts.Tick >= tick {
@@ -2,7 +2,6 @@ package weapon
 
 import (
 	"math"
-	"math/rand"
 	"sync"
 	"time"
 
@@ -13,7 +12,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -100,7 +98,7 @@ func (m *WeaponSMG) GetRenderObjects() []common.RenderObject {
 }
 
 func (m *WeaponSMG) Render(target pixel.Target, viewPos pixel.Vec) {
-	now := ticktime.GetLerpTime()
+	now := m.world.GetClock().GetLerpTime()
 	anim := animation.NewWeaponSMG()
 	anim.Pos = m.pos.Sub(viewPos)
 	anim.Dir = m.dir
@@ -144,17 +142,17 @@ func (m *WeaponSMG) renderDir(target pixel.Target, viewPos pixel.Vec) { // For d
 }
 
 func (m *WeaponSMG) ServerUpdate(tick int64) {
-	if ticktime.IsZeroTime(m.triggerTime) {
-		m.triggerTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.triggerTime) {
+		m.triggerTime = m.world.GetTime()
 	}
-	if ticktime.IsZeroTime(m.reloadTime) {
-		m.reloadTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.reloadTime) {
+		m.reloadTime = m.world.GetTime()
 	}
 	if m.playerID == "" {
 		m.isReloading = false
-		m.reloadTime = ticktime.GetServerStartTime()
+		m.reloadTime = m.world.GetClock().GetServerStartTime()
 	} else {
-		now := ticktime.GetServerTime()
+		now := m.world.GetTime()
 		m.isTriggering = now.Sub(m.triggerTime) < smgTriggerCooldown
 		isReloading := now.Sub(m.reloadTime) < smgReloadCooldown
 		if !isReloading && m.isReloading {
@@ -168,7 +166,7 @@ func (m *WeaponSMG) ServerUpdate(tick int64) {
 }
 
 func (m *WeaponSMG) ClientUpdate() {
-	now := ticktime.GetLerpTime()
+	now := m.world.GetClock().GetLerpTime()
 	var ss *protocol.WeaponSMGSnapshot
 	if m.playerID == m.world.GetMainPlayerID() {
 		snapshot := m.getLastSnapshot()
@@ -177,8 +175,6 @@ func (m *WeaponSMG) ClientUpdate() {
 		snapshot := m.getLerpSnapshot()
 		ss = snapshot.Weapon.SMG
 	}
-	prevTriggerTime := m.triggerTime
-	prevReloadTime := m.reloadTime
 	m.playerID = ss.PlayerID
 	m.mag = ss.Mag
 	m.ammo = ss.Ammo
@@ -186,19 +182,25 @@ func (m *WeaponSMG) ClientUpdate() {
 	m.reloadTime = time.Unix(0, ss.ReloadTime)
 	m.isTriggering = now.Sub(m.triggerTime) < smgTriggerCooldown
 	m.isReloading = now.Sub(m.reloadTime) < smgReloadCooldown
-	if mainPlayer := m.world.GetMainPlayer(); mainPlayer != nil {
-		dist := m.world.GetMainPlayer().GetPivot().Sub(m.pos).Len()
-		if !ticktime.IsZeroTime(prevTriggerTime) && prevTriggerTime.Before(m.triggerTime) {
-			sound.PlayWeaponSMGFire(dist)
-		}
-		if !ticktime.IsZeroTime(prevReloadTime) && prevReloadTime.Before(m.reloadTime) {
-			sound.PlayWeaponSMGReload(dist)
-		}
-	}
 	// Clean snapshot
 	m.cleanTickSnapshots()
 }
 
+// HandleGameEvent plays the sounds of the weapon where it's rendered
+func (m *WeaponSMG) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayer := m.world.GetMainPlayer()
+	if mainPlayer == nil {
+		return
+	}
+	dist := mainPlayer.GetPivot().Sub(m.pos).Len()
+	switch event.Type {
+	case protocol.GameEventFired:
+		sound.PlayWeaponSMGFire(dist)
+	case protocol.GameEventReloaded:
+		sound.PlayWeaponSMGReload(dist)
+	}
+}
+
 func (m *WeaponSMG) GetSnapshot(tick int64) (snapshot *protocol.ObjectSnapshot) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -229,7 +231,7 @@ func (m *WeaponSMG) Trigger() (ok bool) {
 	ok = false
 	if !m.isTriggering && m.mag > 0 && !m.isReloading {
 		bullet := NewBullet(m.world, m.world.GetObjectDB().GetAvailableID())
-		recoilAngle := rand.Float64()*smgRecoilAngle - smgRecoilAngle/2
+		recoilAngle := m.world.GetRand().Float64()*smgRecoilAngle - smgRecoilAngle/2
 		dir := m.dir.Rotated(recoilAngle)
 		bullet.Fire(
 			m.playerID,
@@ -242,9 +244,14 @@ func (m *WeaponSMG) Trigger() (ok bool) {
 			smgBulletLength,
 		)
 		m.world.GetObjectDB().Set(bullet)
-		m.triggerTime = ticktime.GetServerTime()
+		m.triggerTime = m.world.GetTime()
 		m.mag--
 		ok = true
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventFired,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 	}
 	if !m.isReloading && m.mag == 0 {
 		_ = m.Reload()
@@ -254,7 +261,12 @@ func (m *WeaponSMG) Trigger() (ok bool) {
 
 func (m *WeaponSMG) Reload() bool {
 	if !m.isReloading && m.mag < smgMag && m.ammo > 0 {
-		m.reloadTime = ticktime.GetServerTime()
+		m.reloadTime = m.world.GetTime()
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventReloaded,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 		return true
 	}
 	return false
@@ -262,7 +274,7 @@ func (m *WeaponSMG) Reload() bool {
 
 func (m *WeaponSMG) StopReloading() {
 	m.isReloading = false
-	m.reloadTime = ticktime.GetServerStartTime()
+	m.reloadTime = m.world.GetClock().GetServerStartTime()
 }
 
 func (m *WeaponSMG) SetPlayerID(playerID string) {
@@ -324,13 +336,13 @@ func (m *WeaponSMG) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (m *WeaponSMG) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return m.getSnapshotsByTime(ticktime.GetLerpTime())
+	return m.getSnapshotsByTime(m.world.GetClock().GetLerpTime())
 }
 
 func (m *WeaponSMG) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, m.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(m.world.GetClock(), t, m.tickSnapshots)
 	if b == nil {
 		b = m.getCurrentSnapshot()
 	}
@@ -372,8 +384,8 @@ func (m *WeaponSMG) cleanTickSnapshots() {
 	if len(m.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := m.world.GetTime().Add(-m.world.GetClock().GetLerpPeriod() * 2)
+	tick := m.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range m.tickSnapshots {
 		if ts.Tick >= tick {
@@ -11,7 +11,6 @@ import (
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"golang.org/x/image/colornames"
 )
@@ -97,9 +96,9 @@ func (m *WeaponSniper) GetRenderObjects() []common.RenderObject {
 }
 
 func (m *WeaponSniper) Render(target pixel.Target, viewPos pixel.Vec) {
-	now := ticktime.GetLerpTime()
+	now := m.world.GetClock().GetLerpTime()
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 	}
 	anim := animation.NewWeaponSniper()
 	anim.Pos = m.pos.Sub(viewPos)
@@ -144,17 +143,17 @@ func (m *WeaponSniper) renderDir(target pixel.Target, viewPos pixel.Vec) { // Fo
 }
 
 func (m *WeaponSniper) ServerUpdate(tick int64) {
-	if ticktime.IsZeroTime(m.triggerTime) {
-		m.triggerTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.triggerTime) {
+		m.triggerTime = m.world.GetTime()
 	}
-	if ticktime.IsZeroTime(m.reloadTime) {
-		m.reloadTime = ticktime.GetServerTime()
+	if m.world.GetClock().IsZeroTime(m.reloadTime) {
+		m.reloadTime = m.world.GetTime()
 	}
 	if m.playerID == "" {
 		m.isReloading = false
-		m.reloadTime = ticktime.GetServerStartTime()
+		m.reloadTime = m.world.GetClock().GetServerStartTime()
 	} else {
-		now := ticktime.GetServerTime()
+		now := m.world.GetTime()
 		m.isTriggering = now.Sub(m.triggerTime) < sniperTriggerCooldown
 		isReloading := now.Sub(m.reloadTime) < sniperReloadCooldown
 		if !isReloading && m.isReloading {
@@ -171,16 +170,14 @@ func (m *WeaponSniper) ClientUpdate() {
 	var now time.Time
 	var ss *protocol.WeaponSniperSnapshot
 	if m.playerID == m.world.GetMainPlayerID() {
-		now = ticktime.GetServerTime()
+		now = m.world.GetTime()
 		snapshot := m.getLastSnapshot()
 		ss = snapshot.Weapon.Sniper
 	} else {
-		now = ticktime.GetLerpTime()
+		now = m.world.GetClock().GetLerpTime()
 		snapshot := m.getLerpSnapshot()
 		ss = snapshot.Weapon.Sniper
 	}
-	prevTriggerTime := m.triggerTime
-	prevReloadTime := m.reloadTime
 	m.playerID = ss.PlayerID
 	m.mag = ss.Mag
 	m.ammo = ss.Ammo
@@ -188,19 +185,25 @@ func (m *WeaponSniper) ClientUpdate() {
 	m.reloadTime = time.Unix(0, ss.ReloadTime)
 	m.isTriggering = now.Sub(m.triggerTime) < sniperTriggerCooldown
 	m.isReloading = now.Sub(m.reloadTime) < sniperReloadCooldown
-	if mainPlayer := m.world.GetMainPlayer(); mainPlayer != nil {
-		dist := m.world.GetMainPlayer().GetPivot().Sub(m.pos).Len()
-		if !ticktime.IsZeroTime(prevTriggerTime) && prevTriggerTime.Before(m.triggerTime) {
-			sound.PlayWeaponSniperFire(dist)
-		}
-		if !ticktime.IsZeroTime(prevReloadTime) && prevReloadTime.Before(m.reloadTime) {
-			sound.PlayWeaponSniperReload(dist)
-		}
-	}
 	// Clean snapshot
 	m.cleanTickSnapshots()
 }
 
+// HandleGameEvent plays the sounds of the weapon where it's rendered
+func (m *WeaponSniper) HandleGameEvent(event *protocol.GameEvent) {
+	mainPlayer := m.world.GetMainPlayer()
+	if mainPlayer == nil {
+		return
+	}
+	dist := mainPlayer.GetPivot().Sub(m.pos).Len()
+	switch event.Type {
+	case protocol.GameEventFired:
+		sound.PlayWeaponSniperFire(dist)
+	case protocol.GameEventReloaded:
+		sound.PlayWeaponSniperReload(dist)
+	}
+}
+
 func (m *WeaponSniper) GetSnapshot(tick int64) (snapshot *protocol.ObjectSnapshot) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -242,9 +245,14 @@ func (m *WeaponSniper) Trigger() (ok bool) {
 			sniperBulletLength,
 		)
 		m.world.GetObjectDB().Set(bullet)
-		m.triggerTime = ticktime.GetServerTime()
+		m.triggerTime = m.world.GetTime()
 		m.mag--
 		ok = true
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventFired,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 	}
 	if !m.isReloading && m.mag == 0 {
 		_ = m.Reload()
@@ -254,7 +262,12 @@ func (m *WeaponSniper) Trigger() (ok bool) {
 
 func (m *WeaponSniper) Reload() bool {
 	if !m.isReloading && m.mag < sniperMag && m.ammo > 0 {
-		m.reloadTime = ticktime.GetServerTime()
+		m.reloadTime = m.world.GetTime()
+		m.world.AddGameEvent(&protocol.GameEvent{
+			Type:     protocol.GameEventReloaded,
+			ObjectID: m.id,
+			PlayerID: m.playerID,
+		})
 		return true
 	}
 	return false
@@ -262,7 +275,7 @@ func (m *WeaponSniper) Reload() bool {
 
 func (m *WeaponSniper) StopReloading() {
 	m.isReloading = false
-	m.reloadTime = ticktime.GetServerStartTime()
+	m.reloadTime = m.world.GetClock().GetServerStartTime()
 }
 
 func (m *WeaponSniper) SetPlayerID(playerID string) {
@@ -324,13 +337,13 @@ func (m *WeaponSniper) getLastSnapshot() *protocol.ObjectSnapshot {
 }
 
 func (m *WeaponSniper) getLerpSnapshot() *protocol.ObjectSnapshot {
-	return m.getSnapshotsByTime(ticktime.GetLerpTime())
+	return m.getSnapshotsByTime(m.world.GetClock().GetLerpTime())
 }
 
 func (m *WeaponSniper) getSnapshotsByTime(t time.Time) *protocol.ObjectSnapshot {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
-	_, b, _ := protocol.GetSnapshotByTime(t, m.tickSnapshots)
+	_, b, _ := protocol.GetSnapshotByTime(m.world.GetClock(), t, m.tickSnapshots)
 	if b == nil {
 		b = m.getCurrentSnapshot()
 	}
@@ -372,8 +385,8 @@ func (m *WeaponSniper) cleanTickSnapshots() {
 	if len(m.tickSnapshots) <= 1 {
 		return
 	}
-	t := ticktime.GetServerTime().Add(-config.LerpPeriod * 2)
-	tick := ticktime.GetTick(t)
+	t := m.world.GetTime().Add(-m.world.GetClock().GetLerpPeriod() * 2)
+	tick := m.world.GetClock().GetTick(t)
 	index := 0
 	for i, ts := range m.tickSnapshots {
 		if ts.Tick >= tick {
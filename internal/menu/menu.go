@@ -17,18 +17,22 @@ type Menu struct {
 	clientProcessor common.ClientProcessor
 	hostAddrInput   *Input
 	playerNameInput *Input
+	roomNameInput   *Input
 	playButton      *Button
+	roomsButton     *Button
 	message         string
+	rooms           string
 }
 
-func New(clientProcessor common.ClientProcessor) *Menu {
-	win := clientProcessor.GetWindow()
+func New(clientProcessor common.ClientProcessor, win *pixelgl.Window) *Menu {
 	return &Menu{
 		win:             win,
 		clientProcessor: clientProcessor,
 		hostAddrInput:   NewInput(win, config.TCPIP),
 		playerNameInput: NewInput(win, ""),
+		roomNameInput:   NewInput(win, ""),
 		playButton:      NewButton(win),
+		roomsButton:     NewButton(win),
 	}
 }
 
@@ -37,17 +41,28 @@ func (m *Menu) UpdateAndRender() {
 	m.render()
 }
 
+// SetMessage shows a message under the play button, like why the last game
+// was left.
+func (m *Menu) SetMessage(message string) {
+	m.message = message
+}
+
 func (m *Menu) render() {
 	m.hostAddrInput.Render()
 	m.playerNameInput.Render()
+	m.roomNameInput.Render()
 	m.playButton.Render()
+	m.roomsButton.Render()
 	m.renderMessage()
+	m.renderRooms()
 }
 
 func (m *Menu) update() {
 	m.updateHostAddrInput()
 	m.updatePlayerNameInput()
+	m.updateRoomNameInput()
 	m.updatePlayerButton()
+	m.updateRoomsButton()
 }
 
 func (m *Menu) updateHostAddrInput() {
@@ -59,7 +74,7 @@ func (m *Menu) updateHostAddrInput() {
 	m.hostAddrInput.HoverColor = colornames.Yellow
 	m.hostAddrInput.FocusColor = colornames.Red
 	m.hostAddrInput.Label = "HOST ADDRESS"
-	m.hostAddrInput.Pos = m.win.Bounds().Center().Sub(pixel.V(m.hostAddrInput.Width/2, -60))
+	m.hostAddrInput.Pos = m.win.Bounds().Center().Sub(pixel.V(m.hostAddrInput.Width/2, -120))
 	m.hostAddrInput.Update()
 }
 
@@ -72,10 +87,24 @@ func (m *Menu) updatePlayerNameInput() {
 	m.playerNameInput.HoverColor = colornames.Yellow
 	m.playerNameInput.FocusColor = colornames.Red
 	m.playerNameInput.Label = "PLAYER NAME"
-	m.playerNameInput.Pos = m.win.Bounds().Center().Sub(pixel.V(m.playerNameInput.Width/2, 0))
+	m.playerNameInput.Pos = m.win.Bounds().Center().Sub(pixel.V(m.playerNameInput.Width/2, -60))
 	m.playerNameInput.Update()
 }
 
+func (m *Menu) updateRoomNameInput() {
+	m.roomNameInput.Width = 360
+	m.roomNameInput.Height = 36
+	m.roomNameInput.Thickness = 2
+	m.roomNameInput.Size = 2
+	m.roomNameInput.Color = colornames.White
+	m.roomNameInput.HoverColor = colornames.Yellow
+	m.roomNameInput.FocusColor = colornames.Red
+	// Empty joins the default room, a new name creates a room
+	m.roomNameInput.Label = "ROOM"
+	m.roomNameInput.Pos = m.win.Bounds().Center().Sub(pixel.V(m.roomNameInput.Width/2, 0))
+	m.roomNameInput.Update()
+}
+
 func (m *Menu) updatePlayerButton() {
 	m.playButton.Width = 120
 	m.playButton.Height = 40
@@ -85,11 +114,12 @@ func (m *Menu) updatePlayerButton() {
 	m.playButton.HoverColor = colornames.Yellow
 	m.playButton.FocusColor = colornames.Red
 	m.playButton.Label = "PLAY"
-	m.playButton.Pos = m.win.Bounds().Center().Sub(pixel.V(m.playButton.Width/2, 64))
+	m.playButton.Pos = m.win.Bounds().Center().Sub(pixel.V(m.playButton.Width+10, 64))
 	m.playButton.Update()
 	if m.playButton.Actived() {
 		err := m.clientProcessor.StartWorld(
 			m.hostAddrInput.GetValue(),
+			m.roomNameInput.GetValue(),
 			m.playerNameInput.GetValue(),
 		)
 		if err != nil {
@@ -98,6 +128,31 @@ func (m *Menu) updatePlayerButton() {
 	}
 }
 
+func (m *Menu) updateRoomsButton() {
+	m.roomsButton.Width = 120
+	m.roomsButton.Height = 40
+	m.roomsButton.Thickness = 2
+	m.roomsButton.Size = 2
+	m.roomsButton.Color = colornames.White
+	m.roomsButton.HoverColor = colornames.Yellow
+	m.roomsButton.FocusColor = colornames.Red
+	m.roomsButton.Label = "ROOMS"
+	m.roomsButton.Pos = m.win.Bounds().Center().Sub(pixel.V(-10, 64))
+	m.roomsButton.Update()
+	if m.roomsButton.Actived() {
+		rooms, err := m.clientProcessor.ListRooms(m.hostAddrInput.GetValue())
+		if err != nil {
+			m.message = err.Error()
+			return
+		}
+		m.message = ""
+		m.rooms = ""
+		for _, room := range rooms {
+			m.rooms += fmt.Sprintf("%s (%d)\n", room.Name, room.PlayerCount)
+		}
+	}
+}
+
 func (m *Menu) renderMessage() {
 	atlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
 	txt := text.New(pixel.ZV, atlas)
@@ -109,3 +164,15 @@ func (m *Menu) renderMessage() {
 		Moved(m.win.Bounds().Center().Sub(pixel.V(txt.Bounds().W()/2, 88))),
 	)
 }
+
+func (m *Menu) renderRooms() {
+	atlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
+	txt := text.New(pixel.ZV, atlas)
+	txt.Clear()
+	txt.LineHeight = atlas.LineHeight()
+	txt.Color = colornames.White
+	fmt.Fprint(txt, m.rooms)
+	txt.Draw(m.win, pixel.IM.
+		Moved(m.win.Bounds().Center().Sub(pixel.V(txt.Bounds().W()/2, 112))),
+	)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/mr-panta/go-logger"
 )
@@ -14,6 +15,7 @@ func NewClient(tcpAddrA, tcpAddrB string) Client {
 		id:           randString(clientIDLength),
 		tcpAddrA:     tcpAddrA,
 		tcpAddrB:     tcpAddrB,
+		limits:       DefaultLimits(),
 		tcpConnAPool: make(chan *Connection, poolSize),
 		listenBuffer: make(chan []byte, listenBufferSize),
 		closeSig:     make(chan bool, 1),
@@ -24,6 +26,7 @@ type client struct {
 	id           string
 	tcpAddrA     string
 	tcpAddrB     string
+	limits       Limits
 	tcpConnAPool chan *Connection
 	tcpConnBList []*Connection
 	listenBuffer chan []byte
@@ -38,15 +41,20 @@ func (c *client) Start() (err error) {
 		if err != nil {
 			return err
 		}
-		c.tcpConnAPool <- NewConnection(tcpConnA)
+		conn := NewConnection(tcpConnA, c.limits)
+		if err := conn.Write([]byte(c.id)); err != nil {
+			return err
+		}
+		c.tcpConnAPool <- conn
 	}
 	for i := 0; i < poolSize; i++ {
 		tcpConnB, err := net.Dial("tcp", c.tcpAddrB)
 		if err != nil {
 			return err
 		}
-		c.tcpConnBList = append(c.tcpConnBList, NewConnection(tcpConnB))
-		go c.listenConnB(i)
+		conn := NewConnection(tcpConnB, c.limits)
+		c.tcpConnBList = append(c.tcpConnBList, conn)
+		go c.listenConnB(conn)
 	}
 	return nil
 }
@@ -81,21 +89,30 @@ func (c *client) Send(req []byte) (resp []byte, err error) {
 	if c.isClosed {
 		return nil, fmt.Errorf("network client is closed")
 	}
-	tcpConn := <-c.tcpConnAPool
+	// Broken connections leave the pool, don't wait forever when all are gone
+	var tcpConn *Connection
+	select {
+	case tcpConn = <-c.tcpConnAPool:
+	case <-time.After(c.limits.IdleTimeOut):
+		return nil, fmt.Errorf("no connection available")
+	}
 	// Compress data
 	if req, err = compressData(req); err != nil {
 		return nil, err
 	}
 	// Write data
 	if err = tcpConn.Write(req); err != nil {
+		_ = tcpConn.Close()
 		return nil, err
 	}
 	// Read data
 	if resp, err = tcpConn.Read(); err != nil {
+		_ = tcpConn.Close()
 		return nil, err
 	}
 	// Uncompress data
-	if resp, err = uncompressData(resp); err != nil {
+	if resp, err = uncompressData(resp, c.limits.MaxDecompressedSize); err != nil {
+		_ = tcpConn.Close()
 		return nil, err
 	}
 	// Return connect
@@ -103,25 +120,33 @@ func (c *client) Send(req []byte) (resp []byte, err error) {
 	return resp, nil
 }
 
-func (c *client) listenConnB(i int) {
+// SendUnreliable is the same as Send, tcp is always reliable
+func (c *client) SendUnreliable(req []byte) (resp []byte, err error) {
+	return c.Send(req)
+}
+
+func (c *client) listenConnB(conn *Connection) {
 	ctx := context.Background()
 	buffer := []byte(c.id)
-	conn := c.tcpConnBList[i]
 	if err := conn.Write(buffer); err != nil {
 		logger.Errorf(ctx, err.Error())
 		return
 	}
-	for !c.isClosed {
-		c.lock.RLock()
+	for {
 		data, err := conn.Read()
-		if err != nil {
-			logger.Errorf(ctx, "%v|%v", err.Error(), conn.LocalAddr())
-		}
 		// Uncompress data
-		data, err = uncompressData(data)
+		if err == nil {
+			data, err = uncompressData(data, c.limits.MaxDecompressedSize)
+		}
+		c.lock.RLock()
+		if c.isClosed {
+			c.lock.RUnlock()
+			return
+		}
 		if err != nil {
-			logger.Errorf(ctx, err.Error())
-			break
+			c.lock.RUnlock()
+			logger.Errorf(ctx, "%v|%v", err.Error(), conn.LocalAddr())
+			return
 		}
 		c.listenBuffer <- data
 		c.lock.RUnlock()
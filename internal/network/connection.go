@@ -2,18 +2,21 @@ package network
 
 import (
 	"encoding/binary"
+	"io"
 	"net"
 )
 
 // Connection
 
 type Connection struct {
-	conn net.Conn
+	conn   net.Conn
+	limits Limits
 }
 
-func NewConnection(conn net.Conn) *Connection {
+func NewConnection(conn net.Conn, limits Limits) *Connection {
 	return &Connection{
-		conn: conn,
+		conn:   conn,
+		limits: limits,
 	}
 }
 
@@ -29,40 +32,44 @@ func (c *Connection) Close() error {
 	return c.conn.Close()
 }
 
+// Read waits up to IdleTimeOut for a frame to start, the length prefix is
+// checked before anything is allocated.
 func (c *Connection) Read() (data []byte, err error) {
-	length := 4
-	lengthBytes := make([]byte, length)
-	for length > 0 {
-		n, err := c.conn.Read(lengthBytes[len(lengthBytes)-length:])
-		if err != nil {
-			return nil, err
-		}
-		length -= n
+	if err = c.conn.SetReadDeadline(getDeadline(c.limits.IdleTimeOut)); err != nil {
+		return nil, err
+	}
+	lengthBytes := make([]byte, 4)
+	if _, err = io.ReadFull(c.conn, lengthBytes); err != nil {
+		return nil, err
+	}
+	length := int64(binary.LittleEndian.Uint32(lengthBytes))
+	if c.limits.MaxFrameSize > 0 && length > int64(c.limits.MaxFrameSize) {
+		return nil, errFrameTooLarge
+	}
+	if err = c.conn.SetReadDeadline(getDeadline(c.limits.IOTimeOut)); err != nil {
+		return nil, err
 	}
-	length = int(binary.LittleEndian.Uint32(lengthBytes))
 	data = make([]byte, length)
-	for length > 0 {
-		n, err := c.conn.Read(data[len(data)-length:])
-		if err != nil {
-			return nil, err
+	if _, err = io.ReadFull(c.conn, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
-		length -= n
+		return nil, err
 	}
 	return data, nil
 }
 
 func (c *Connection) Write(data []byte) error {
 	length := len(data)
-	extendedLength := length + 4
-	dataWithLength := make([]byte, extendedLength)
+	if c.limits.MaxFrameSize > 0 && length > c.limits.MaxFrameSize {
+		return errFrameTooLarge
+	}
+	if err := c.conn.SetWriteDeadline(getDeadline(c.limits.IOTimeOut)); err != nil {
+		return err
+	}
+	dataWithLength := make([]byte, length+4)
 	binary.LittleEndian.PutUint32(dataWithLength[:4], uint32(length))
 	copy(dataWithLength[4:], data)
-	for extendedLength > 0 {
-		n, err := c.conn.Write(dataWithLength[len(dataWithLength)-extendedLength:])
-		if err != nil {
-			return err
-		}
-		extendedLength -= n
-	}
-	return nil
+	_, err := c.conn.Write(dataWithLength)
+	return err
 }
@@ -0,0 +1,215 @@
+package network
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+// errTestTimeout stands for any timeout error
+var errTestTimeout = errors.New("timeout")
+
+var testLimits = Limits{
+	MaxFrameSize:        1 << 10,
+	MaxDecompressedSize: 1 << 12,
+	IdleTimeOut:         200 * time.Millisecond,
+	IOTimeOut:           100 * time.Millisecond,
+}
+
+// makeTestFrame prefixes data with length, which may lie about it
+func makeTestFrame(length uint32, data []byte) []byte {
+	frame := make([]byte, 4, 4+len(data))
+	binary.LittleEndian.PutUint32(frame, length)
+	return append(frame, data...)
+}
+
+func compressTestData(t *testing.T, data []byte) []byte {
+	compressed, err := compressData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compressed
+}
+
+func TestConnectionRead(t *testing.T) {
+	cases := []struct {
+		name string
+		sent []byte
+		// the peer keeps the connection open after sending
+		stall bool
+		data  []byte
+		err   error
+	}{
+		{"frame", makeTestFrame(5, []byte("hello")), false, []byte("hello"), nil},
+		{"empty frame", makeTestFrame(0, nil), false, []byte{}, nil},
+		{"largest frame", makeTestFrame(1<<10, make([]byte, 1<<10)), false, make([]byte, 1<<10), nil},
+		{"oversized length", makeTestFrame(1<<10+1, nil), true, nil, errFrameTooLarge},
+		{"max length", makeTestFrame(0xffffffff, nil), true, nil, errFrameTooLarge},
+		{"closed", nil, false, nil, io.EOF},
+		{"truncated length", []byte{5, 0}, false, nil, io.ErrUnexpectedEOF},
+		{"truncated data", makeTestFrame(5, []byte("he")), false, nil, io.ErrUnexpectedEOF},
+		{"truncated empty data", makeTestFrame(5, nil), false, nil, io.ErrUnexpectedEOF},
+		{"idle", nil, true, nil, errTestTimeout},
+		{"stalled length", []byte{5, 0}, true, nil, errTestTimeout},
+		{"stalled data", makeTestFrame(5, []byte("he")), true, nil, errTestTimeout},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			go func() {
+				client.Write(c.sent)
+				if !c.stall {
+					client.Close()
+				}
+			}()
+			defer client.Close()
+			data, err := NewConnection(server, testLimits).Read()
+			if c.err == errTestTimeout {
+				if !isTimeout(err) {
+					t.Fatalf("got %v, want a timeout", err)
+				}
+				return
+			}
+			if err != c.err {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+			if !bytes.Equal(data, c.data) {
+				t.Fatalf("got %d bytes, want %d", len(data), len(c.data))
+			}
+		})
+	}
+}
+
+func TestConnectionWriteRejectsOversizedFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err := NewConnection(client, testLimits).Write(make([]byte, 1<<10+1)); err != errFrameTooLarge {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestUncompressData(t *testing.T) {
+	// A few kilobytes which inflate to far more than the limit
+	bomb := &bytes.Buffer{}
+	w := zlib.NewWriter(bomb)
+	w.Write(make([]byte, 1<<23))
+	w.Close()
+	data := make([]byte, 1<<12)
+	rand.New(rand.NewSource(1)).Read(data)
+	compressed := compressTestData(t, data)
+	cases := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		ok      bool
+		err     error
+	}{
+		{"data", compressed, 1 << 12, true, nil},
+		{"no limit", compressed, 0, true, nil},
+		{"over limit", compressed, 1<<12 - 1, false, errDecompressedTooLarge},
+		{"zlib bomb", bomb.Bytes(), 1 << 16, false, errDecompressedTooLarge},
+		{"empty", nil, 1 << 12, false, nil},
+		{"not zlib", []byte("not zlib at all"), 1 << 12, false, nil},
+		{"truncated", compressed[:len(compressed)/2], 1 << 12, false, nil},
+		{"bad checksum", append(append([]byte{}, compressed[:len(compressed)-1]...), compressed[len(compressed)-1]^1), 1 << 12, false, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := uncompressData(c.data, c.maxSize)
+			if c.ok {
+				if err != nil || !bytes.Equal(got, data) {
+					t.Fatalf("got %d bytes, err %v", len(got), err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got %d bytes", len(got))
+			}
+			if c.err != nil && err != c.err {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+// Garbage from peers must close their connections and be counted, the
+// server keeps serving everyone else.
+func TestServerClosesGarbageConnections(t *testing.T) {
+	p := &testProcess{}
+	s := NewServer("127.0.0.1:0", "127.0.0.1:0", testLimits, p.process).(*server)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	clientID := []byte(randString(clientIDLength))
+	bomb := &bytes.Buffer{}
+	w := zlib.NewWriter(bomb)
+	w.Write(make([]byte, 1<<18))
+	w.Close()
+	withID := func(data []byte) []byte {
+		return append(makeTestFrame(clientIDLength, clientID), data...)
+	}
+	type garbageCase struct {
+		name string
+		sent []byte
+		// only sent to the request port
+		requestOnly bool
+	}
+	cases := []garbageCase{
+		{"oversized length", makeTestFrame(0xffffffff, nil), false},
+		{"invalid client id", makeTestFrame(6, []byte("bad id")), false},
+		{"truncated client id", makeTestFrame(clientIDLength, clientID[:4]), false},
+		{"stalled", nil, false},
+		{"not zlib", withID(makeTestFrame(8, []byte("not zlib"))), true},
+		{"zlib bomb", withID(makeTestFrame(uint32(bomb.Len()), bomb.Bytes())), true},
+		{"oversized request", withID(makeTestFrame(1<<10+1, nil)), true},
+		{"truncated request", withID([]byte{16, 0, 0}), true},
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		garbage := make([]byte, r.Intn(64))
+		r.Read(garbage)
+		cases = append(cases, garbageCase{"random", garbage, false})
+	}
+	for _, addr := range []net.Addr{s.tcpListenerA.Addr(), s.tcpListenerB.Addr()} {
+		for _, c := range cases {
+			if c.requestOnly && addr == s.tcpListenerB.Addr() {
+				continue
+			}
+			conn, err := net.Dial("tcp", addr.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Write(c.sent)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			buffer := make([]byte, 16)
+			if n, err := conn.Read(buffer); err == nil {
+				t.Fatalf("%s to %v got %q", c.name, addr, buffer[:n])
+			} else if isTimeout(err) {
+				t.Fatalf("%s to %v wasn't closed", c.name, addr)
+			}
+			conn.Close()
+		}
+	}
+	counts := s.GetErrorCounts()
+	if counts.FrameTooLarge == 0 || counts.DecompressedTooLarge == 0 || counts.Timeout == 0 || counts.Malformed == 0 {
+		t.Fatalf("errors were not counted %+v", counts)
+	}
+	c := NewClient(s.tcpListenerA.Addr().String(), s.tcpListenerB.Addr().String())
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if resp, err := c.Send([]byte("hello")); err != nil || string(resp) != "hello" {
+		t.Fatalf("client got %q %v", resp, err)
+	}
+}
@@ -0,0 +1,114 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+)
+
+var (
+	errFrameTooLarge        = errors.New("frame is too large")
+	errDecompressedTooLarge = errors.New("decompressed data is too large")
+	errInvalidClientID      = errors.New("client id is invalid")
+	errTooManyConnections   = errors.New("client has too many connections")
+	errClientHostMismatch   = errors.New("client id is used from another host")
+	errClientTooSlow        = errors.New("client is too slow to receive pushes")
+)
+
+// Limits protect a peer from connections which send too much or too slowly,
+// a zero value disables the limit.
+type Limits struct {
+	MaxFrameSize        int
+	MaxDecompressedSize int
+	// IdleTimeOut is how long a connection may wait for the next frame,
+	// IOTimeOut is how long reading the rest of a frame or writing one may take
+	IdleTimeOut time.Duration
+	IOTimeOut   time.Duration
+	// SlowClientTimeOut is how long a client may stay behind on pushes
+	// before it is evicted
+	SlowClientTimeOut time.Duration
+	// HeartbeatTimeOut replaces IdleTimeOut on mux connections, which are
+	// never idle for longer than the heartbeat period
+	HeartbeatTimeOut time.Duration
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFrameSize:        config.DefaultMaxFrameSize,
+		MaxDecompressedSize: config.DefaultMaxDecompressedSize,
+		IdleTimeOut:         config.DefaultIdleTimeOut,
+		IOTimeOut:           config.DefaultIOTimeOut,
+		SlowClientTimeOut:   config.DefaultSlowClientTimeOut,
+		HeartbeatTimeOut:    config.DefaultHeartbeatTimeOut,
+	}
+}
+
+func getDeadline(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// ErrorCounts tells why connections were torn down.
+type ErrorCounts struct {
+	Closed               int64
+	Timeout              int64
+	FrameTooLarge        int64
+	DecompressedTooLarge int64
+	Malformed            int64
+	SlowClient           int64
+}
+
+type errorCounter struct {
+	counts ErrorCounts
+}
+
+func (c *errorCounter) add(err error) {
+	switch {
+	case err == io.EOF:
+		atomic.AddInt64(&c.counts.Closed, 1)
+	case err == errFrameTooLarge:
+		atomic.AddInt64(&c.counts.FrameTooLarge, 1)
+	case err == errDecompressedTooLarge:
+		atomic.AddInt64(&c.counts.DecompressedTooLarge, 1)
+	case err == errClientTooSlow:
+		atomic.AddInt64(&c.counts.SlowClient, 1)
+	case isTimeout(err):
+		atomic.AddInt64(&c.counts.Timeout, 1)
+	default:
+		atomic.AddInt64(&c.counts.Malformed, 1)
+	}
+}
+
+func (c *errorCounter) get() ErrorCounts {
+	return ErrorCounts{
+		Closed:               atomic.LoadInt64(&c.counts.Closed),
+		Timeout:              atomic.LoadInt64(&c.counts.Timeout),
+		FrameTooLarge:        atomic.LoadInt64(&c.counts.FrameTooLarge),
+		DecompressedTooLarge: atomic.LoadInt64(&c.counts.DecompressedTooLarge),
+		Malformed:            atomic.LoadInt64(&c.counts.Malformed),
+		SlowClient:           atomic.LoadInt64(&c.counts.SlowClient),
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func isValidClientID(clientID string) bool {
+	if len(clientID) != clientIDLength {
+		return false
+	}
+	for _, r := range clientID {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
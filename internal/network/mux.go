@@ -0,0 +1,55 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// A mux connection carries every request, response and push of a client over
+// one tcp stream. Each frame starts with its type and the request id, which
+// matches a response to its request while others are still in flight.
+const (
+	muxFrameRequest  = 1
+	muxFrameResponse = 2
+	muxFramePush     = 3
+	// heartbeats keep the connection from being closed as idle, they have
+	// no request id or data
+	muxFrameHeartbeat = 4
+	// type, request id
+	muxHeaderSize = 5
+)
+
+var (
+	errMuxInvalidFrame    = errors.New("mux frame is invalid")
+	errMuxConnectionLost  = errors.New("mux connection is lost")
+	errMuxRequestTimedOut = errors.New("mux request timed out")
+)
+
+// getMuxLimits waits for the next frame only as long as heartbeats may be
+// missing, so a dead peer is noticed quickly.
+func getMuxLimits(limits Limits) Limits {
+	if limits.HeartbeatTimeOut > 0 {
+		limits.IdleTimeOut = limits.HeartbeatTimeOut
+	}
+	return limits
+}
+
+func makeMuxFrame(frameType byte, id uint32, data []byte) []byte {
+	frame := make([]byte, muxHeaderSize+len(data))
+	frame[0] = frameType
+	binary.LittleEndian.PutUint32(frame[1:muxHeaderSize], id)
+	copy(frame[muxHeaderSize:], data)
+	return frame
+}
+
+func readMuxFrame(frame []byte) (frameType byte, id uint32, data []byte, err error) {
+	if len(frame) < muxHeaderSize {
+		return 0, 0, nil, errMuxInvalidFrame
+	}
+	frameType = frame[0]
+	if frameType < muxFrameRequest || frameType > muxFrameHeartbeat {
+		return 0, 0, nil, errMuxInvalidFrame
+	}
+	id = binary.LittleEndian.Uint32(frame[1:muxHeaderSize])
+	return frameType, id, frame[muxHeaderSize:], nil
+}
@@ -0,0 +1,214 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/go-logger"
+)
+
+func NewMuxClient(tcpAddr string) Client {
+	return &muxClient{
+		id:           randString(clientIDLength),
+		tcpAddr:      tcpAddr,
+		limits:       getMuxLimits(DefaultLimits()),
+		pendingMap:   make(map[uint32]chan []byte),
+		brokenSig:    make(chan bool),
+		listenBuffer: make(chan []byte, listenBufferSize),
+		closeSig:     make(chan bool, 1),
+	}
+}
+
+type muxClient struct {
+	id           string
+	tcpAddr      string
+	limits       Limits
+	conn         *Connection
+	writeLock    sync.Mutex
+	seq          uint32
+	pendingMap   map[uint32]chan []byte
+	pendingLock  sync.Mutex
+	isBroken     bool
+	brokenSig    chan bool
+	listenBuffer chan []byte
+	closeSig     chan bool
+	isClosed     bool
+	lock         sync.RWMutex
+}
+
+func (c *muxClient) Start() error {
+	tcpConn, err := net.Dial("tcp", c.tcpAddr)
+	if err != nil {
+		return err
+	}
+	c.conn = NewConnection(tcpConn, c.limits)
+	if err := c.conn.Write([]byte(c.id)); err != nil {
+		_ = c.conn.Close()
+		return err
+	}
+	go c.listen()
+	go c.startHeartbeat()
+	return nil
+}
+
+func (c *muxClient) Wait() {
+	<-c.closeSig
+}
+
+func (c *muxClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closeSig <- true
+	c.isClosed = true
+	close(c.listenBuffer)
+	return c.conn.Close()
+}
+
+func (c *muxClient) Send(req []byte) (resp []byte, err error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.isClosed {
+		return nil, fmt.Errorf("network client is closed")
+	}
+	// Compress data
+	if req, err = compressData(req); err != nil {
+		return nil, err
+	}
+	id := atomic.AddUint32(&c.seq, 1)
+	ch, err := c.addPending(id)
+	if err != nil {
+		return nil, err
+	}
+	defer c.removePending(id)
+	// Write data
+	if err = c.write(makeMuxFrame(muxFrameRequest, id, req)); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	// Wait for the response, a closed channel means the connection is lost
+	var ok bool
+	select {
+	case resp, ok = <-ch:
+		if !ok {
+			return nil, errMuxConnectionLost
+		}
+	case <-time.After(c.limits.IdleTimeOut):
+		return nil, errMuxRequestTimedOut
+	}
+	// Uncompress data
+	return uncompressData(resp, c.limits.MaxDecompressedSize)
+}
+
+// SendUnreliable is the same as Send, tcp is always reliable
+func (c *muxClient) SendUnreliable(req []byte) (resp []byte, err error) {
+	return c.Send(req)
+}
+
+func (c *muxClient) write(frame []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.Write(frame)
+}
+
+func (c *muxClient) addPending(id uint32) (chan []byte, error) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if c.isBroken {
+		return nil, errMuxConnectionLost
+	}
+	ch := make(chan []byte, 1)
+	c.pendingMap[id] = ch
+	return ch, nil
+}
+
+func (c *muxClient) removePending(id uint32) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	delete(c.pendingMap, id)
+}
+
+func (c *muxClient) resolve(id uint32, data []byte) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if ch, exists := c.pendingMap[id]; exists {
+		ch <- data
+		delete(c.pendingMap, id)
+	}
+}
+
+// breakPending fails every request in flight and all later ones
+func (c *muxClient) breakPending() {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	c.isBroken = true
+	close(c.brokenSig)
+	for id, ch := range c.pendingMap {
+		close(ch)
+		delete(c.pendingMap, id)
+	}
+}
+
+// startHeartbeat tells the server that the client is alive until the
+// connection is lost.
+func (c *muxClient) startHeartbeat() {
+	ticker := time.NewTicker(config.HeartbeatPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(makeMuxFrame(muxFrameHeartbeat, 0, nil)); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-c.brokenSig:
+			return
+		}
+	}
+}
+
+func (c *muxClient) listen() {
+	ctx := context.Background()
+	defer c.breakPending()
+	for {
+		frame, err := c.conn.Read()
+		var frameType byte
+		var id uint32
+		var data []byte
+		if err == nil {
+			frameType, id, data, err = readMuxFrame(frame)
+		}
+		if err != nil {
+			c.lock.RLock()
+			if !c.isClosed {
+				logger.Errorf(ctx, "%v|%v", err.Error(), c.conn.LocalAddr())
+			}
+			c.lock.RUnlock()
+			_ = c.conn.Close()
+			return
+		}
+		switch frameType {
+		case muxFrameResponse:
+			c.resolve(id, data)
+		case muxFramePush:
+			// Uncompress data
+			if data, err = uncompressData(data, c.limits.MaxDecompressedSize); err != nil {
+				logger.Errorf(ctx, err.Error())
+				continue
+			}
+			c.lock.RLock()
+			if !c.isClosed {
+				c.listenBuffer <- data
+			}
+			c.lock.RUnlock()
+		}
+	}
+}
+
+func (c *muxClient) Listen() <-chan []byte {
+	return c.listenBuffer
+}
@@ -0,0 +1,280 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/go-logger"
+)
+
+func NewMuxServer(tcpAddr string, limits Limits, process Process) Server {
+	return &muxServer{
+		tcpAddr:   tcpAddr,
+		limits:    limits,
+		process:   process,
+		closeSig:  make(chan bool, 1),
+		clientMap: make(map[string]*muxServerClient),
+	}
+}
+
+type muxServer struct {
+	tcpAddr      string
+	limits       Limits
+	errorCounter errorCounter
+	process      Process
+	closeSig     chan bool
+	tcpListener  net.Listener
+	clientMap    map[string]*muxServerClient
+	clientLock   sync.RWMutex
+	isClosed     int32
+}
+
+type muxServerClient struct {
+	conn      *Connection
+	writeLock sync.Mutex
+	queue     *sendQueue
+	isEvicted int32
+}
+
+func (s *muxServer) Start() (err error) {
+	s.tcpListener, err = net.Listen("tcp", s.tcpAddr)
+	if err != nil {
+		return err
+	}
+	logger.Infof(context.Background(), "listen tcp (mux) connection|%+v", s.tcpListener.Addr())
+	go s.listen()
+	return nil
+}
+
+func (s *muxServer) listen() {
+	ctx := context.Background()
+	for atomic.LoadInt32(&s.isClosed) == 0 {
+		conn, err := s.tcpListener.Accept()
+		if err != nil {
+			logger.Errorf(ctx, err.Error())
+			continue
+		}
+		go s.handleConn(NewConnection(conn, getMuxLimits(s.limits)))
+	}
+}
+
+func (s *muxServer) handleConn(conn *Connection) {
+	clientID, err := readClientID(conn)
+	if err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	c, err := s.addClient(clientID, conn)
+	if err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	logger.Infof(context.Background(), "client_id:%s|connection is created via tcp (mux)|%+v", clientID, conn.RemoteAddr())
+	go s.startPushWorker(c)
+	go s.startHeartbeat(c)
+	err = s.readRequests(clientID, c)
+	s.removeClient(clientID, c)
+	// Reading fails because the connection was closed by the eviction
+	if atomic.LoadInt32(&c.isEvicted) == 1 {
+		err = errClientTooSlow
+	}
+	closeConnection(&s.errorCounter, conn, clientID, err)
+}
+
+// readRequests runs requests of a client concurrently, at most poolSize at
+// a time like the pooled connections of the dual port server.
+func (s *muxServer) readRequests(clientID string, c *muxServerClient) error {
+	sem := make(chan bool, poolSize)
+	for {
+		// Read data
+		frame, err := c.conn.Read()
+		if err != nil {
+			return err
+		}
+		frameType, id, req, err := readMuxFrame(frame)
+		if err != nil {
+			return err
+		}
+		if frameType == muxFrameHeartbeat {
+			continue
+		}
+		if frameType != muxFrameRequest {
+			return errMuxInvalidFrame
+		}
+		// Uncompress data
+		if req, err = uncompressData(req, s.limits.MaxDecompressedSize); err != nil {
+			return err
+		}
+		sem <- true
+		go func() {
+			defer func() { <-sem }()
+			s.handleRequest(clientID, c, id, req)
+		}()
+	}
+}
+
+func (s *muxServer) handleRequest(clientID string, c *muxServerClient, id uint32, req []byte) {
+	// Process data
+	resp := s.process(clientID, req)
+	// Compress data
+	resp, err := compressData(resp)
+	if err != nil {
+		logger.Errorf(context.Background(), err.Error())
+		_ = c.conn.Close()
+		return
+	}
+	// Write data, the reader notices a broken connection and cleans up
+	if err = c.write(makeMuxFrame(muxFrameResponse, id, resp)); err != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *muxServerClient) write(frame []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.conn.Write(frame)
+}
+
+func (s *muxServer) addClient(clientID string, conn *Connection) (*muxServerClient, error) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if _, exists := s.clientMap[clientID]; exists {
+		return nil, errTooManyConnections
+	}
+	c := &muxServerClient{
+		conn:  conn,
+		queue: newSendQueue(config.BufferSize, s.limits.SlowClientTimeOut),
+	}
+	s.clientMap[clientID] = c
+	return c, nil
+}
+
+func (s *muxServer) removeClient(clientID string, c *muxServerClient) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if s.clientMap[clientID] == c {
+		delete(s.clientMap, clientID)
+	}
+	c.queue.close()
+}
+
+// evictClient closes the connection of a client which can't keep up, the
+// reader of the connection removes the client.
+func (s *muxServer) evictClient(clientID string, c *muxServerClient, err error) {
+	if atomic.CompareAndSwapInt32(&c.isEvicted, 0, 1) {
+		logger.Errorf(context.Background(), "client_id:%s|evict client|err:%v|stats:%+v",
+			clientID, err, c.queue.getStats())
+		_ = c.conn.Close()
+	}
+}
+
+func (s *muxServer) getClient(clientID string) (c *muxServerClient, exists bool) {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	c, exists = s.clientMap[clientID]
+	return c, exists
+}
+
+func (s *muxServer) startPushWorker(c *muxServerClient) {
+	for {
+		data, ok := c.queue.pop()
+		if !ok {
+			return
+		}
+		if err := c.write(makeMuxFrame(muxFramePush, 0, data)); err != nil {
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+// startHeartbeat tells the client that the server is alive until the
+// client is removed.
+func (s *muxServer) startHeartbeat(c *muxServerClient) {
+	ticker := time.NewTicker(config.HeartbeatPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(makeMuxFrame(muxFrameHeartbeat, 0, nil)); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-c.queue.closeSig:
+			return
+		}
+	}
+}
+
+func (s *muxServer) GetClientIDs() (list []string) {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	for clientID := range s.clientMap {
+		list = append(list, clientID)
+	}
+	return list
+}
+
+func (s *muxServer) GetClientStats() map[string]ClientStats {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	stats := make(map[string]ClientStats)
+	for clientID, c := range s.clientMap {
+		stats[clientID] = c.queue.getStats()
+	}
+	return stats
+}
+
+func (s *muxServer) GetErrorCounts() ErrorCounts {
+	return s.errorCounter.get()
+}
+
+func (s *muxServer) Wait() {
+	<-s.closeSig
+}
+
+func (s *muxServer) Close() error {
+	// The accept loop stops instead of logging the closed listener
+	atomic.StoreInt32(&s.isClosed, 1)
+	if err := s.tcpListener.Close(); err != nil {
+		return err
+	}
+	s.closeSig <- true
+	return nil
+}
+
+func (s *muxServer) Broadcast(data []byte) {
+	s.Multicast(s.GetClientIDs(), data)
+}
+
+func (s *muxServer) Multicast(clientIDs []string, data []byte) {
+	s.multicast(clientIDs, data, false)
+}
+
+func (s *muxServer) MulticastLatest(clientIDs []string, data []byte) {
+	s.multicast(clientIDs, data, true)
+}
+
+func (s *muxServer) multicast(clientIDs []string, data []byte, latest bool) {
+	// Compress data once for all clients
+	data, err := compressData(data)
+	if err != nil {
+		logger.Errorf(context.Background(), err.Error())
+		return
+	}
+	for _, clientID := range clientIDs {
+		if c, exists := s.getClient(clientID); exists {
+			if err := c.queue.push(data, latest); err != nil {
+				s.evictClient(clientID, c, err)
+			}
+		}
+	}
+}
+
+func (s *muxServer) SendTo(clientID string, data []byte) {
+	s.Multicast([]string{clientID}, data)
+}
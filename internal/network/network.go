@@ -11,6 +11,7 @@ type Client interface {
 	Wait()
 	Close() error
 	Send(req []byte) (resp []byte, err error)
+	SendUnreliable(req []byte) (resp []byte, err error)
 	Listen() <-chan []byte
 }
 
@@ -19,6 +20,13 @@ type Server interface {
 	Wait()
 	Close() error
 	Broadcast(data []byte)
+	Multicast(clientIDs []string, data []byte)
+	// MulticastLatest replaces older latest data which is not sent yet
+	MulticastLatest(clientIDs []string, data []byte)
+	SendTo(clientID string, data []byte)
+	GetClientIDs() []string
+	GetErrorCounts() ErrorCounts
+	GetClientStats() map[string]ClientStats
 }
 
-type Process func(req []byte) (resp []byte)
+type Process func(clientID string, req []byte) (resp []byte)
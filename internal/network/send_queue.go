@@ -0,0 +1,124 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// ClientStats tells how well a client keeps up with pushes.
+type ClientStats struct {
+	QueueDepth int
+	// DropCount is the number of latest pushes replaced before being sent
+	DropCount int64
+}
+
+type sendItem struct {
+	data   []byte
+	latest bool
+}
+
+// sendQueue holds pushes of one client until its worker writes them. Data
+// pushed as latest replaces latest data which is still waiting, so a slow
+// client skips stale snapshots instead of piling them up.
+type sendQueue struct {
+	items       []sendItem
+	maxSize     int
+	slowTimeOut time.Duration
+	behindSince time.Time
+	dropCount   int64
+	signal      chan bool
+	closeSig    chan bool
+	isClosed    bool
+	lock        sync.Mutex
+}
+
+func newSendQueue(maxSize int, slowTimeOut time.Duration) *sendQueue {
+	return &sendQueue{
+		maxSize:     maxSize,
+		slowTimeOut: slowTimeOut,
+		signal:      make(chan bool, 1),
+		closeSig:    make(chan bool),
+	}
+}
+
+// push never blocks, errClientTooSlow is returned when the client has been
+// behind for longer than slowTimeOut or the queue is full.
+func (q *sendQueue) push(data []byte, latest bool) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.isClosed {
+		return nil
+	}
+	if len(q.items) > 0 {
+		if q.behindSince.IsZero() {
+			q.behindSince = time.Now()
+		} else if q.slowTimeOut > 0 && time.Since(q.behindSince) > q.slowTimeOut {
+			return errClientTooSlow
+		}
+	}
+	if latest {
+		items := q.items[:0]
+		for _, item := range q.items {
+			if item.latest {
+				q.dropCount++
+				continue
+			}
+			items = append(items, item)
+		}
+		q.items = items
+	}
+	if len(q.items) >= q.maxSize {
+		return errClientTooSlow
+	}
+	q.items = append(q.items, sendItem{data: data, latest: latest})
+	select {
+	case q.signal <- true:
+	default:
+	}
+	return nil
+}
+
+// pop waits for the next push, ok is false after the queue is closed.
+func (q *sendQueue) pop() (data []byte, ok bool) {
+	for {
+		q.lock.Lock()
+		if q.isClosed {
+			q.lock.Unlock()
+			return nil, false
+		}
+		if len(q.items) > 0 {
+			data = q.items[0].data
+			q.items[0] = sendItem{}
+			q.items = q.items[1:]
+			if len(q.items) == 0 {
+				q.behindSince = time.Time{}
+			}
+			q.lock.Unlock()
+			return data, true
+		}
+		q.lock.Unlock()
+		select {
+		case <-q.signal:
+		case <-q.closeSig:
+		}
+	}
+}
+
+func (q *sendQueue) close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if !q.isClosed {
+		q.isClosed = true
+		q.items = nil
+		close(q.closeSig)
+	}
+}
+
+func (q *sendQueue) getStats() ClientStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return ClientStats{
+		QueueDepth: len(q.items),
+		DropCount:  q.dropCount,
+	}
+}
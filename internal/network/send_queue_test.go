@@ -0,0 +1,224 @@
+package network
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// popAll pops what is waiting without blocking
+func popAll(q *sendQueue) []string {
+	got := []string{}
+	for q.getStats().QueueDepth > 0 {
+		data, ok := q.pop()
+		if !ok {
+			break
+		}
+		got = append(got, string(data))
+	}
+	return got
+}
+
+func TestSendQueueReplacesLatest(t *testing.T) {
+	q := newSendQueue(8, 0)
+	pushes := []struct {
+		data   string
+		latest bool
+	}{
+		{"a", false},
+		{"snapshot 1", true},
+		{"b", false},
+		{"snapshot 2", true},
+		{"c", false},
+		{"snapshot 3", true},
+	}
+	for _, push := range pushes {
+		if err := q.push([]byte(push.data), push.latest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := q.getStats()
+	if stats.QueueDepth != 4 || stats.DropCount != 2 {
+		t.Fatalf("got stats %+v", stats)
+	}
+	// Other pushes keep their order, only the newest latest push is left
+	if got := strings.Join(popAll(q), ","); got != "a,b,c,snapshot 3" {
+		t.Fatalf("popped %s", got)
+	}
+	if err := q.push([]byte("snapshot 4"), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(popAll(q), ","); got != "snapshot 4" {
+		t.Fatalf("popped %s", got)
+	}
+	if stats := q.getStats(); stats.QueueDepth != 0 || stats.DropCount != 2 {
+		t.Fatalf("a sent push was counted as dropped %+v", stats)
+	}
+}
+
+func TestSendQueueTooSlow(t *testing.T) {
+	const slowTimeOut = 50 * time.Millisecond
+	cases := []struct {
+		name        string
+		maxSize     int
+		slowTimeOut time.Duration
+		// pushes before the one which fails
+		pushes int
+		wait   time.Duration
+		latest bool
+	}{
+		{"full", 3, 0, 3, 0, false},
+		{"full of other pushes", 3, 0, 3, 0, true},
+		{"behind for too long", 100, slowTimeOut, 2, 2 * slowTimeOut, false},
+		{"latest behind for too long", 100, slowTimeOut, 2, 2 * slowTimeOut, true},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			q := newSendQueue(c.maxSize, c.slowTimeOut)
+			for i := 0; i < c.pushes; i++ {
+				if err := q.push([]byte("data"), false); err != nil {
+					t.Fatalf("push %d got %v", i, err)
+				}
+			}
+			time.Sleep(c.wait)
+			if err := q.push([]byte("data"), c.latest); err != errClientTooSlow {
+				t.Fatalf("got %v", err)
+			}
+		})
+	}
+}
+
+// A client which catches up is not behind anymore, however long ago it fell
+// behind the last time.
+func TestSendQueueCatchesUp(t *testing.T) {
+	const slowTimeOut = 50 * time.Millisecond
+	q := newSendQueue(100, slowTimeOut)
+	for i := 0; i < 2; i++ {
+		if err := q.push([]byte("data"), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	popAll(q)
+	time.Sleep(2 * slowTimeOut)
+	for i := 0; i < 2; i++ {
+		if err := q.push([]byte("data"), false); err != nil {
+			t.Fatalf("push %d got %v", i, err)
+		}
+	}
+	// Pushes which are sent right away never count as behind
+	q = newSendQueue(100, slowTimeOut)
+	for i := 0; i < 3; i++ {
+		if err := q.push([]byte("data"), false); err != nil {
+			t.Fatalf("push %d got %v", i, err)
+		}
+		popAll(q)
+		time.Sleep(slowTimeOut)
+	}
+}
+
+func TestSendQueueWakesWorker(t *testing.T) {
+	q := newSendQueue(8, 0)
+	popped := make(chan string)
+	go func() {
+		for {
+			data, ok := q.pop()
+			if !ok {
+				close(popped)
+				return
+			}
+			popped <- string(data)
+		}
+	}()
+	if err := q.push([]byte("push"), false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-popped:
+		if data != "push" {
+			t.Fatalf("popped %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("worker wasn't woken by a push")
+	}
+	q.close()
+	select {
+	case data, ok := <-popped:
+		if ok {
+			t.Fatalf("popped %s after close", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("worker wasn't woken by close")
+	}
+	// Pushes to a closed queue are dropped
+	if err := q.push([]byte("late"), false); err != nil {
+		t.Fatal(err)
+	}
+	if stats := q.getStats(); stats.QueueDepth != 0 {
+		t.Fatalf("closed queue kept a push %+v", stats)
+	}
+	if _, ok := q.pop(); ok {
+		t.Fatal("closed queue popped")
+	}
+}
+
+// A client which stops reading is evicted, the others keep getting pushes.
+func TestMuxServerEvictsStalledClient(t *testing.T) {
+	limits := DefaultLimits()
+	limits.SlowClientTimeOut = 200 * time.Millisecond
+	p := &testProcess{}
+	s := NewMuxServer("127.0.0.1:0", limits, p.process).(*muxServer)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := NewMuxClient(s.tcpListener.Addr().String()).(*muxClient)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	stalledID := randString(clientIDLength)
+	stalled := dialAs(t, "127.0.0.1", s.tcpListener.Addr(), stalledID)
+	defer stalled.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(s.GetClientIDs()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("stalled client didn't connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Random data doesn't compress, the socket buffers of the stalled client
+	// fill up after a few pushes
+	data := make([]byte, 1<<17)
+	rand.Read(data)
+	received := 0
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		s.Multicast([]string{c.id, stalledID}, data)
+		select {
+		case <-c.Listen():
+			received++
+		case <-time.After(time.Second):
+			t.Fatalf("client lost push %d", received)
+		}
+		if _, exists := s.getClient(stalledID); !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stalled client wasn't evicted")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if counts := s.GetErrorCounts(); counts.SlowClient != 1 {
+		t.Fatalf("eviction wasn't counted %+v", counts)
+	}
+	s.SendTo(c.id, []byte("after"))
+	expectPush(t, c, "after")
+	if resp, err := c.Send([]byte("bye")); err != nil || string(resp) != "bye" {
+		t.Fatalf("client got %q %v", resp, err)
+	}
+}
@@ -3,36 +3,48 @@ package network
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/go-logger"
 )
 
-func NewServer(tcpAddrA, tcpAddrB string, process Process) Server {
+func NewServer(tcpAddrA, tcpAddrB string, limits Limits, process Process) Server {
 	return &server{
-		tcpAddrA:      tcpAddrA,
-		tcpAddrB:      tcpAddrB,
-		process:       process,
-		closeSig:      make(chan bool, 1),
-		clientConnMap: make(map[string]chan *Connection),
-		clientBuffMap: make(map[string]chan []byte),
+		tcpAddrA:       tcpAddrA,
+		tcpAddrB:       tcpAddrB,
+		limits:         limits,
+		process:        process,
+		closeSig:       make(chan bool, 1),
+		clientConnMap:  make(map[string]chan *Connection),
+		clientQueueMap: make(map[string]*sendQueue),
+		clientHostMap:  make(map[string]string),
+		clientConnAMap: make(map[string]int),
+		clientConnBMap: make(map[string]int),
 	}
 }
 
 type server struct {
-	tcpAddrA      string
-	tcpAddrB      string
-	process       Process
-	closeSig      chan bool
-	tcpListenerA  net.Listener
-	tcpListenerB  net.Listener
-	clientConnMap map[string]chan *Connection
-	clientBuffMap map[string]chan []byte
-	clientLock    sync.RWMutex
-	isClosed      bool
+	tcpAddrA       string
+	tcpAddrB       string
+	limits         Limits
+	errorCounter   errorCounter
+	process        Process
+	closeSig       chan bool
+	tcpListenerA   net.Listener
+	tcpListenerB   net.Listener
+	clientConnMap  map[string]chan *Connection
+	clientQueueMap map[string]*sendQueue
+	// host which a client id is bound to, and its counts of connections
+	clientHostMap  map[string]string
+	clientConnAMap map[string]int
+	clientConnBMap map[string]int
+	clientLock     sync.RWMutex
+	isClosed       int32
 }
 
 func (s *server) Start() (err error) {
@@ -53,97 +65,201 @@ func (s *server) Start() (err error) {
 }
 
 func (s *server) listenTCPA() {
-	for !s.isClosed {
+	for atomic.LoadInt32(&s.isClosed) == 0 {
 		conn, err := s.tcpListenerA.Accept()
 		if err != nil {
 			logger.Errorf(context.Background(), err.Error())
 		} else {
 			logger.Infof(context.Background(), "connection is created via tcp (a)|%+v", conn.RemoteAddr())
-			go s.handleTCP(NewConnection(conn))
+			go s.handleTCP(NewConnection(conn, s.limits))
 		}
 	}
 }
 
 func (s *server) handleTCP(conn *Connection) {
-	ctx := context.Background()
+	clientID, err := readClientID(conn)
+	if err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	if err := s.addClientConnA(clientID, conn); err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	defer s.removeClientConnA(clientID)
 	for {
-		var err error
 		// Read data
 		req, err := conn.Read()
 		if err != nil {
-			logger.Errorf(ctx, err.Error())
+			closeConnection(&s.errorCounter, conn, clientID, err)
 			return
 		}
 		// Uncompress data
-		if req, err = uncompressData(req); err != nil {
-			logger.Errorf(ctx, err.Error())
+		if req, err = uncompressData(req, s.limits.MaxDecompressedSize); err != nil {
+			closeConnection(&s.errorCounter, conn, clientID, err)
 			return
 		}
 		// Process data
-		resp := s.process(req)
+		resp := s.process(clientID, req)
 		// Compress data
 		if resp, err = compressData(resp); err != nil {
-			logger.Errorf(ctx, err.Error())
+			closeConnection(&s.errorCounter, conn, clientID, err)
 			return
 		}
 		// Write data
 		if err = conn.Write(resp); err != nil {
-			logger.Errorf(ctx, err.Error())
+			closeConnection(&s.errorCounter, conn, clientID, err)
 			return
 		}
 	}
 }
 
-func (s *server) addClientPool(clientID string, conn *Connection) {
+// readClientID reads the first frame of a connection, it must be a client
+// id made by randString.
+func readClientID(conn *Connection) (clientID string, err error) {
+	clientIDBytes, err := conn.Read()
+	if err != nil {
+		return "", err
+	}
+	clientID = string(clientIDBytes)
+	if !isValidClientID(clientID) {
+		return "", errInvalidClientID
+	}
+	return clientID, nil
+}
+
+// closeConnection tears a connection down and counts why, a peer closing
+// the connection is not an error.
+func closeConnection(counter *errorCounter, conn *Connection, clientID string, err error) {
+	ctx := context.Background()
+	counter.add(err)
+	if err == io.EOF {
+		logger.Debugf(ctx, "client_id:%s|connection is closed|%+v", clientID, conn.RemoteAddr())
+	} else {
+		logger.Errorf(ctx, "client_id:%s|close connection|%+v|err:%v|errors:%+v",
+			clientID, conn.RemoteAddr(), err, counter.get())
+	}
+	if err := conn.Close(); err != nil {
+		logger.Debugf(ctx, err.Error())
+	}
+}
+
+func (s *server) GetErrorCounts() ErrorCounts {
+	return s.errorCounter.get()
+}
+
+// bindClientHost must be called with clientLock held. A client stays bound
+// to the host of its first connection, anyone else who knows its id could
+// otherwise send requests as the client and take its pushes.
+func (s *server) bindClientHost(clientID string, conn *Connection) error {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return err
+	}
+	if boundHost, exists := s.clientHostMap[clientID]; exists && boundHost != host {
+		return errClientHostMismatch
+	}
+	s.clientHostMap[clientID] = host
+	return nil
+}
+
+// addClientConnA allows as many request connections as the client pools
+func (s *server) addClientConnA(clientID string, conn *Connection) error {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if err := s.bindClientHost(clientID, conn); err != nil {
+		return err
+	}
+	if s.clientConnAMap[clientID] >= poolSize {
+		return errTooManyConnections
+	}
+	s.clientConnAMap[clientID]++
+	return nil
+}
+
+func (s *server) removeClientConnA(clientID string) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if s.clientConnAMap[clientID]--; s.clientConnAMap[clientID] > 0 {
+		return
+	}
+	delete(s.clientConnAMap, clientID)
+	if _, exists := s.clientConnMap[clientID]; !exists {
+		delete(s.clientHostMap, clientID)
+	}
+}
+
+func (s *server) addClientPool(clientID string, conn *Connection) error {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
+	if err := s.bindClientHost(clientID, conn); err != nil {
+		return err
+	}
+	// Connections which are writing aren't in the pool, so count them
+	if s.clientConnBMap[clientID] >= poolSize {
+		return errTooManyConnections
+	}
 	chPool := make(chan *Connection, poolSize)
 	if pool, exists := s.clientConnMap[clientID]; exists {
 		chPool = pool
 	} else {
 		s.clientConnMap[clientID] = chPool
 	}
+	s.clientConnBMap[clientID]++
 	chPool <- conn
+	return nil
 }
 
-func (s *server) sendToClient(clientID string, data []byte) (err error) {
-	s.clientLock.RLock()
-	defer s.clientLock.RUnlock()
-	var pool chan *Connection
-	if p, exists := s.clientConnMap[clientID]; exists {
-		pool = p
-	} else {
-		return errors.New("client connection pool not found")
+func (s *server) removeClientConnB(clientID string) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if s.clientConnBMap[clientID] > 0 {
+		s.clientConnBMap[clientID]--
 	}
-	timeout := time.After(time.Second)
+}
+
+func (s *server) sendToClient(clientID string, pool chan *Connection, data []byte) (err error) {
 	var conn *Connection
 	select {
-	case <-timeout:
-		s.clientLock.RUnlock()
-		s.clientLock.Lock()
-		if buffer, exists := s.clientBuffMap[clientID]; exists {
-			close(buffer)
-		}
-		delete(s.clientBuffMap, clientID)
-		delete(s.clientConnMap, clientID)
-		s.clientLock.Unlock()
-		s.clientLock.RLock()
+	case <-time.After(time.Second):
+		s.removeClient(clientID)
 		return errors.New("no connection available")
 	case conn = <-pool:
 	}
-	// Compress data
-	if data, err = compressData(data); err != nil {
-		return err
-	}
-	// Write data
+	// Write data, a broken connection leaves the pool
 	if err = conn.Write(data); err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		s.removeClientConnB(clientID)
 		return err
 	}
 	pool <- conn
 	return nil
 }
 
-func (s *server) getClientIDs() (list []string) {
+func (s *server) removeClient(clientID string) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	if queue, exists := s.clientQueueMap[clientID]; exists {
+		queue.close()
+	}
+	delete(s.clientQueueMap, clientID)
+	delete(s.clientConnMap, clientID)
+	delete(s.clientConnBMap, clientID)
+	if s.clientConnAMap[clientID] == 0 {
+		delete(s.clientHostMap, clientID)
+	}
+}
+
+// evictClient drops a client which can't keep up, its worker closes the
+// connections once it stops.
+func (s *server) evictClient(clientID string, err error) {
+	s.errorCounter.add(err)
+	logger.Errorf(context.Background(), "client_id:%s|evict client|err:%v|stats:%+v",
+		clientID, err, s.GetClientStats()[clientID])
+	s.removeClient(clientID)
+}
+
+func (s *server) GetClientIDs() (list []string) {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
 	for clientID := range s.clientConnMap {
@@ -154,24 +270,30 @@ func (s *server) getClientIDs() (list []string) {
 
 func (s *server) listenTCPB() {
 	ctx := context.Background()
-	for !s.isClosed {
+	for atomic.LoadInt32(&s.isClosed) == 0 {
 		c, err := s.tcpListenerB.Accept()
 		if err != nil {
 			logger.Errorf(ctx, err.Error())
 			continue
 		}
-		conn := NewConnection(c)
-		clientIDBytes, err := conn.Read()
-		if err != nil {
-			logger.Errorf(ctx, err.Error())
-			continue
-		}
-		clientID := string(clientIDBytes)
-		logger.Infof(ctx, "client_id:%s|connection is created via tcp (b)|%+v", clientID, conn.RemoteAddr())
-		s.addClientPool(clientID, conn)
-		if s.newBuffer(clientID) {
-			go s.startBroadcastWorker(clientID)
-		}
+		// A slow client must not block accepting others
+		go s.handleTCPB(NewConnection(c, s.limits))
+	}
+}
+
+func (s *server) handleTCPB(conn *Connection) {
+	clientID, err := readClientID(conn)
+	if err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	if err := s.addClientPool(clientID, conn); err != nil {
+		closeConnection(&s.errorCounter, conn, clientID, err)
+		return
+	}
+	logger.Infof(context.Background(), "client_id:%s|connection is created via tcp (b)|%+v", clientID, conn.RemoteAddr())
+	if queue, pool, ok := s.newQueue(clientID); ok {
+		go s.startBroadcastWorker(clientID, queue, pool)
 	}
 }
 
@@ -186,51 +308,91 @@ func (s *server) Close() error {
 	if err := s.tcpListenerB.Close(); err != nil {
 		return err
 	}
-	s.isClosed = true
+	atomic.StoreInt32(&s.isClosed, 1)
 	s.closeSig <- true
 	return nil
 }
 
-func (s *server) getBuffer(clientID string) (buffer chan []byte, exists bool) {
+func (s *server) getQueue(clientID string) (queue *sendQueue, exists bool) {
 	s.clientLock.RLock()
 	defer s.clientLock.RUnlock()
-	buffer, exists = s.clientBuffMap[clientID]
-	if exists {
-		return buffer, true
-	}
-	return nil, false
+	queue, exists = s.clientQueueMap[clientID]
+	return queue, exists
 }
 
-func (s *server) newBuffer(clientID string) (ok bool) {
+func (s *server) newQueue(clientID string) (queue *sendQueue, pool chan *Connection, ok bool) {
 	s.clientLock.Lock()
 	defer s.clientLock.Unlock()
-	if _, exists := s.clientBuffMap[clientID]; !exists {
-		buffer := make(chan []byte, config.BufferSize)
-		s.clientBuffMap[clientID] = buffer
-		return true
+	if _, exists := s.clientQueueMap[clientID]; exists {
+		return nil, nil, false
 	}
-	return false
+	queue = newSendQueue(config.BufferSize, s.limits.SlowClientTimeOut)
+	s.clientQueueMap[clientID] = queue
+	return queue, s.clientConnMap[clientID], true
 }
 
-func (s *server) startBroadcastWorker(clientID string) {
+func (s *server) startBroadcastWorker(clientID string, queue *sendQueue, pool chan *Connection) {
 	ctx := context.Background()
-	buffer, exists := s.getBuffer(clientID)
-	if !exists {
-		logger.Errorf(ctx, "client_id:%s|err:buffer not found", clientID)
-		return
-	}
-	for data := range buffer {
-		if err := s.sendToClient(clientID, data); err != nil {
+	for {
+		data, ok := queue.pop()
+		if !ok {
+			break
+		}
+		if err := s.sendToClient(clientID, pool, data); err != nil {
 			logger.Errorf(ctx, "client_id:%s|err:%v", clientID, err)
 		}
 	}
+	// Nothing is written anymore, close connections left in the pool
+	for {
+		select {
+		case conn := <-pool:
+			if err := conn.Close(); err != nil {
+				logger.Debugf(ctx, err.Error())
+			}
+		default:
+			return
+		}
+	}
 }
 
 func (s *server) Broadcast(data []byte) {
-	clientIDs := s.getClientIDs()
+	s.Multicast(s.GetClientIDs(), data)
+}
+
+func (s *server) Multicast(clientIDs []string, data []byte) {
+	s.multicast(clientIDs, data, false)
+}
+
+func (s *server) MulticastLatest(clientIDs []string, data []byte) {
+	s.multicast(clientIDs, data, true)
+}
+
+func (s *server) multicast(clientIDs []string, data []byte, latest bool) {
+	// Compress data once for all clients
+	data, err := compressData(data)
+	if err != nil {
+		logger.Errorf(context.Background(), err.Error())
+		return
+	}
 	for _, clientID := range clientIDs {
-		if buffer, exists := s.getBuffer(clientID); exists {
-			buffer <- data
+		if queue, exists := s.getQueue(clientID); exists {
+			if err := queue.push(data, latest); err != nil {
+				s.evictClient(clientID, err)
+			}
 		}
 	}
 }
+
+func (s *server) SendTo(clientID string, data []byte) {
+	s.Multicast([]string{clientID}, data)
+}
+
+func (s *server) GetClientStats() map[string]ClientStats {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	stats := make(map[string]ClientStats)
+	for clientID, queue := range s.clientQueueMap {
+		stats[clientID] = queue.getStats()
+	}
+	return stats
+}
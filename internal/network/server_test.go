@@ -0,0 +1,150 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testProcess echoes requests and remembers them
+type testProcess struct {
+	reqs []string
+	lock sync.Mutex
+}
+
+func (p *testProcess) process(clientID string, req []byte) []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reqs = append(p.reqs, string(req))
+	return req
+}
+
+func (p *testProcess) hasProcessed(req string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, r := range p.reqs {
+		if r == req {
+			return true
+		}
+	}
+	return false
+}
+
+// dialAs opens a connection from host which claims to be clientID
+func dialAs(t *testing.T, host string, addr net.Addr, clientID string) *Connection {
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(host)}}
+	tcpConn, err := dialer.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := NewConnection(tcpConn, DefaultLimits())
+	if err := conn.Write([]byte(clientID)); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// expectClosed fails unless the server closes conn without sending anything
+func expectClosed(t *testing.T, conn *Connection, name string) {
+	conn.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if data, err := conn.Read(); err == nil {
+		t.Fatalf("%s got %q", name, data)
+	} else if isTimeout(err) {
+		t.Fatalf("%s wasn't closed", name)
+	}
+}
+
+func expectPush(t *testing.T, c Client, data string) {
+	select {
+	case got := <-c.Listen():
+		if string(got) != data {
+			t.Fatalf("client got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client lost its push")
+	}
+}
+
+// A second connection with the id of a connected client, from another host
+// or beyond what the client opened itself, must neither run requests as the
+// client nor take its pushes.
+func TestServerRejectsSpoofedClient(t *testing.T) {
+	p := &testProcess{}
+	s := NewServer("127.0.0.1:0", "127.0.0.1:0", DefaultLimits(), p.process).(*server)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := NewClient(s.tcpListenerA.Addr().String(), s.tcpListenerB.Addr().String()).(*client)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.clientLock.RLock()
+		count := s.clientConnBMap[c.id]
+		s.clientLock.RUnlock()
+		if count == poolSize {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server has %d push connections", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	req, _ := compressData([]byte("spoofed"))
+	for _, host := range []string{"127.0.0.2", "127.0.0.1"} {
+		connA := dialAs(t, host, s.tcpListenerA.Addr(), c.id)
+		defer connA.Close()
+		// The request may be written before the server closes the connection
+		_ = connA.Write(req)
+		expectClosed(t, connA, "request connection from "+host)
+		connB := dialAs(t, host, s.tcpListenerB.Addr(), c.id)
+		defer connB.Close()
+		expectClosed(t, connB, "push connection from "+host)
+	}
+	if p.hasProcessed("spoofed") {
+		t.Fatal("spoofed request was processed")
+	}
+	for i := 0; i < 2*poolSize; i++ {
+		s.SendTo(c.id, []byte("push"))
+		expectPush(t, c, "push")
+	}
+	if resp, err := c.Send([]byte("bye")); err != nil || string(resp) != "bye" {
+		t.Fatalf("client got %q %v", resp, err)
+	}
+}
+
+func TestMuxServerRejectsSpoofedClient(t *testing.T) {
+	p := &testProcess{}
+	s := NewMuxServer("127.0.0.1:0", DefaultLimits(), p.process).(*muxServer)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := NewMuxClient(s.tcpListener.Addr().String()).(*muxClient)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := compressData([]byte("spoofed"))
+	for _, host := range []string{"127.0.0.2", "127.0.0.1"} {
+		conn := dialAs(t, host, s.tcpListener.Addr(), c.id)
+		defer conn.Close()
+		_ = conn.Write(makeMuxFrame(muxFrameRequest, 1, req))
+		expectClosed(t, conn, "connection from "+host)
+	}
+	if p.hasProcessed("spoofed") {
+		t.Fatal("spoofed request was processed")
+	}
+	s.SendTo(c.id, []byte("push"))
+	expectPush(t, c, "push")
+}
@@ -0,0 +1,191 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	udpPacketHello     = 1
+	udpPacketHelloAck  = 2
+	udpPacketRequest   = 3
+	udpPacketResponse  = 4
+	udpPacketBroadcast = 5
+	// type, seq, fragment index, fragment count
+	udpHeaderSize = 9
+	// client id follows the header on packets sent by clients
+	udpClientHeaderSize = udpHeaderSize + clientIDLength
+	// keep datagrams below common path mtu
+	udpMaxFragmentSize  = 1200
+	udpMaxFragmentCount = 512
+	udpReadBufferSize   = 2048
+	udpAssembleTimeout  = 2 * time.Second
+	udpRetryInterval    = 100 * time.Millisecond
+	udpMaxRetries       = 10
+	udpRequestTimeout   = 500 * time.Millisecond
+	udpClientTimeOut    = 30 * time.Second
+	udpResponseCache    = 64
+	// partial data kept per peer, the oldest is dropped for a new one
+	udpMaxPartials = 16
+)
+
+var (
+	errUDPTimeout      = errors.New("udp request timed out")
+	errUDPAddrMismatch = errors.New("udp client id is used from another address")
+)
+
+type udpHeader struct {
+	packetType byte
+	seq        uint32
+	fragIndex  uint16
+	fragCount  uint16
+	clientID   string
+}
+
+func readUDPHeader(packet []byte, fromClient bool) (h udpHeader, payload []byte, err error) {
+	size := udpHeaderSize
+	if fromClient {
+		size = udpClientHeaderSize
+	}
+	if len(packet) < size {
+		return h, nil, errors.New("udp packet is too short")
+	}
+	h.packetType = packet[0]
+	h.seq = binary.LittleEndian.Uint32(packet[1:5])
+	h.fragIndex = binary.LittleEndian.Uint16(packet[5:7])
+	h.fragCount = binary.LittleEndian.Uint16(packet[7:9])
+	if fromClient {
+		h.clientID = string(packet[udpHeaderSize:udpClientHeaderSize])
+	}
+	if h.fragCount == 0 || h.fragCount > udpMaxFragmentCount || h.fragIndex >= h.fragCount ||
+		len(packet)-size > udpMaxFragmentSize {
+		return h, nil, errors.New("udp packet has invalid fragment")
+	}
+	return h, packet[size:], nil
+}
+
+// makeUDPPackets splits data into datagrams which share the same seq
+func makeUDPPackets(packetType byte, seq uint32, clientID string, data []byte) ([][]byte, error) {
+	count := (len(data) + udpMaxFragmentSize - 1) / udpMaxFragmentSize
+	if count == 0 {
+		count = 1
+	}
+	if count > udpMaxFragmentCount {
+		return nil, errors.New("udp data is too large")
+	}
+	packets := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		start := i * udpMaxFragmentSize
+		end := start + udpMaxFragmentSize
+		if end > len(data) {
+			end = len(data)
+		}
+		packet := make([]byte, udpHeaderSize, udpHeaderSize+len(clientID)+end-start)
+		packet[0] = packetType
+		binary.LittleEndian.PutUint32(packet[1:5], seq)
+		binary.LittleEndian.PutUint16(packet[5:7], uint16(i))
+		binary.LittleEndian.PutUint16(packet[7:9], uint16(count))
+		packet = append(packet, clientID...)
+		packet = append(packet, data[start:end]...)
+		packets = append(packets, packet)
+	}
+	return packets, nil
+}
+
+// udpAssembler joins fragments back together, partial data which does not
+// complete in time, or is the oldest of too many, is dropped.
+type udpAssembler struct {
+	partials map[uint64]*udpPartial
+}
+
+type udpPartial struct {
+	frags      [][]byte
+	received   int
+	createTime time.Time
+}
+
+func newUDPAssembler() *udpAssembler {
+	return &udpAssembler{
+		partials: make(map[uint64]*udpPartial),
+	}
+}
+
+func (a *udpAssembler) add(h udpHeader, payload []byte) (data []byte, ok bool) {
+	if h.fragCount == 1 {
+		// payload points into the read buffer
+		return append([]byte{}, payload...), true
+	}
+	now := time.Now()
+	key := uint64(h.packetType)<<32 | uint64(h.seq)
+	partial, exists := a.partials[key]
+	if !exists || len(partial.frags) != int(h.fragCount) {
+		a.clean(now)
+		if !exists && len(a.partials) >= udpMaxPartials {
+			a.dropOldest()
+		}
+		partial = &udpPartial{
+			frags:      make([][]byte, h.fragCount),
+			createTime: now,
+		}
+		a.partials[key] = partial
+	}
+	if partial.frags[h.fragIndex] != nil {
+		return nil, false
+	}
+	partial.frags[h.fragIndex] = append([]byte{}, payload...)
+	partial.received++
+	if partial.received < len(partial.frags) {
+		return nil, false
+	}
+	delete(a.partials, key)
+	for _, frag := range partial.frags {
+		data = append(data, frag...)
+	}
+	return data, true
+}
+
+func (a *udpAssembler) clean(now time.Time) {
+	for key, partial := range a.partials {
+		if now.Sub(partial.createTime) > udpAssembleTimeout {
+			delete(a.partials, key)
+		}
+	}
+}
+
+func (a *udpAssembler) dropOldest() {
+	var oldestKey uint64
+	var oldest *udpPartial
+	for key, partial := range a.partials {
+		if oldest == nil || partial.createTime.Before(oldest.createTime) {
+			oldestKey, oldest = key, partial
+		}
+	}
+	delete(a.partials, oldestKey)
+}
+
+// udpConn wraps a socket so packet loss can be simulated while debugging
+type udpConn struct {
+	conn     *net.UDPConn
+	lossRate float64
+}
+
+func (c *udpConn) writeTo(packets [][]byte, addr *net.UDPAddr) error {
+	for _, packet := range packets {
+		if c.lossRate > 0 && rand.Float64() < c.lossRate {
+			continue
+		}
+		var err error
+		if addr == nil {
+			_, err = c.conn.Write(packet)
+		} else {
+			_, err = c.conn.WriteToUDP(packet, addr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/go-logger"
+)
+
+func NewUDPClient(udpAddr string, lossRate float64) Client {
+	return &udpClient{
+		id:           randString(clientIDLength),
+		udpAddr:      udpAddr,
+		lossRate:     lossRate,
+		limits:       DefaultLimits(),
+		pendingMap:   make(map[uint32]chan []byte),
+		listenBuffer: make(chan []byte, listenBufferSize),
+		closeSig:     make(chan bool, 1),
+	}
+}
+
+type udpClient struct {
+	id           string
+	udpAddr      string
+	lossRate     float64
+	limits       Limits
+	conn         *udpConn
+	seq          uint32
+	pendingMap   map[uint32]chan []byte
+	pendingLock  sync.Mutex
+	lastBcastSeq uint32
+	listenBuffer chan []byte
+	closeSig     chan bool
+	// isClosed is written under lock, listen reads it without
+	isClosed int32
+	lock     sync.RWMutex
+}
+
+func (c *udpClient) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", c.udpAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return err
+	}
+	c.conn = &udpConn{conn: conn, lossRate: c.lossRate}
+	go c.listen()
+	// Say hello so the server knows where to broadcast
+	_, err = c.send(udpPacketHello, nil, udpMaxRetries)
+	return err
+}
+
+func (c *udpClient) Wait() {
+	<-c.closeSig
+}
+
+func (c *udpClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closeSig <- true
+	atomic.StoreInt32(&c.isClosed, 1)
+	close(c.listenBuffer)
+	return c.conn.conn.Close()
+}
+
+// Send retries until the response arrives, the server runs each request once
+func (c *udpClient) Send(req []byte) (resp []byte, err error) {
+	return c.sendRequest(req, udpMaxRetries)
+}
+
+// SendUnreliable gives up after the first attempt times out
+func (c *udpClient) SendUnreliable(req []byte) (resp []byte, err error) {
+	return c.sendRequest(req, 0)
+}
+
+func (c *udpClient) sendRequest(req []byte, retries int) (resp []byte, err error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if atomic.LoadInt32(&c.isClosed) == 1 {
+		return nil, fmt.Errorf("network client is closed")
+	}
+	// Compress data
+	if req, err = compressData(req); err != nil {
+		return nil, err
+	}
+	if resp, err = c.send(udpPacketRequest, req, retries); err != nil {
+		return nil, err
+	}
+	// Uncompress data
+	return uncompressData(resp, c.limits.MaxDecompressedSize)
+}
+
+func (c *udpClient) send(packetType byte, data []byte, retries int) (resp []byte, err error) {
+	seq := atomic.AddUint32(&c.seq, 1)
+	packets, err := makeUDPPackets(packetType, seq, c.id, data)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []byte, 1)
+	c.pendingLock.Lock()
+	c.pendingMap[seq] = ch
+	c.pendingLock.Unlock()
+	defer func() {
+		c.pendingLock.Lock()
+		delete(c.pendingMap, seq)
+		c.pendingLock.Unlock()
+	}()
+	timeout := udpRetryInterval
+	if retries == 0 {
+		timeout = udpRequestTimeout
+	}
+	for i := 0; i <= retries; i++ {
+		if err = c.conn.writeTo(packets, nil); err != nil {
+			return nil, err
+		}
+		select {
+		case resp = <-ch:
+			return resp, nil
+		case <-time.After(timeout):
+		}
+	}
+	return nil, errUDPTimeout
+}
+
+func (c *udpClient) listen() {
+	ctx := context.Background()
+	assembler := newUDPAssembler()
+	buffer := make([]byte, udpReadBufferSize)
+	for atomic.LoadInt32(&c.isClosed) == 0 {
+		n, err := c.conn.conn.Read(buffer)
+		if err != nil {
+			if atomic.LoadInt32(&c.isClosed) == 0 {
+				logger.Errorf(ctx, err.Error())
+			}
+			continue
+		}
+		h, payload, err := readUDPHeader(buffer[:n], false)
+		if err != nil {
+			logger.Debugf(ctx, err.Error())
+			continue
+		}
+		switch h.packetType {
+		case udpPacketHelloAck, udpPacketResponse:
+			if data, ok := assembler.add(h, payload); ok {
+				c.resolve(h.seq, data)
+			}
+		case udpPacketBroadcast:
+			// Drop broadcasts older than the last one
+			if h.seq <= c.lastBcastSeq {
+				continue
+			}
+			data, ok := assembler.add(h, payload)
+			if !ok {
+				continue
+			}
+			c.lastBcastSeq = h.seq
+			// Uncompress data
+			if data, err = uncompressData(data, c.limits.MaxDecompressedSize); err != nil {
+				logger.Errorf(ctx, err.Error())
+				continue
+			}
+			c.lock.RLock()
+			if atomic.LoadInt32(&c.isClosed) == 0 {
+				c.listenBuffer <- data
+			}
+			c.lock.RUnlock()
+		}
+	}
+}
+
+func (c *udpClient) resolve(seq uint32, data []byte) {
+	c.pendingLock.Lock()
+	defer c.pendingLock.Unlock()
+	if ch, exists := c.pendingMap[seq]; exists {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+func (c *udpClient) Listen() <-chan []byte {
+	return c.listenBuffer
+}
@@ -0,0 +1,285 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mr-panta/go-logger"
+)
+
+func NewUDPServer(udpAddr string, lossRate float64, limits Limits, process Process) Server {
+	return &udpServer{
+		udpAddr:   udpAddr,
+		lossRate:  lossRate,
+		limits:    limits,
+		process:   process,
+		closeSig:  make(chan bool, 1),
+		clientMap: make(map[string]*udpServerClient),
+	}
+}
+
+type udpServer struct {
+	udpAddr      string
+	lossRate     float64
+	limits       Limits
+	errorCounter errorCounter
+	process      Process
+	closeSig     chan bool
+	conn         *udpConn
+	clientMap    map[string]*udpServerClient
+	clientLock   sync.RWMutex
+	broadcastSeq uint32
+	isClosed     int32
+}
+
+type udpServerClient struct {
+	// addr and lastSeen are guarded by clientLock of the server
+	addr       *net.UDPAddr
+	lastSeen   time.Time
+	assembler  *udpAssembler
+	responses  map[uint32][]byte
+	respSeqs   []uint32
+	processing map[uint32]bool
+	lock       sync.Mutex
+}
+
+func (s *udpServer) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", s.udpAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	s.conn = &udpConn{conn: conn, lossRate: s.lossRate}
+	logger.Infof(context.Background(), "listen udp connection|%+v", conn.LocalAddr())
+	go s.listen()
+	return nil
+}
+
+func (s *udpServer) listen() {
+	ctx := context.Background()
+	buffer := make([]byte, udpReadBufferSize)
+	for atomic.LoadInt32(&s.isClosed) == 0 {
+		n, addr, err := s.conn.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if atomic.LoadInt32(&s.isClosed) == 0 {
+				logger.Errorf(ctx, err.Error())
+			}
+			continue
+		}
+		h, payload, err := readUDPHeader(buffer[:n], true)
+		if err != nil {
+			logger.Debugf(ctx, "%v|%v", err.Error(), addr)
+			continue
+		}
+		c, err := s.getClient(h.clientID, addr)
+		if err != nil {
+			s.errorCounter.add(err)
+			logger.Debugf(ctx, "client_id:%s|%v|%v", h.clientID, err.Error(), addr)
+			continue
+		}
+		switch h.packetType {
+		case udpPacketHello:
+			packets, _ := makeUDPPackets(udpPacketHelloAck, h.seq, "", nil)
+			if err := s.conn.writeTo(packets, addr); err != nil {
+				logger.Errorf(ctx, err.Error())
+			}
+		case udpPacketRequest:
+			// Fragments of a request which can't fit in a frame aren't kept
+			if int(h.fragCount-1)*udpMaxFragmentSize >= s.limits.MaxFrameSize {
+				s.errorCounter.add(errFrameTooLarge)
+				logger.Debugf(ctx, "client_id:%s|%v|frag_count:%d", h.clientID, errFrameTooLarge, h.fragCount)
+				continue
+			}
+			c.lock.Lock()
+			req, ok := c.assembler.add(h, payload)
+			c.lock.Unlock()
+			if ok && len(req) > s.limits.MaxFrameSize {
+				s.errorCounter.add(errFrameTooLarge)
+				logger.Debugf(ctx, "client_id:%s|%v|size:%d", h.clientID, errFrameTooLarge, len(req))
+				continue
+			}
+			if ok {
+				go s.handleRequest(h.clientID, c, h.seq, req)
+			}
+		}
+	}
+}
+
+// getClient returns the client which sent a packet. A client stays bound to
+// the address of its first packet, anyone else who knows its id could
+// otherwise redirect its pushes and responses to themselves.
+func (s *udpServer) getClient(clientID string, addr *net.UDPAddr) (*udpServerClient, error) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	c, exists := s.clientMap[clientID]
+	if !exists {
+		if !isValidClientID(clientID) {
+			return nil, errInvalidClientID
+		}
+		logger.Infof(context.Background(), "client_id:%s|connection is created via udp|%+v", clientID, addr)
+		c = &udpServerClient{
+			addr:       addr,
+			assembler:  newUDPAssembler(),
+			responses:  make(map[uint32][]byte),
+			processing: make(map[uint32]bool),
+		}
+		s.clientMap[clientID] = c
+	} else if !c.addr.IP.Equal(addr.IP) || c.addr.Port != addr.Port || c.addr.Zone != addr.Zone {
+		return nil, errUDPAddrMismatch
+	}
+	c.lastSeen = time.Now()
+	return c, nil
+}
+
+func (s *udpServer) getClientAddr(c *udpServerClient) *net.UDPAddr {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return c.addr
+}
+
+// handleRequest processes each seq once, retried requests get the cached resp
+func (s *udpServer) handleRequest(clientID string, c *udpServerClient, seq uint32, req []byte) {
+	ctx := context.Background()
+	c.lock.Lock()
+	if c.processing[seq] {
+		c.lock.Unlock()
+		return
+	}
+	resp, done := c.responses[seq]
+	if !done {
+		c.processing[seq] = true
+	}
+	c.lock.Unlock()
+	if !done {
+		var err error
+		resp, err = s.processRequest(clientID, req)
+		c.lock.Lock()
+		delete(c.processing, seq)
+		if err == nil {
+			c.responses[seq] = resp
+			c.respSeqs = append(c.respSeqs, seq)
+			if len(c.respSeqs) > udpResponseCache {
+				delete(c.responses, c.respSeqs[0])
+				c.respSeqs = c.respSeqs[1:]
+			}
+		}
+		c.lock.Unlock()
+		if err != nil {
+			logger.Errorf(ctx, err.Error())
+			return
+		}
+	}
+	// Write data
+	packets, err := makeUDPPackets(udpPacketResponse, seq, "", resp)
+	if err != nil {
+		logger.Errorf(ctx, err.Error())
+		return
+	}
+	if err := s.conn.writeTo(packets, s.getClientAddr(c)); err != nil {
+		logger.Errorf(ctx, err.Error())
+	}
+}
+
+func (s *udpServer) processRequest(clientID string, req []byte) (resp []byte, err error) {
+	// Uncompress data
+	if req, err = uncompressData(req, s.limits.MaxDecompressedSize); err != nil {
+		s.errorCounter.add(err)
+		return nil, err
+	}
+	// Process data
+	resp = s.process(clientID, req)
+	// Compress data
+	return compressData(resp)
+}
+
+func (s *udpServer) GetClientStats() map[string]ClientStats {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	stats := make(map[string]ClientStats)
+	for clientID := range s.clientMap {
+		stats[clientID] = ClientStats{}
+	}
+	return stats
+}
+
+func (s *udpServer) GetErrorCounts() ErrorCounts {
+	return s.errorCounter.get()
+}
+
+func (s *udpServer) Wait() {
+	<-s.closeSig
+}
+
+func (s *udpServer) Close() error {
+	atomic.StoreInt32(&s.isClosed, 1)
+	if err := s.conn.conn.Close(); err != nil {
+		return err
+	}
+	s.closeSig <- true
+	return nil
+}
+
+func (s *udpServer) GetClientIDs() (list []string) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	now := time.Now()
+	for clientID, c := range s.clientMap {
+		if now.Sub(c.lastSeen) > udpClientTimeOut {
+			delete(s.clientMap, clientID)
+			continue
+		}
+		list = append(list, clientID)
+	}
+	return list
+}
+
+func (s *udpServer) Broadcast(data []byte) {
+	s.Multicast(s.GetClientIDs(), data)
+}
+
+func (s *udpServer) Multicast(clientIDs []string, data []byte) {
+	ctx := context.Background()
+	// Compress data once for all clients
+	data, err := compressData(data)
+	if err != nil {
+		logger.Errorf(ctx, err.Error())
+		return
+	}
+	seq := atomic.AddUint32(&s.broadcastSeq, 1)
+	packets, err := makeUDPPackets(udpPacketBroadcast, seq, "", data)
+	if err != nil {
+		logger.Errorf(ctx, err.Error())
+		return
+	}
+	for _, clientID := range clientIDs {
+		s.clientLock.RLock()
+		c, exists := s.clientMap[clientID]
+		var addr *net.UDPAddr
+		if exists {
+			addr = c.addr
+		}
+		s.clientLock.RUnlock()
+		if !exists {
+			continue
+		}
+		if err := s.conn.writeTo(packets, addr); err != nil {
+			logger.Errorf(ctx, "client_id:%s|err:%v", clientID, err)
+		}
+	}
+}
+
+// MulticastLatest is the same as Multicast, datagrams are never queued and
+// clients drop broadcasts older than the last one
+func (s *udpServer) MulticastLatest(clientIDs []string, data []byte) {
+	s.Multicast(clientIDs, data)
+}
+
+func (s *udpServer) SendTo(clientID string, data []byte) {
+	s.Multicast([]string{clientID}, data)
+}
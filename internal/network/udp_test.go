@@ -0,0 +1,253 @@
+package network
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testUDPLossRate = 0.2
+
+// startTestUDPServer listens on a free loopback port, process echoes the
+// request back and counts how often it ran.
+func startTestUDPServer(t *testing.T, lossRate float64, processCount *int32) *udpServer {
+	s := NewUDPServer("127.0.0.1:0", lossRate, DefaultLimits(), func(clientID string, req []byte) []byte {
+		atomic.AddInt32(processCount, 1)
+		return req
+	}).(*udpServer)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func startTestUDPClient(t *testing.T, s *udpServer, lossRate float64) *udpClient {
+	c := NewUDPClient(s.conn.conn.LocalAddr().String(), lossRate).(*udpClient)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// newTestPayload doesn't compress, so it is split into several fragments
+func newTestPayload(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestUDPSendRetriesUnderLoss(t *testing.T) {
+	processCount := int32(0)
+	s := startTestUDPServer(t, testUDPLossRate, &processCount)
+	defer s.Close()
+	c := startTestUDPClient(t, s, testUDPLossRate)
+	defer c.Close()
+	for i := 0; i < 20; i++ {
+		req := []byte{byte(i)}
+		resp, err := c.Send(req)
+		if err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		if !bytes.Equal(resp, req) {
+			t.Fatalf("send %d: got %v", i, resp)
+		}
+	}
+	// Retries of a request which already ran get the cached response
+	if n := atomic.LoadInt32(&processCount); n != 20 {
+		t.Fatalf("requests were processed %d times", n)
+	}
+}
+
+func TestUDPFragmentReassemblyUnderLoss(t *testing.T) {
+	processCount := int32(0)
+	s := startTestUDPServer(t, testUDPLossRate, &processCount)
+	defer s.Close()
+	c := startTestUDPClient(t, s, testUDPLossRate)
+	defer c.Close()
+	req := newTestPayload(5 * udpMaxFragmentSize)
+	resp, err := c.Send(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, req) {
+		t.Fatalf("got %d bytes, want the %d bytes sent", len(resp), len(req))
+	}
+	// Broadcasts aren't retried, so send until a whole one arrives
+	data := newTestPayload(3 * udpMaxFragmentSize)
+	deadline := time.After(5 * time.Second)
+	for {
+		s.SendTo(c.id, data)
+		select {
+		case got := <-c.Listen():
+			if !bytes.Equal(got, data) {
+				t.Fatalf("got %d bytes, want the %d bytes sent", len(got), len(data))
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no broadcast was reassembled")
+		}
+	}
+}
+
+func TestUDPAssemblerIgnoresDuplicateFragments(t *testing.T) {
+	data := newTestPayload(3 * udpMaxFragmentSize)
+	packets, err := makeUDPPackets(udpPacketResponse, 7, "", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newUDPAssembler()
+	// Out of order and the first fragment twice
+	for _, i := range []int{2, 0, 0} {
+		h, payload, err := readUDPHeader(packets[i], false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := a.add(h, payload); ok {
+			t.Fatalf("completed without fragment 1")
+		}
+	}
+	h, payload, err := readUDPHeader(packets[1], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := a.add(h, payload)
+	if !ok || !bytes.Equal(got, data) {
+		t.Fatal("fragments weren't joined")
+	}
+}
+
+// A peer which starts many sends and never finishes them holds a bounded
+// number of them.
+func TestUDPAssemblerDropsOldestPartial(t *testing.T) {
+	data := newTestPayload(2 * udpMaxFragmentSize)
+	a := newUDPAssembler()
+	var lastPackets [][]byte
+	for seq := uint32(0); seq <= udpMaxPartials; seq++ {
+		packets, err := makeUDPPackets(udpPacketRequest, seq, "", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, payload, _ := readUDPHeader(packets[0], false)
+		a.add(h, payload)
+		lastPackets = packets
+	}
+	if len(a.partials) != udpMaxPartials {
+		t.Fatalf("kept %d partials", len(a.partials))
+	}
+	// The first send was dropped, the last one still completes
+	packets, _ := makeUDPPackets(udpPacketRequest, 0, "", data)
+	h, payload, _ := readUDPHeader(packets[1], false)
+	if _, ok := a.add(h, payload); ok {
+		t.Fatal("dropped partial was completed")
+	}
+	h, payload, _ = readUDPHeader(lastPackets[1], false)
+	if got, ok := a.add(h, payload); !ok || !bytes.Equal(got, data) {
+		t.Fatal("last partial wasn't completed")
+	}
+}
+
+func TestUDPServerRejectsLargeFrames(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxFrameSize = udpMaxFragmentSize + udpMaxFragmentSize/4
+	processCount := int32(0)
+	s := NewUDPServer("127.0.0.1:0", 0, limits, func(clientID string, req []byte) []byte {
+		atomic.AddInt32(&processCount, 1)
+		return req
+	}).(*udpServer)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := net.DialUDP("udp", nil, s.conn.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	clientID := randString(clientIDLength)
+	cases := []struct {
+		name     string
+		size     int
+		accepted bool
+	}{
+		// Every fragment is dropped before it is kept
+		{"too many fragments", 4*udpMaxFragmentSize - 100, false},
+		// The fragments fit, what they join to doesn't
+		{"joined too large", 3 * udpMaxFragmentSize / 2, false},
+		{"small enough", udpMaxFragmentSize / 2, true},
+	}
+	for i, c := range cases {
+		req, _ := compressData(newTestPayload(c.size))
+		packets, err := makeUDPPackets(udpPacketRequest, uint32(i), clientID, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, packet := range packets {
+			if _, err := conn.Write(packet); err != nil {
+				t.Fatal(err)
+			}
+		}
+		buffer := make([]byte, udpReadBufferSize)
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		if _, err := conn.Read(buffer); (err == nil) != c.accepted {
+			t.Fatalf("%s: read got %v", c.name, err)
+		}
+	}
+	if n := atomic.LoadInt32(&processCount); n != 1 {
+		t.Fatalf("requests were processed %d times", n)
+	}
+	// One for each of the 4 fragments of the first request, one for the joined
+	// second one
+	if counts := s.GetErrorCounts(); counts.FrameTooLarge != 5 {
+		t.Fatalf("large frames were counted %+v", counts)
+	}
+}
+
+// A packet with the id of a known client from another address must not take
+// the client over.
+func TestUDPServerKeepsClientAddr(t *testing.T) {
+	processCount := int32(0)
+	s := startTestUDPServer(t, 0, &processCount)
+	defer s.Close()
+	c := startTestUDPClient(t, s, 0)
+	defer c.Close()
+	spoofer, err := net.DialUDP("udp", nil, s.conn.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spoofer.Close()
+	for _, packetType := range []byte{udpPacketHello, udpPacketRequest} {
+		req, _ := compressData([]byte("spoofed"))
+		packets, err := makeUDPPackets(packetType, 1000, c.id, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := spoofer.Write(packets[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	s.SendTo(c.id, []byte("push"))
+	select {
+	case got := <-c.Listen():
+		if string(got) != "push" {
+			t.Fatalf("client got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client lost its push")
+	}
+	buffer := make([]byte, udpReadBufferSize)
+	spoofer.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := spoofer.Read(buffer); err == nil {
+		t.Fatalf("spoofer got %d bytes", n)
+	}
+	if n := atomic.LoadInt32(&processCount); n != 0 {
+		t.Fatalf("spoofed request was processed %d times", n)
+	}
+	if counts := s.GetErrorCounts(); counts.Malformed != 2 {
+		t.Fatalf("spoofed packets were counted %+v", counts)
+	}
+}
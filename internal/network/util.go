@@ -3,6 +3,7 @@ package network
 import (
 	"bytes"
 	"compress/zlib"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"time"
@@ -34,15 +35,23 @@ func compressData(data []byte) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-func uncompressData(data []byte) ([]byte, error) {
-	b := &bytes.Buffer{}
-	if _, err := b.Write(data); err != nil {
+// uncompressData stops inflating after maxSize bytes, so a small frame can't
+// expand into all of the memory. Zero maxSize means no limit.
+func uncompressData(data []byte, maxSize int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
 		return nil, err
 	}
-	r, err := zlib.NewReader(b)
+	defer r.Close()
+	if maxSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(r, int64(maxSize)+1))
 	if err != nil {
 		return nil, err
 	}
-	defer r.Close()
-	return ioutil.ReadAll(r)
+	if len(data) > maxSize {
+		return nil, errDecompressedTooLarge
+	}
+	return data, nil
 }
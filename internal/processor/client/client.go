@@ -5,34 +5,44 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/faiface/beep/speaker"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/animation"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/clientnet"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/menu"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/sound"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/go-logger"
 	"golang.org/x/image/colornames"
 )
 
 type clientProcessor struct {
-	win          *pixelgl.Window
-	restartSig   chan bool
-	restartCount int
-	menu         common.Menu
-	world        common.World
-	client       ClientNetwork
-	started      bool
-	worldID      string
-	playerName   string
-	hostIP       string
+	win           *window
+	restartSig    chan bool
+	restartCount  int
+	menu          common.Menu
+	world         common.World
+	clock         *ticktime.Clock
+	client        clientnet.ClientNetwork
+	started       bool
+	worldID       string
+	sessionToken  string
+	playerTimeOut time.Duration
+	playerName    string
+	roomName      string
+	hostIP        string
+	replay        *replayState
 }
 
 func NewClientProcessor() (processor common.ClientProcessor, err error) {
 	p := &clientProcessor{
 		restartSig: make(chan bool),
+		// Animations and the menu read the default clock
+		clock: ticktime.GetDefaultClock(),
 	}
 	cfg := config.GetConfig()
 	// Create win
@@ -41,19 +51,24 @@ func NewClientProcessor() (processor common.ClientProcessor, err error) {
 		Bounds:    pixel.R(0, 0, cfg.WindowWidth, cfg.WindowHeight),
 		Resizable: true,
 	}
-	if p.win, err = pixelgl.NewWindow(winCfg); err != nil {
+	win, err := pixelgl.NewWindow(winCfg)
+	if err != nil {
 		return nil, err
 	}
+	p.win = &window{Window: win}
 	// Load sprite
 	if err := animation.LoadAllSprites(); err != nil {
 		return nil, err
 	}
 	// Load sound
-	if err := sound.LoadAllSounds(); err != nil {
+	if err := speaker.Init(sound.SampleRate, sound.SampleRate.N(time.Second/20)); err != nil {
+		return nil, err
+	}
+	if err := sound.LoadAllSounds(speaker.Play); err != nil {
 		return nil, err
 	}
 	// Create menu
-	p.menu = menu.New(p)
+	p.menu = menu.New(p, p.win.Window)
 	return p, nil
 }
 
@@ -73,10 +88,11 @@ func (p *clientProcessor) Restart() {
 }
 
 func (p *clientProcessor) Close() {
+	p.leaveWorld()
 	p.restartSig <- true
 }
 
-func (p *clientProcessor) GetWindow() *pixelgl.Window {
+func (p *clientProcessor) GetWindow() common.Window {
 	return p.win
 }
 
@@ -91,12 +107,15 @@ func (p *clientProcessor) Run() {
 	}
 }
 
-func (p *clientProcessor) StartWorld(hostIP, playerName string) (err error) {
+// StartWorld joins a room by name, the room is created when it doesn't
+// exist. An empty room name joins the default room of the server.
+func (p *clientProcessor) StartWorld(hostIP, roomName, playerName string) (err error) {
 	p.playerName = playerName
+	p.roomName = roomName
 	p.hostIP = hostIP
 	// Create network
 	success := false
-	p.client = NewClientNetwork(hostIP)
+	p.client = clientnet.NewClientNetwork(hostIP)
 	if err = p.client.Start(); err != nil {
 		logger.Debugf(nil, err.Error())
 		return errors.New("CAN'T CONNECT TO HOST")
@@ -109,7 +128,7 @@ func (p *clientProcessor) StartWorld(hostIP, playerName string) (err error) {
 		}
 	}()
 	// Register player and create world
-	if err := p.registerPlayer(playerName); err != nil {
+	if err := p.registerPlayer(roomName, playerName); err != nil {
 		return err
 	}
 	p.started = true
@@ -117,6 +136,7 @@ func (p *clientProcessor) StartWorld(hostIP, playerName string) (err error) {
 	p.win.SetCursorVisible(false)
 	go p.consumeWorldSnapshot()
 	go p.produceInputSnapshot()
+	go p.syncClock()
 	success = true
 	return nil
 }
@@ -129,9 +149,14 @@ func (p *clientProcessor) startUpdateLoop(restartCount int) {
 		}
 		if p.started && p.world != nil {
 			p.win.UpdateInput()
+			if p.replay != nil {
+				p.updateReplay()
+				p.world.ClientUpdate()
+				continue
+			}
 			if exists := p.world.ClientUpdate(); !exists {
 				_ = p.client.Close()
-				if err := p.StartWorld(p.hostIP, p.playerName); err != nil {
+				if err := p.StartWorld(p.hostIP, p.roomName, p.playerName); err != nil {
 					p.world = nil
 					p.started = false
 				}
@@ -158,6 +183,9 @@ func (p *clientProcessor) startRenderLoop(restartCount int) {
 		if p.started && p.world != nil {
 			p.world.Render()
 		}
+		if p.replay != nil {
+			p.renderReplay()
+		}
 		p.win.Update()
 	}
 }
@@ -168,10 +196,15 @@ func (p *clientProcessor) consumeWorldSnapshot() {
 		case protocol.CmdAddWorldSnapshot:
 			data := cmdData.Data.(*protocol.AddWorldSnapshotRequest)
 			if p.world != nil {
+				p.clock.AddSnapshotArrival(data.Tick)
 				p.world.SetSnapshot(data.Tick, data.WorldSnapshot)
+				p.world.SetGameEvents(data.Events)
 			} else {
 				break
 			}
+		case protocol.CmdDisconnect:
+			data := cmdData.Data.(*protocol.DisconnectRequest)
+			p.disconnect(data.Reason)
 		}
 	}
 }
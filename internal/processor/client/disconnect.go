@@ -0,0 +1,35 @@
+package client
+
+import (
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+// disconnect goes back to the menu and shows why, clearing worldID stops
+// producing inputs for the old world.
+func (p *clientProcessor) disconnect(reason int) {
+	logger.Debugf(nil, "disconnected|reason:%d", reason)
+	p.started = false
+	p.world = nil
+	p.worldID = ""
+	p.win.SetCursorVisible(true)
+	p.menu.SetMessage(protocol.GetDisconnectMessage(reason))
+	// The consumer may call this, it has to keep draining until the close
+	client := p.client
+	go func() {
+		if err := client.Close(); err != nil {
+			logger.Debugf(nil, err.Error())
+		}
+	}()
+}
+
+// leaveWorld tells the server that the player is gone, so it doesn't wait
+// for the player to time out.
+func (p *clientProcessor) leaveWorld() {
+	if !p.started || p.replay != nil || p.client == nil {
+		return
+	}
+	if err := p.client.Leave(); err != nil {
+		logger.Debugf(nil, err.Error())
+	}
+}
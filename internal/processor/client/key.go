@@ -0,0 +1,32 @@
+package client
+
+import (
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// key bindings, only the client reads the window
+const (
+	fireKey             = pixelgl.MouseButton1
+	meleeKey            = pixelgl.MouseButton2
+	upKey               = pixelgl.KeyW
+	leftKey             = pixelgl.KeyA
+	downKey             = pixelgl.KeyS
+	rightKey            = pixelgl.KeyD
+	reloadKey           = pixelgl.KeyR
+	dropKey             = pixelgl.KeyG
+	use1stItemKey       = pixelgl.Key1
+	use2ndItemKey       = pixelgl.Key2
+	use3rdItemKey       = pixelgl.Key3
+	toggleMuteKey       = pixelgl.KeyM
+	volumeUpKey         = pixelgl.KeyUp
+	volumeDownKey       = pixelgl.KeyDown
+	toggleFullScreenKey = pixelgl.KeyF10
+	toggleFPSLimitKey   = pixelgl.KeyF9
+	// replay
+	replayPauseKey       = pixelgl.KeySpace
+	replaySeekBackKey    = pixelgl.KeyLeft
+	replaySeekForwardKey = pixelgl.KeyRight
+	replaySlowerKey      = pixelgl.KeyLeftBracket
+	replayFasterKey      = pixelgl.KeyRightBracket
+	replayFollowNextKey  = pixelgl.KeyTab
+)
@@ -6,38 +6,44 @@ import (
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/world"
 	"github.com/mr-panta/go-logger"
 )
 
-func (c *clientProcessor) registerPlayer(playerName string) error {
-	now := time.Now()
-	r, err := c.client.Send(
-		protocol.CmdRegisterPlayer,
-		&protocol.RegisterPlayerRequest{
-			PlayerName: playerName,
-			Version:    config.Version,
-		},
-	)
-	ping := time.Since(now)
+func (c *clientProcessor) registerPlayer(roomName, playerName string) error {
+	if err := c.handshake(); err != nil {
+		return err
+	}
+	resp, ping, err := c.sendRegisterPlayer(roomName, playerName)
 	if err != nil {
-		logger.Debugf(nil, err.Error())
-		return errors.New("CAN'T REGISTER PLAYER")
+		return err
 	}
-	resp := r.(*protocol.RegisterPlayerResponse)
-	if !resp.OK {
-		return errors.New(resp.DebugMessage)
+	// A room which doesn't exist is created, then joined
+	if resp.ErrorCode == protocol.ErrorCodeRoomNotFound && roomName != "" {
+		if err := c.createRoom(roomName); err != nil {
+			return err
+		}
+		if resp, ping, err = c.sendRegisterPlayer(roomName, playerName); err != nil {
+			return err
+		}
+	}
+	if resp.ErrorCode != protocol.ErrorCodeNone {
+		return errors.New(protocol.GetErrorMessage(resp.ErrorCode))
+	}
+	if resp.Room != nil {
+		c.roomName = resp.Room.Name
 	}
 	c.worldID = resp.WorldSnapshot.ID
+	c.sessionToken = resp.SessionToken
+	c.playerTimeOut = time.Duration(resp.PlayerTimeOut)
 	serverTime := time.Unix(0, resp.ServerTime)
 	startTime := time.Unix(0, resp.StartTime)
-	ticktime.SetServerTime(serverTime, ping)
-	ticktime.SetServerStartTime(startTime)
+	c.clock.SetServerTime(serverTime, ping)
+	c.clock.SetServerStartTime(startTime)
 	// Set world
 	switch resp.WorldSnapshot.Type {
 	case config.DefaultWorld:
-		c.world = world.NewDefaultWorld(c, c.worldID)
+		c.world = world.NewDefaultWorld(c, c.clock, c.worldID, config.NewWorldConfig())
 	default:
 		return errors.New("UNKNOWN WORLD TYPE")
 	}
@@ -45,3 +51,32 @@ func (c *clientProcessor) registerPlayer(playerName string) error {
 	c.world.SetSnapshot(resp.Tick, resp.WorldSnapshot)
 	return nil
 }
+
+func (c *clientProcessor) sendRegisterPlayer(roomName, playerName string) (
+	resp *protocol.RegisterPlayerResponse, ping time.Duration, err error) {
+	resp, ping, err = c.client.RegisterPlayer(roomName, playerName)
+	if err != nil {
+		logger.Debugf(nil, err.Error())
+		return nil, 0, errors.New("CAN'T REGISTER PLAYER")
+	}
+	return resp, ping, nil
+}
+
+func (c *clientProcessor) handshake() error {
+	resp, err := c.client.Handshake(c.supportedCodecs())
+	if err != nil {
+		logger.Debugf(nil, err.Error())
+		return errors.New("CAN'T REGISTER PLAYER")
+	}
+	if resp.ErrorCode != protocol.ErrorCodeNone {
+		return errors.New(protocol.GetErrorMessage(resp.ErrorCode))
+	}
+	return nil
+}
+
+func (c *clientProcessor) supportedCodecs() []int {
+	if config.EnvJSONCodec() {
+		return []int{protocol.CodecJSON}
+	}
+	return protocol.GetSupportedCodecs()
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/animation"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/replay"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/world"
+	"github.com/mr-panta/go-logger"
+)
+
+const (
+	replaySeekStep = 5 * time.Second
+	replayMinSpeed = 0.25
+	replayMaxSpeed = 8
+)
+
+var replayKeys = []pixelgl.Button{
+	replayPauseKey,
+	replaySeekBackKey,
+	replaySeekForwardKey,
+	replaySlowerKey,
+	replayFasterKey,
+	replayFollowNextKey,
+}
+
+type replayState struct {
+	reader *replay.Reader
+	// time is the server time of the replay, it drives the clock so the
+	// world interpolates recorded snapshots the same way as live ones
+	time       time.Time
+	updateTime time.Time
+	speed      float64
+	paused     bool
+	prevKeys   map[pixelgl.Button]bool
+	txt        *text.Text
+}
+
+// StartReplay plays a replay file in place of a server, recorded snapshots
+// are fed to the world with SetSnapshot while the camera follows a player.
+func (p *clientProcessor) StartReplay(fileName string) error {
+	reader, err := replay.Open(fileName)
+	if err != nil {
+		logger.Debugf(nil, err.Error())
+		return errors.New("CAN'T OPEN REPLAY")
+	}
+	header := reader.GetHeader()
+	if header.GameVersion != config.Version {
+		return errors.New("REPLAY IS FROM ANOTHER VERSION")
+	}
+	if header.WorldType != config.DefaultWorld {
+		return errors.New("UNKNOWN WORLD TYPE")
+	}
+	p.clock.SetServerStartTime(time.Unix(0, header.StartTime))
+	p.replay = &replayState{
+		reader:   reader,
+		speed:    1,
+		prevKeys: make(map[pixelgl.Button]bool),
+		txt:      animation.NewText(),
+	}
+	p.seekReplay(reader.GetFirstTick())
+	p.started = true
+	p.win.SetSmooth(true)
+	return nil
+}
+
+func (p *clientProcessor) updateReplay() {
+	r := p.replay
+	now := time.Now()
+	if !r.paused {
+		d := float64(now.Sub(r.updateTime)) * r.speed
+		r.time = r.time.Add(time.Duration(d))
+	}
+	r.updateTime = now
+	p.updateReplayInput()
+	endTime := p.clock.GetTickTime(r.reader.GetLastTick())
+	if !r.time.Before(endTime) {
+		r.time = endTime
+		r.paused = true
+	}
+	p.clock.SetServerTime(r.time, 0)
+	p.feedReplay(p.world, true)
+	if p.world.GetMainPlayer() == nil {
+		p.followNextReplayPlayer()
+	}
+}
+
+func (p *clientProcessor) updateReplayInput() {
+	r := p.replay
+	justPressed := func(key pixelgl.Button) bool {
+		return p.win.Pressed(key) && !r.prevKeys[key]
+	}
+	switch {
+	case justPressed(replayPauseKey):
+		r.paused = !r.paused
+	case justPressed(replaySeekBackKey):
+		p.seekReplay(p.clock.GetTick(r.time.Add(-replaySeekStep)))
+	case justPressed(replaySeekForwardKey):
+		p.seekReplay(p.clock.GetTick(r.time.Add(replaySeekStep)))
+	case justPressed(replaySlowerKey):
+		r.speed = math.Max(r.speed/2, replayMinSpeed)
+	case justPressed(replayFasterKey):
+		r.speed = math.Min(r.speed*2, replayMaxSpeed)
+	case justPressed(replayFollowNextKey):
+		p.followNextReplayPlayer()
+	}
+	for _, key := range replayKeys {
+		r.prevKeys[key] = p.win.Pressed(key)
+	}
+}
+
+// seekReplay rebuilds the world from the keyframe before tick, objects keep
+// snapshots only in time order so the old world can't be reused.
+func (p *clientProcessor) seekReplay(tick int64) {
+	r := p.replay
+	if first := r.reader.GetFirstTick(); tick < first {
+		tick = first
+	}
+	if last := r.reader.GetLastTick(); tick > last {
+		tick = last
+	}
+	mainPlayerID := ""
+	if p.world != nil {
+		mainPlayerID = p.world.GetMainPlayerID()
+	}
+	header := r.reader.GetHeader()
+	w := world.NewDefaultWorld(p, p.clock, header.WorldID, config.NewWorldConfig())
+	r.time = p.clock.GetTickTime(tick)
+	r.updateTime = time.Now()
+	p.clock.SetServerTime(r.time, 0)
+	r.reader.SeekTick(tick)
+	// Events before the seek already happened
+	p.feedReplay(w, false)
+	// Only follow a player which already exists, a player created after
+	// SetMainPlayerID would become a predicted main player
+	if _, exists := w.GetObjectDB().SelectOne(mainPlayerID); exists {
+		w.SetMainPlayerID(mainPlayerID)
+	}
+	p.world = w
+}
+
+func (p *clientProcessor) feedReplay(w common.World, withEvents bool) {
+	r := p.replay
+	tick := p.clock.GetTick(r.time)
+	for {
+		nextTick, exists := r.reader.PeekTick()
+		if !exists || nextTick > tick {
+			return
+		}
+		frame, err := r.reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf(nil, err.Error())
+			}
+			return
+		}
+		w.SetSnapshot(frame.Tick, frame.WorldSnapshot)
+		if withEvents {
+			w.SetGameEvents(frame.Events)
+		}
+	}
+}
+
+func (p *clientProcessor) followNextReplayPlayer() {
+	playerIDs := []string{}
+	for _, o := range p.world.GetObjectDB().SelectAll() {
+		if o.GetType() == config.PlayerObject {
+			playerIDs = append(playerIDs, o.GetID())
+		}
+	}
+	if len(playerIDs) == 0 {
+		return
+	}
+	sort.Strings(playerIDs)
+	mainPlayerID := p.world.GetMainPlayerID()
+	index := sort.SearchStrings(playerIDs, mainPlayerID)
+	if index < len(playerIDs) && playerIDs[index] == mainPlayerID {
+		index++
+	}
+	p.world.SetMainPlayerID(playerIDs[index%len(playerIDs)])
+}
+
+func (p *clientProcessor) renderReplay() {
+	r := p.replay
+	elapsed := r.time.Sub(p.clock.GetTickTime(r.reader.GetFirstTick()))
+	length := p.clock.GetTickTime(r.reader.GetLastTick()).Sub(p.clock.GetTickTime(r.reader.GetFirstTick()))
+	status := fmt.Sprintf("REPLAY %s/%s x%.2f", formatReplayTime(elapsed), formatReplayTime(length), r.speed)
+	if r.paused {
+		status += " PAUSED"
+	}
+	animation.DrawShadowTextLeft(
+		r.txt,
+		p.win,
+		p.win.Bounds().Vertices()[1].Add(pixel.V(16, -32)),
+		status,
+		1,
+	)
+}
+
+func formatReplayTime(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
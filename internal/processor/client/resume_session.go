@@ -0,0 +1,85 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/clientnet"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+const (
+	clientMaxSendError = 10
+	clientResumePeriod = time.Second
+)
+
+var errSessionRejected = errors.New("session is rejected")
+
+// reconnect reopens the connection and resumes the session, so the player
+// keeps its stats and items. A new player is registered only when the server
+// doesn't know the session anymore.
+func (p *clientProcessor) reconnect() {
+	if err := p.client.Close(); err != nil {
+		logger.Debugf(nil, err.Error())
+	}
+	// The server keeps the player this long without input, older servers
+	// don't tell it
+	timeOut := p.playerTimeOut
+	if timeOut <= 0 {
+		timeOut = config.DefaultPlayerTimeOut
+	}
+	deadline := time.Now().Add(timeOut)
+	for time.Now().Before(deadline) {
+		err := p.resumeSession()
+		if err == nil {
+			return
+		}
+		logger.Debugf(nil, err.Error())
+		if err == errSessionRejected {
+			break
+		}
+		time.Sleep(clientResumePeriod)
+	}
+	if err := p.StartWorld(p.hostIP, p.roomName, p.playerName); err != nil {
+		p.disconnect(protocol.DisconnectReasonTimeOut)
+	}
+}
+
+func (p *clientProcessor) resumeSession() (err error) {
+	success := false
+	p.client = clientnet.NewClientNetwork(p.hostIP)
+	if err = p.client.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		if !success {
+			if e := p.client.Close(); e != nil {
+				logger.Debugf(nil, e.Error())
+			}
+		}
+	}()
+	if err := p.handshake(); err != nil {
+		return err
+	}
+	resp, ping, err := p.client.ResumeSession(p.sessionToken)
+	if err != nil {
+		return err
+	}
+	if resp.ErrorCode != protocol.ErrorCodeNone || resp.WorldSnapshot.ID != p.worldID {
+		return errSessionRejected
+	}
+	if resp.PlayerTimeOut > 0 {
+		p.playerTimeOut = time.Duration(resp.PlayerTimeOut)
+	}
+	p.clock.SetServerTime(time.Unix(0, resp.ServerTime), ping)
+	p.clock.SetServerStartTime(time.Unix(0, resp.StartTime))
+	// The world is kept, objects pick up from the new snapshot
+	p.world.SetSnapshot(resp.Tick, resp.WorldSnapshot)
+	go p.consumeWorldSnapshot()
+	go p.produceInputSnapshot()
+	go p.syncClock()
+	success = true
+	return nil
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/clientnet"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+// ListRooms asks a host for its rooms with a short lived connection, the
+// world which is played isn't affected.
+func (p *clientProcessor) ListRooms(hostIP string) (rooms []*protocol.RoomInfo, err error) {
+	client := clientnet.NewClientNetwork(hostIP)
+	if err = client.Start(); err != nil {
+		logger.Debugf(nil, err.Error())
+		return nil, errors.New("CAN'T CONNECT TO HOST")
+	}
+	defer func() {
+		if e := client.Close(); e != nil {
+			logger.Debugf(nil, e.Error())
+		}
+	}()
+	resp, err := client.ListRooms()
+	if err != nil {
+		logger.Debugf(nil, err.Error())
+		return nil, errors.New("CAN'T LIST ROOMS")
+	}
+	if resp.ErrorCode != protocol.ErrorCodeNone {
+		return nil, errors.New(protocol.GetErrorMessage(resp.ErrorCode))
+	}
+	return resp.Rooms, nil
+}
+
+func (p *clientProcessor) createRoom(name string) error {
+	resp, err := p.client.CreateRoom(name)
+	if err != nil {
+		logger.Debugf(nil, err.Error())
+		return errors.New("CAN'T CREATE ROOM")
+	}
+	// Another player may have created it meanwhile
+	if resp.ErrorCode != protocol.ErrorCodeNone && resp.ErrorCode != protocol.ErrorCodeRoomNameTaken {
+		return errors.New(protocol.GetErrorMessage(resp.ErrorCode))
+	}
+	return nil
+}
@@ -2,11 +2,9 @@ package client
 
 import (
 	"context"
-	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/go-logger"
 	"golang.org/x/time/rate"
 )
@@ -15,16 +13,36 @@ func (p *clientProcessor) produceInputSnapshot() {
 	ctx := context.Background()
 	limiter := rate.NewLimiter(rate.Limit(config.ClientSyncRate), 1)
 	worldID := p.worldID
+	prevInputSSList := []*protocol.InputSnapshot{}
+	sendErrorCount := 0
 	for worldID == p.worldID {
 		_ = limiter.Wait(ctx)
-		now := time.Now()
+		seq, inputSS := p.world.GetInputSnapshot()
 		_, err := p.client.Send(protocol.CmdSetPlayerInput, &protocol.SetPlayerInputRequest{
-			PlayerID:      p.world.GetMainPlayerID(),
-			InputSnapshot: p.world.GetInputSnapshot(),
+			SessionToken:       p.sessionToken,
+			InputSnapshot:      inputSS,
+			AckTick:            p.client.GetAckTick(),
+			AckEventSeq:        p.client.GetAckEventSeq(),
+			Seq:                seq,
+			PrevInputSnapshots: prevInputSSList,
 		})
-		ticktime.SetPing(time.Since(now))
+		// Keep latest inputs to resend
+		if inputSS != nil {
+			prevInputSSList = append(prevInputSSList, inputSS)
+			if len(prevInputSSList) > config.InputRedundancy {
+				prevInputSSList = prevInputSSList[1:]
+			}
+		}
 		if err != nil {
 			logger.Errorf(ctx, err.Error())
+			sendErrorCount++
+		} else {
+			sendErrorCount = 0
+		}
+		// The connection is gone, consumer and producer are restarted by reconnect
+		if sendErrorCount >= clientMaxSendError {
+			p.reconnect()
+			return
 		}
 	}
 }
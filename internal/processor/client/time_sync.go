@@ -0,0 +1,43 @@
+package client
+
+import (
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+// syncClock refines the server time offset while the world lasts, a burst of
+// samples right after joining replaces the rough estimate from registering.
+// Reconnect starts a new one with the new client.
+func (p *clientProcessor) syncClock() {
+	worldID := p.worldID
+	client := p.client
+	for i := 0; worldID == p.worldID && client == p.client; i++ {
+		if i < config.TimeSyncSamples {
+			time.Sleep(config.TimeSyncBurstPeriod)
+		} else {
+			time.Sleep(config.TimeSyncPeriod)
+		}
+		sendTime := time.Now()
+		r, err := client.Send(protocol.CmdTimeSync, &protocol.TimeSyncRequest{
+			ClientSendTime: sendTime.UnixNano(),
+		})
+		receiveTime := time.Now()
+		if err != nil {
+			logger.Debugf(nil, err.Error())
+			continue
+		}
+		resp := r.(*protocol.TimeSyncResponse)
+		if resp.ClientSendTime != sendTime.UnixNano() {
+			continue
+		}
+		p.clock.AddTimeSample(
+			sendTime,
+			time.Unix(0, resp.ServerReceiveTime),
+			time.Unix(0, resp.ServerSendTime),
+			receiveTime,
+		)
+	}
+}
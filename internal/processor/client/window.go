@@ -0,0 +1,42 @@
+package client
+
+import (
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+)
+
+// window is the game window which worlds render to, it reads the key
+// bindings so worlds don't depend on pixelgl.
+type window struct {
+	*pixelgl.Window
+}
+
+func (w *window) GetRawInput() *common.RawInput {
+	return &common.RawInput{
+		MousePos:                w.MousePosition(),
+		PressedFireKey:          w.Pressed(fireKey),
+		PressedMeleeKey:         w.Pressed(meleeKey),
+		PressedUpKey:            w.Pressed(upKey),
+		PressedLeftKey:          w.Pressed(leftKey),
+		PressedDownKey:          w.Pressed(downKey),
+		PressedRightKey:         w.Pressed(rightKey),
+		PressedReloadKey:        w.Pressed(reloadKey),
+		PressedDropKey:          w.Pressed(dropKey),
+		PressedUse1stItemKey:    w.Pressed(use1stItemKey),
+		PressedUse2ndItemKey:    w.Pressed(use2ndItemKey),
+		PressedUse3rdItemKey:    w.Pressed(use3rdItemKey),
+		PressedToggleMuteKey:    w.Pressed(toggleMuteKey),
+		PressedVolumeUpKey:      w.Pressed(volumeUpKey),
+		PressedVolumeDownKey:    w.Pressed(volumeDownKey),
+		PressedToggleFullScreen: w.Pressed(toggleFullScreenKey),
+		PressedToggleFPSLimit:   w.Pressed(toggleFPSLimitKey),
+	}
+}
+
+func (w *window) ToggleFullScreen() {
+	if w.Monitor() != nil {
+		w.SetMonitor(nil)
+	} else {
+		w.SetMonitor(pixelgl.PrimaryMonitor())
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+// processCreateRoom opens a room for players, it is closed again once
+// nobody plays in it.
+func (p *serverProcessor) processCreateRoom(clientID string, request interface{}) (resp *protocol.CreateRoomResponse) {
+	req := request.(*protocol.CreateRoomRequest)
+	if req == nil {
+		return &protocol.CreateRoomResponse{
+			ErrorCode: protocol.ErrorCodeInvalidRoomName,
+		}
+	}
+	r, errorCode := p.createRoom(strings.TrimSpace(req.Name), false)
+	if errorCode != protocol.ErrorCodeNone {
+		return &protocol.CreateRoomResponse{
+			ErrorCode: errorCode,
+		}
+	}
+	logger.Infof(context.Background(), "create room|client_id:%s|room_id:%s", clientID, r.id)
+	return &protocol.CreateRoomResponse{
+		Room: r.getInfo(),
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+// processDisconnect removes the player of a leaving client right away
+// instead of waiting for it to time out.
+func (p *serverProcessor) processDisconnect(clientID string, request interface{}) (resp *protocol.DisconnectResponse) {
+	req := request.(*protocol.DisconnectRequest)
+	roomID, playerID, exists := p.getClientPlayer(clientID)
+	if !exists {
+		return &protocol.DisconnectResponse{}
+	}
+	reason := protocol.DisconnectReasonNone
+	if req != nil {
+		reason = req.Reason
+	}
+	logger.Infof(context.Background(), "player left|client_id:%s|room_id:%s|player_id:%s|reason:%d",
+		clientID, roomID, playerID, reason)
+	p.removeClientPlayer(clientID)
+	if r, exists := p.rooms.get(roomID); exists {
+		p.removePlayer(r, playerID)
+	}
+	return &protocol.DisconnectResponse{}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
+)
+
+func (p *serverProcessor) processHandshake(clientID string, request interface{}) (resp *protocol.HandshakeResponse) {
+	req := request.(*protocol.HandshakeRequest)
+	resp = &protocol.HandshakeResponse{
+		MinVersion: protocol.MinProtocolVersion,
+		MaxVersion: protocol.ProtocolVersion,
+	}
+	version, ok := protocol.SelectVersion(req.MinVersion, req.MaxVersion)
+	if !ok {
+		logger.Infof(context.Background(), "unsupported client|client_id:%s|min_version:%d|max_version:%d|game_version:%s",
+			clientID, req.MinVersion, req.MaxVersion, req.GameVersion)
+		resp.ErrorCode = protocol.ErrorCodeUnsupportedVersion
+		p.disconnectClient(clientID, protocol.DisconnectReasonVersionMismatch)
+		return resp
+	}
+	resp.Version = version
+	resp.Codec = protocol.CodecJSON
+	if version >= protocol.ProtocolVersionSessions {
+		resp.Codec = protocol.SelectCodec(req.Codecs)
+		resp.Capabilities = req.Capabilities & protocol.GetSupportedCapabilities()
+	}
+	p.server.SetClientProtocol(clientID, resp.Version, resp.Codec, resp.Capabilities)
+	return resp
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+func (p *serverProcessor) processListRooms() (resp *protocol.ListRoomsResponse) {
+	rooms := p.rooms.getAll()
+	resp = &protocol.ListRoomsResponse{
+		Rooms: make([]*protocol.RoomInfo, 0, len(rooms)),
+	}
+	for _, r := range rooms {
+		resp.Rooms = append(resp.Rooms, r.getInfo())
+	}
+	return resp
+}
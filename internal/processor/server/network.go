@@ -2,7 +2,8 @@ package server
 
 import (
 	"context"
-	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/network"
@@ -10,28 +11,83 @@ import (
 	"github.com/mr-panta/go-logger"
 )
 
-type GameProcess func(cmd int, req interface{}) (resp interface{})
+type GameProcess func(clientID string, cmd int, req interface{}) (resp interface{})
 
 type ServerNetwork interface {
 	Start() error
 	Wait()
 	Close() error
 	Broadcast(cmd int, data interface{}) error
+	SendTo(clientID string, cmd int, data interface{}) error
+	GetClientIDs() []string
+	SetClientProtocol(clientID string, version, codecType int, capabilities uint64)
+	GetClientVersion(clientID string) int
+	GetClientCapabilities(clientID string) (capabilities uint64, exists bool)
+	GetClientAckEventSeq(clientID string) int64
+	GetClientStats() map[string]network.ClientStats
+	GetErrorCounts() network.ErrorCounts
 }
 
 type serverNetwork struct {
-	server network.Server
+	server             network.Server
+	clientVersions     map[string]int
+	clientCodecs       map[string]int
+	clientCapabilities map[string]uint64
+	clientAckTicks     map[string]int64
+	clientAckEventSeqs map[string]int64
+	clientHistories    map[string]*protocol.WorldSnapshotHistory
+	clientLock         sync.RWMutex
+	worldDiffers       map[string]*worldDiffer
+	worldDifferLock    sync.Mutex
 }
 
-func NewServerNetwork(gameProcess GameProcess) ServerNetwork {
-	server := network.NewServer(
-		config.TCPPortA,
-		config.TCPPortB,
-		translateProcess(gameProcess),
-	)
-	return &serverNetwork{
-		server: server,
+// worldDiffer shares object deltas between the clients of a world
+type worldDiffer struct {
+	differ  *protocol.WorldSnapshotDiffer
+	useTime time.Time
+}
+
+func NewServerNetwork(cfg *config.ServerConfig, gameProcess GameProcess) ServerNetwork {
+	s := &serverNetwork{
+		clientVersions:     make(map[string]int),
+		clientCodecs:       make(map[string]int),
+		clientCapabilities: make(map[string]uint64),
+		clientAckTicks:     make(map[string]int64),
+		clientAckEventSeqs: make(map[string]int64),
+		clientHistories:    make(map[string]*protocol.WorldSnapshotHistory),
+		worldDiffers:       make(map[string]*worldDiffer),
+	}
+	limits := network.Limits{
+		MaxFrameSize:        cfg.MaxFrameSize,
+		MaxDecompressedSize: cfg.MaxDecompressedSize,
+		IdleTimeOut:         cfg.IdleTimeOut.Duration,
+		IOTimeOut:           cfg.IOTimeOut.Duration,
+		SlowClientTimeOut:   cfg.SlowClientTimeOut.Duration,
+		HeartbeatTimeOut:    cfg.HeartbeatTimeOut.Duration,
 	}
+	switch cfg.Transport {
+	case config.TransportUDP:
+		s.server = network.NewUDPServer(
+			cfg.UDPAddr,
+			cfg.UDPLossRate,
+			limits,
+			s.translateProcess(gameProcess),
+		)
+	case config.TransportTCP:
+		s.server = network.NewServer(
+			cfg.TCPAddrA,
+			cfg.TCPAddrB,
+			limits,
+			s.translateProcess(gameProcess),
+		)
+	default:
+		s.server = network.NewMuxServer(
+			cfg.MuxAddr,
+			limits,
+			s.translateProcess(gameProcess),
+		)
+	}
+	return s
 }
 
 func (s *serverNetwork) Start() error {
@@ -46,29 +102,184 @@ func (s *serverNetwork) Close() error {
 	return s.server.Close()
 }
 
-func translateProcess(gameProcess GameProcess) (process network.Process) {
-	return func(reqBytes []byte) (respBytes []byte) {
+// SetClientProtocol keeps what was agreed with a client, in the handshake or
+// when a legacy client registered.
+func (s *serverNetwork) SetClientProtocol(clientID string, version, codecType int, capabilities uint64) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	s.clientVersions[clientID] = version
+	s.clientCodecs[clientID] = codecType
+	s.clientCapabilities[clientID] = capabilities
+	// New client needs a full snapshot
+	delete(s.clientAckTicks, clientID)
+	delete(s.clientAckEventSeqs, clientID)
+	delete(s.clientHistories, clientID)
+}
+
+// GetClientVersion returns the agreed protocol version, clients which didn't
+// handshake speak the first version.
+func (s *serverNetwork) GetClientVersion(clientID string) int {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	if version, exists := s.clientVersions[clientID]; exists {
+		return version
+	}
+	return protocol.MinProtocolVersion
+}
+
+// GetClientCapabilities returns what was agreed with the client, exists is
+// false for clients which neither handshook nor registered.
+func (s *serverNetwork) GetClientCapabilities(clientID string) (capabilities uint64, exists bool) {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	capabilities, exists = s.clientCapabilities[clientID]
+	return capabilities, exists
+}
+
+// GetClientStats returns how far behind each client is on snapshots
+func (s *serverNetwork) GetClientStats() map[string]network.ClientStats {
+	return s.server.GetClientStats()
+}
+
+// GetErrorCounts returns why connections were torn down
+func (s *serverNetwork) GetErrorCounts() network.ErrorCounts {
+	return s.server.GetErrorCounts()
+}
+
+func (s *serverNetwork) getClientCodec(clientID string) protocol.Codec {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return protocol.GetCodec(s.clientCodecs[clientID])
+}
+
+func (s *serverNetwork) setClientAckTick(clientID string, tick int64) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	s.clientAckTicks[clientID] = tick
+}
+
+func (s *serverNetwork) getClientAckTick(clientID string) int64 {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return s.clientAckTicks[clientID]
+}
+
+func (s *serverNetwork) setClientAckEventSeq(clientID string, seq int64) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	s.clientAckEventSeqs[clientID] = seq
+}
+
+// GetClientAckEventSeq returns the seq of the last game event which the
+// client received.
+func (s *serverNetwork) GetClientAckEventSeq(clientID string) int64 {
+	s.clientLock.RLock()
+	defer s.clientLock.RUnlock()
+	return s.clientAckEventSeqs[clientID]
+}
+
+func (s *serverNetwork) getClientHistory(clientID string) *protocol.WorldSnapshotHistory {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	history, exists := s.clientHistories[clientID]
+	if !exists {
+		history = protocol.NewWorldSnapshotHistory(config.DeltaBaselineHistory)
+		s.clientHistories[clientID] = history
+	}
+	return history
+}
+
+func (s *serverNetwork) GetClientIDs() []string {
+	clientIDs := s.server.GetClientIDs()
+	s.cleanClients(clientIDs)
+	return clientIDs
+}
+
+// cleanClients forgets clients which are not connected anymore
+func (s *serverNetwork) cleanClients(clientIDs []string) {
+	s.clientLock.Lock()
+	defer s.clientLock.Unlock()
+	existsMap := make(map[string]bool)
+	for _, clientID := range clientIDs {
+		existsMap[clientID] = true
+	}
+	for clientID := range s.clientVersions {
+		if !existsMap[clientID] {
+			delete(s.clientVersions, clientID)
+		}
+	}
+	for clientID := range s.clientCodecs {
+		if !existsMap[clientID] {
+			delete(s.clientCodecs, clientID)
+		}
+	}
+	for clientID := range s.clientCapabilities {
+		if !existsMap[clientID] {
+			delete(s.clientCapabilities, clientID)
+		}
+	}
+	for clientID := range s.clientAckTicks {
+		if !existsMap[clientID] {
+			delete(s.clientAckTicks, clientID)
+		}
+	}
+	for clientID := range s.clientAckEventSeqs {
+		if !existsMap[clientID] {
+			delete(s.clientAckEventSeqs, clientID)
+		}
+	}
+	for clientID := range s.clientHistories {
+		if !existsMap[clientID] {
+			delete(s.clientHistories, clientID)
+		}
+	}
+}
+
+func (s *serverNetwork) translateProcess(gameProcess GameProcess) (process network.Process) {
+	return func(clientID string, reqBytes []byte) (respBytes []byte) {
 		// Prepare
 		ctx := context.Background()
 		wrappedData := &protocol.WrappedData{}
-		// Read req
-		if err := json.Unmarshal(reqBytes, wrappedData); err != nil {
+		// Read req, resp is written with the same codec
+		codec := protocol.DetectCodec(reqBytes)
+		if err := codec.Unmarshal(reqBytes, wrappedData); err != nil {
 			logger.Errorf(ctx, err.Error())
 			return []byte{}
 		}
+		// Commands which are newer than the client are unknown to it
+		if !protocol.IsCmdSupported(wrappedData.Cmd, s.GetClientVersion(clientID)) {
+			logger.Debugf(ctx, "unsupported cmd|client_id:%s|cmd:%d", clientID, wrappedData.Cmd)
+			return []byte{}
+		}
 		// Command routing
 		var resp interface{}
 		switch wrappedData.Cmd {
+		case protocol.CmdHandshake:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.Handshake)
 		case protocol.CmdRegisterPlayer:
-			resp = gameProcess(wrappedData.Cmd, wrappedData.RegisterPlayer)
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.RegisterPlayer)
+		case protocol.CmdResumeSession:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.ResumeSession)
+		case protocol.CmdDisconnect:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.Disconnect)
+		case protocol.CmdTimeSync:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.TimeSync)
+		case protocol.CmdListRooms:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.ListRooms)
+		case protocol.CmdCreateRoom:
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.CreateRoom)
 		case protocol.CmdSetPlayerInput:
-			resp = gameProcess(wrappedData.Cmd, wrappedData.SetPlayerInput)
+			if wrappedData.SetPlayerInput != nil {
+				s.setClientAckTick(clientID, wrappedData.SetPlayerInput.AckTick)
+				s.setClientAckEventSeq(clientID, wrappedData.SetPlayerInput.AckEventSeq)
+			}
+			resp = gameProcess(clientID, wrappedData.Cmd, wrappedData.SetPlayerInput)
 		default:
 			return []byte{}
 		}
 		// Write resp
 		var err error
-		respBytes, err = json.Marshal(resp)
+		respBytes, err = codec.Marshal(resp)
 		if err != nil {
 			logger.Errorf(ctx, err.Error())
 			return []byte{}
@@ -78,6 +289,64 @@ func translateProcess(gameProcess GameProcess) (process network.Process) {
 }
 
 func (s *serverNetwork) Broadcast(cmd int, data interface{}) error {
+	return s.multicast(s.GetClientIDs(), cmd, data)
+}
+
+func (s *serverNetwork) SendTo(clientID string, cmd int, data interface{}) error {
+	if cmd == protocol.CmdAddWorldSnapshot {
+		req := data.(*protocol.AddWorldSnapshotRequest)
+		if s.GetClientVersion(clientID) < protocol.ProtocolVersionSessions {
+			// Legacy clients don't ack events
+			legacyReq := *req
+			legacyReq.Events = nil
+			legacyReq.EventSeq = 0
+			req = &legacyReq
+		}
+		data = s.getWorldSnapshotDelta(clientID, req)
+	}
+	return s.multicast([]string{clientID}, cmd, data)
+}
+
+// getWorldSnapshotDelta turns the snapshot into a delta against the last one
+// the client acknowledged, it stays full when there is no usable baseline.
+func (s *serverNetwork) getWorldSnapshotDelta(clientID string, req *protocol.AddWorldSnapshotRequest) *protocol.AddWorldSnapshotRequest {
+	if capabilities, _ := s.GetClientCapabilities(clientID); capabilities&protocol.CapabilityDeltaSnapshot == 0 {
+		return req
+	}
+	history := s.getClientHistory(clientID)
+	history.Add(req.Tick, req.WorldSnapshot)
+	baseTick := s.getClientAckTick(clientID)
+	base, exists := history.Get(baseTick)
+	if !exists || baseTick <= 0 || base.ID != req.WorldSnapshot.ID {
+		return req
+	}
+	delta := *req
+	differ := s.getWorldDiffer(req.WorldSnapshot.ID)
+	delta.WorldSnapshot = differ.Diff(req.Tick, baseTick, base, req.WorldSnapshot)
+	return &delta
+}
+
+// getWorldDiffer returns the differ of a world, differs of worlds which are
+// gone are dropped when another world starts.
+func (s *serverNetwork) getWorldDiffer(worldID string) *protocol.WorldSnapshotDiffer {
+	s.worldDifferLock.Lock()
+	defer s.worldDifferLock.Unlock()
+	now := time.Now()
+	d, exists := s.worldDiffers[worldID]
+	if !exists {
+		for id, d := range s.worldDiffers {
+			if now.Sub(d.useTime) > config.WorldDifferTimeOut {
+				delete(s.worldDiffers, id)
+			}
+		}
+		d = &worldDiffer{differ: protocol.NewWorldSnapshotDiffer()}
+		s.worldDiffers[worldID] = d
+	}
+	d.useTime = now
+	return d.differ
+}
+
+func (s *serverNetwork) multicast(clientIDs []string, cmd int, data interface{}) error {
 	wrappedData := &protocol.WrappedData{
 		Cmd: cmd,
 	}
@@ -85,11 +354,29 @@ func (s *serverNetwork) Broadcast(cmd int, data interface{}) error {
 	switch cmd {
 	case protocol.CmdAddWorldSnapshot:
 		wrappedData.AddWorldSnapshot = data.(*protocol.AddWorldSnapshotRequest)
+	case protocol.CmdDisconnect:
+		wrappedData.Disconnect = data.(*protocol.DisconnectRequest)
+	}
+	// Encode once per codec
+	codecClientIDs := make(map[int][]string)
+	for _, clientID := range clientIDs {
+		if !protocol.IsCmdSupported(cmd, s.GetClientVersion(clientID)) {
+			continue
+		}
+		codecType := s.getClientCodec(clientID).GetType()
+		codecClientIDs[codecType] = append(codecClientIDs[codecType], clientID)
 	}
-	respBytes, err := json.Marshal(wrappedData)
-	if err != nil {
-		return err
+	for codecType, clientIDs := range codecClientIDs {
+		dataBytes, err := protocol.GetCodec(codecType).Marshal(wrappedData)
+		if err != nil {
+			return err
+		}
+		// A newer snapshot makes an unsent one useless
+		if cmd == protocol.CmdAddWorldSnapshot {
+			s.server.MulticastLatest(clientIDs, dataBytes)
+		} else {
+			s.server.Multicast(clientIDs, dataBytes)
+		}
 	}
-	s.server.Broadcast(respBytes)
 	return nil
 }
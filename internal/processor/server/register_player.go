@@ -1,37 +1,90 @@
 package server
 
 import (
+	"context"
 	"strings"
 
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
-	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
+	"github.com/mr-panta/go-logger"
 )
 
-func (p *serverProcessor) processRegisterPlayer(request interface{}) (resp *protocol.RegisterPlayerResponse) {
+func (p *serverProcessor) processRegisterPlayer(clientID string, request interface{}) (resp *protocol.RegisterPlayerResponse) {
 	req := request.(*protocol.RegisterPlayerRequest)
-	if config.Version != req.Version {
-		return &protocol.RegisterPlayerResponse{
-			OK:           false,
-			DebugMessage: "CLIENT/SERVER VERSION MISMATCH",
+	if version := p.server.GetClientVersion(clientID); version < protocol.ProtocolVersionSessions {
+		// Legacy clients register without a handshake, they get json
+		// whichever codecs they offer
+		logger.Infof(context.Background(), "legacy client|client_id:%s|version:%d|game_version:%s",
+			clientID, version, req.Version)
+		p.server.SetClientProtocol(clientID, version, protocol.CodecJSON, 0)
+		resp = p.registerPlayer(clientID, req, 0)
+		resp.OK = resp.ErrorCode == protocol.ErrorCodeNone
+		if !resp.OK {
+			resp.DebugMessage = protocol.GetErrorMessage(resp.ErrorCode)
 		}
+		resp.Codec = protocol.CodecJSON
+		return resp
 	}
+	capabilities, _ := p.server.GetClientCapabilities(clientID)
+	return p.registerPlayer(clientID, req, capabilities)
+}
+
+func (p *serverProcessor) registerPlayer(clientID string, req *protocol.RegisterPlayerRequest, capabilities uint64) (
+	resp *protocol.RegisterPlayerResponse) {
 	playerName := strings.Trim(req.PlayerName, " ")
 	if len(playerName) == 0 || len(playerName) > 16 {
 		return &protocol.RegisterPlayerResponse{
-			OK:           false,
-			DebugMessage: "PLAYER NAME MUST BE NON-EMPTY AND SHORTER THAN 16 CHARACTERS",
+			ErrorCode: protocol.ErrorCodeInvalidPlayerName,
 		}
 	}
-	playerID := p.world.GetObjectDB().GetAvailableID()
-	p.world.SpawnPlayer(playerID, playerName)
-	tick, worldSnapshot := p.world.GetSnapshot(true)
-	return &protocol.RegisterPlayerResponse{
-		OK:            true,
-		PlayerID:      playerID,
-		ServerTime:    ticktime.GetServerTime().UnixNano(),
-		StartTime:     ticktime.GetServerStartTime().UnixNano(),
-		Tick:          tick,
-		WorldSnapshot: worldSnapshot,
+	r, exists := p.rooms.find(req.RoomID, req.RoomName)
+	if !exists {
+		return &protocol.RegisterPlayerResponse{
+			ErrorCode: protocol.ErrorCodeRoomNotFound,
+		}
+	}
+	// A client plays in one room at a time
+	if roomID, playerID, exists := p.getClientPlayer(clientID); exists {
+		if prevRoom, exists := p.rooms.get(roomID); exists {
+			p.removePlayer(prevRoom, playerID)
+		}
+		p.removeClientPlayer(clientID)
+	}
+	ok := r.call(func(world common.WorldSimulation) {
+		playerID := world.GetObjectDB().GetAvailableID()
+		token, err := p.sessions.create(clientID, r.id, playerID)
+		if err != nil {
+			logger.Errorf(context.Background(), err.Error())
+			resp = &protocol.RegisterPlayerResponse{
+				ErrorCode: protocol.ErrorCodeInvalidSession,
+			}
+			return
+		}
+		world.SpawnPlayer(playerID, playerName)
+		if o, exists := world.GetObjectDB().SelectOne(playerID); exists &&
+			capabilities&protocol.CapabilityExtraItemSlots != 0 {
+			o.(common.Player).SetExtraItemSlots()
+		}
+		p.setClientPlayer(clientID, r.id, playerID)
+		r.markActiveTime(playerID, world.GetClock().GetServerTime())
+		tick, worldSnapshot := world.GetSnapshot(true)
+		worldSnapshot = world.FilterSnapshot(playerID, worldSnapshot)
+		resp = &protocol.RegisterPlayerResponse{
+			PlayerID:      playerID,
+			SessionToken:  token,
+			ServerTime:    world.GetClock().GetServerTime().UnixNano(),
+			StartTime:     world.GetClock().GetServerStartTime().UnixNano(),
+			Tick:          tick,
+			WorldSnapshot: worldSnapshot,
+			PlayerTimeOut: int64(p.cfg.PlayerTimeOut.Duration),
+			Room:          r.getInfo(),
+		}
+	})
+	if !ok {
+		// The room was closed or its world was reset meanwhile
+		return &protocol.RegisterPlayerResponse{
+			ErrorCode: protocol.ErrorCodeRoomNotFound,
+		}
 	}
+	return resp
 }
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// legacyRegisterPlayerResponse is what clients of the first version read
+type legacyRegisterPlayerResponse struct {
+	OK            bool                    `json:"ok"`
+	DebugMessage  string                  `json:"debug_message"`
+	PlayerID      string                  `json:"player_id"`
+	Tick          int64                   `json:"tick"`
+	WorldSnapshot *protocol.WorldSnapshot `json:"world_snapshot"`
+	Codec         int                     `json:"codec"`
+}
+
+func TestLegacyClientRegistersWithoutHandshake(t *testing.T) {
+	const legacyID, clientID = "legacy", "client"
+	server := newTestServer(legacyID, clientID)
+	p, process := newTestServerProcessor(config.NewServerConfig(), server)
+	r, errorCode := p.createRoom("main", true)
+	if errorCode != protocol.ErrorCodeNone {
+		t.Fatal(protocol.GetErrorMessage(errorCode))
+	}
+	defer p.closeRoom(r)
+
+	// A client of the first version offers binary, which it can't be served
+	respBytes := process(legacyID, []byte(`{"cmd":1,"register_player":{"player_name":"old","version":"0.4.0","codecs":[2,1]}}`))
+	legacyResp := &legacyRegisterPlayerResponse{}
+	if err := json.Unmarshal(respBytes, legacyResp); err != nil {
+		t.Fatal(err)
+	}
+	if !legacyResp.OK || legacyResp.PlayerID == "" || legacyResp.WorldSnapshot == nil {
+		t.Fatalf("register failed %+v", legacyResp)
+	}
+	if legacyResp.Codec != protocol.CodecJSON {
+		t.Fatalf("legacy client got codec %d", legacyResp.Codec)
+	}
+	// It drives its player without a session
+	respBytes = process(legacyID, []byte(fmt.Sprintf(
+		`{"cmd":2,"set_player_input":{"player_id":%q,"input_snapshot":{"cursor_dir":{"x":1,"y":0},"right":true},"seq":1}}`, legacyResp.PlayerID)))
+	inputResp := &protocol.SetPlayerInputResponse{}
+	if err := json.Unmarshal(respBytes, inputResp); err != nil || inputResp.ErrorCode != protocol.ErrorCodeNone {
+		t.Fatalf("legacy input was rejected %s", respBytes)
+	}
+	// and doesn't know newer commands
+	if respBytes := process(legacyID, []byte(`{"cmd":6,"time_sync":{}}`)); len(respBytes) != 0 {
+		t.Fatalf("legacy client got %s", respBytes)
+	}
+
+	// A client of the newest version can't skip its session
+	if resp := handshake(process, clientID, protocol.CodecBinary); resp == nil || resp.Version != protocol.ProtocolVersion {
+		t.Fatalf("handshake failed %+v", resp)
+	}
+	codec := protocol.GetCodec(protocol.CodecBinary)
+	reqBytes, _ := codec.Marshal(&protocol.WrappedData{
+		Cmd:            protocol.CmdRegisterPlayer,
+		RegisterPlayer: &protocol.RegisterPlayerRequest{PlayerName: "new"},
+	})
+	resp := &protocol.RegisterPlayerResponse{}
+	if err := codec.Unmarshal(process(clientID, reqBytes), resp); err != nil || resp.ErrorCode != protocol.ErrorCodeNone {
+		t.Fatalf("register failed %+v %v", resp, err)
+	}
+	if resp.OK || resp.Codec != 0 {
+		t.Fatal("legacy fields were set for a new client")
+	}
+	reqBytes, _ = codec.Marshal(&protocol.WrappedData{
+		Cmd:            protocol.CmdSetPlayerInput,
+		SetPlayerInput: &protocol.SetPlayerInputRequest{InputSnapshot: &protocol.InputSnapshot{Right: true}, Seq: 1},
+	})
+	inputResp = &protocol.SetPlayerInputResponse{}
+	if err := codec.Unmarshal(process(clientID, reqBytes), inputResp); err != nil ||
+		inputResp.ErrorCode != protocol.ErrorCodeInvalidSession {
+		t.Fatalf("input without session got %+v %v", inputResp, err)
+	}
+
+	// Pushes only carry what each client knows
+	time.Sleep(200 * time.Millisecond)
+	for _, id := range []string{legacyID, clientID} {
+		if err := p.server.SendTo(id, protocol.CmdAddWorldSnapshot, &protocol.AddWorldSnapshotRequest{
+			Tick:          1,
+			WorldSnapshot: &protocol.WorldSnapshot{},
+			Events:        []*protocol.GameEvent{{Seq: 1, Type: protocol.GameEventKilled}},
+			EventSeq:      1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		p.disconnectClient(id, protocol.DisconnectReasonKicked)
+	}
+	legacyPushes := server.takePushes(legacyID)
+	if len(legacyPushes) < 2 {
+		t.Fatalf("legacy client got %d pushes", len(legacyPushes))
+	}
+	for _, data := range legacyPushes {
+		push := make(map[string]interface{})
+		if err := json.Unmarshal(data, &push); err != nil {
+			t.Fatalf("legacy client can't read %q: %v", data, err)
+		}
+		snapshot, ok := push["add_world_snapshot"].(map[string]interface{})
+		if push["cmd"] != float64(protocol.CmdAddWorldSnapshot) || !ok {
+			t.Fatalf("legacy client got %s", data)
+		}
+		if _, exists := snapshot["events"]; exists {
+			t.Fatalf("legacy client got events %s", data)
+		}
+	}
+	pushes := server.takePushes(clientID)
+	wrappedData := &protocol.WrappedData{}
+	if err := codec.Unmarshal(pushes[len(pushes)-1], wrappedData); err != nil || wrappedData.Cmd != protocol.CmdDisconnect {
+		t.Fatalf("new client wasn't told it was kicked %+v %v", wrappedData, err)
+	}
+	wrappedData = &protocol.WrappedData{}
+	if err := codec.Unmarshal(pushes[len(pushes)-2], wrappedData); err != nil || len(wrappedData.AddWorldSnapshot.Events) != 1 {
+		t.Fatalf("new client lost its events %+v %v", wrappedData, err)
+	}
+}
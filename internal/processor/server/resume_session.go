@@ -0,0 +1,56 @@
+package server
+
+import (
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// processResumeSession attaches a reconnected client to the player of its
+// session, the player keeps stats and items as long as it hasn't timed out.
+func (p *serverProcessor) processResumeSession(clientID string, request interface{}) (resp *protocol.ResumeSessionResponse) {
+	req := request.(*protocol.ResumeSessionRequest)
+	roomID, playerID, prevClientID, ok := p.sessions.resume(req.SessionToken, clientID)
+	if !ok {
+		return &protocol.ResumeSessionResponse{
+			ErrorCode: protocol.ErrorCodeInvalidSession,
+		}
+	}
+	r, exists := p.rooms.get(roomID)
+	if !exists {
+		p.sessions.removeRoom(roomID)
+		return &protocol.ResumeSessionResponse{
+			ErrorCode: protocol.ErrorCodeInvalidSession,
+		}
+	}
+	ok = r.call(func(world common.WorldSimulation) {
+		if _, exists := world.GetObjectDB().SelectOne(playerID); !exists {
+			p.sessions.removePlayer(roomID, playerID)
+			resp = &protocol.ResumeSessionResponse{
+				ErrorCode: protocol.ErrorCodeInvalidSession,
+			}
+			return
+		}
+		// The old connection may still be alive, it has lost the player
+		if prevClientID != clientID {
+			p.disconnectClient(prevClientID, protocol.DisconnectReasonKicked)
+		}
+		p.setClientPlayer(clientID, roomID, playerID)
+		r.markActiveTime(playerID, world.GetClock().GetServerTime())
+		tick, worldSnapshot := world.GetSnapshot(true)
+		worldSnapshot = world.FilterSnapshot(playerID, worldSnapshot)
+		resp = &protocol.ResumeSessionResponse{
+			PlayerID:      playerID,
+			ServerTime:    world.GetClock().GetServerTime().UnixNano(),
+			StartTime:     world.GetClock().GetServerStartTime().UnixNano(),
+			Tick:          tick,
+			WorldSnapshot: worldSnapshot,
+			PlayerTimeOut: int64(p.cfg.PlayerTimeOut.Duration),
+		}
+	})
+	if !ok {
+		return &protocol.ResumeSessionResponse{
+			ErrorCode: protocol.ErrorCodeInvalidSession,
+		}
+	}
+	return resp
+}
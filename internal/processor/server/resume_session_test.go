@@ -0,0 +1,181 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/entity/item"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// waitRoom calls check in the loop of the room until it returns true
+func waitRoom(t *testing.T, r *room, check func(world common.WorldSimulation) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		done := false
+		if ok := r.call(func(world common.WorldSimulation) {
+			done = check(world)
+		}); !ok {
+			t.Fatal("room was closed")
+		}
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("room didn't get there in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A client which reconnects gets its player back as it was left.
+func TestResumeSessionKeepsPlayer(t *testing.T) {
+	const oldID, newID = "old", "new"
+	cfg := config.NewServerConfig()
+	cfg.PlayerTimeOut = config.Duration{Duration: 7 * time.Second}
+	cfg.World.RespawnTime = config.Duration{Duration: time.Millisecond}
+	server := newTestServer(oldID, newID)
+	p, process := newTestServerProcessor(cfg, server)
+	r, errorCode := p.createRoom("main", true)
+	if errorCode != protocol.ErrorCodeNone {
+		t.Fatal(protocol.GetErrorMessage(errorCode))
+	}
+	defer p.closeRoom(r)
+	codec := protocol.GetCodec(protocol.CodecBinary)
+
+	handshake(process, oldID, protocol.CodecBinary)
+	reqBytes, _ := codec.Marshal(&protocol.WrappedData{
+		Cmd:            protocol.CmdRegisterPlayer,
+		RegisterPlayer: &protocol.RegisterPlayerRequest{PlayerName: "player"},
+	})
+	registerResp := &protocol.RegisterPlayerResponse{}
+	if err := codec.Unmarshal(process(oldID, reqBytes), registerResp); err != nil ||
+		registerResp.ErrorCode != protocol.ErrorCodeNone {
+		t.Fatalf("register failed %+v %v", registerResp, err)
+	}
+	if registerResp.PlayerTimeOut != int64(cfg.PlayerTimeOut.Duration) {
+		t.Fatalf("register got player time out %d", registerResp.PlayerTimeOut)
+	}
+	playerID := registerResp.PlayerID
+	// Commands run in the loop of the room, which mustn't stop the test
+	getPlayer := func(world common.WorldSimulation) common.Player {
+		o, _ := world.GetObjectDB().SelectOne(playerID)
+		player, _ := o.(common.Player)
+		return player
+	}
+	getSkull := func(world common.WorldSimulation) *item.ItemSkull {
+		for _, o := range world.GetObjectDB().SelectAll() {
+			if skull, ok := o.(*item.ItemSkull); ok {
+				return skull
+			}
+		}
+		return nil
+	}
+
+	// The player kills, dies, then picks up a land mine and the skull
+	r.call(func(world common.WorldSimulation) {
+		player := getPlayer(world)
+		player.IncreaseKill()
+		player.Die("", "")
+	})
+	waitRoom(t, r, func(world common.WorldSimulation) bool {
+		player := getPlayer(world)
+		return player != nil && player.IsAlive()
+	})
+	landMineID := ""
+	pickedUpSkull := false
+	r.call(func(world common.WorldSimulation) {
+		player := getPlayer(world)
+		landMineID = world.GetObjectDB().GetAvailableID()
+		landMine := item.NewItemLandMine(world.(common.World), landMineID)
+		landMine.SetPos(player.GetPos())
+		world.GetObjectDB().Set(landMine)
+		if skull := getSkull(world); skull != nil {
+			pickedUpSkull = skull.UsedBy(player)
+		}
+	})
+	if !pickedUpSkull {
+		t.Fatal("skull wasn't picked up")
+	}
+	var skullTime time.Duration
+	waitRoom(t, r, func(world common.WorldSimulation) bool {
+		player := getPlayer(world)
+		if player == nil {
+			return false
+		}
+		skullTime = getSkull(world).GetRemainingTimeMap()[playerID]
+		for _, item := range player.GetItems() {
+			if item != nil && item.GetID() == landMineID {
+				return true
+			}
+		}
+		return false
+	})
+
+	handshake(process, newID, protocol.CodecBinary)
+	reqBytes, _ = codec.Marshal(&protocol.WrappedData{
+		Cmd:           protocol.CmdResumeSession,
+		ResumeSession: &protocol.ResumeSessionRequest{SessionToken: registerResp.SessionToken},
+	})
+	resp := &protocol.ResumeSessionResponse{}
+	if err := codec.Unmarshal(process(newID, reqBytes), resp); err != nil || resp.ErrorCode != protocol.ErrorCodeNone {
+		t.Fatalf("resume failed %+v %v", resp, err)
+	}
+	if resp.PlayerID != playerID {
+		t.Fatalf("resumed player %s, registered %s", resp.PlayerID, playerID)
+	}
+	if resp.PlayerTimeOut != int64(cfg.PlayerTimeOut.Duration) {
+		t.Fatalf("resume got player time out %d", resp.PlayerTimeOut)
+	}
+	var playerSnapshot *protocol.PlayerSnapshot
+	var skullSnapshot *protocol.ItemSkullSnapshot
+	for _, ss := range resp.WorldSnapshot.ObjectSnapshots {
+		switch {
+		case ss.ID == playerID:
+			playerSnapshot = ss.Player
+		case ss.Item != nil && ss.Item.Skull != nil:
+			skullSnapshot = ss.Item.Skull
+		}
+	}
+	if playerSnapshot == nil || skullSnapshot == nil {
+		t.Fatal("resumed snapshot lost the player or the skull")
+	}
+	if playerSnapshot.Kill != 1 || playerSnapshot.Death != 1 {
+		t.Fatalf("stats weren't kept %+v", playerSnapshot)
+	}
+	hasLandMine := false
+	for _, itemID := range playerSnapshot.ItemIDs {
+		hasLandMine = hasLandMine || itemID == landMineID
+	}
+	if !hasLandMine {
+		t.Fatalf("items weren't kept %v", playerSnapshot.ItemIDs)
+	}
+	record, exists := skullSnapshot.RecordMap[playerID]
+	if skullSnapshot.PlayerID != playerID || !exists {
+		t.Fatalf("skull wasn't kept %+v", skullSnapshot)
+	}
+	// The skull keeps running down while it's held
+	remainingTime := time.Duration(record.RemainingMS)*time.Millisecond - time.Duration(resp.ServerTime-record.PickupTime)
+	if remainingTime > skullTime || remainingTime < skullTime-time.Second {
+		t.Fatalf("skull had %v left, resumed with %v", skullTime, remainingTime)
+	}
+
+	// The player moved to the new client, the old one was told
+	if roomID, id, exists := p.getClientPlayer(newID); !exists || roomID != r.id || id != playerID {
+		t.Fatalf("new client has player %s in room %s", id, roomID)
+	}
+	if _, _, exists := p.getClientPlayer(oldID); exists {
+		t.Fatal("old client kept the player")
+	}
+	pushes := server.takePushes(oldID)
+	wrappedData := &protocol.WrappedData{}
+	if len(pushes) == 0 {
+		t.Fatal("old client got no pushes")
+	}
+	if err := codec.Unmarshal(pushes[len(pushes)-1], wrappedData); err != nil ||
+		wrappedData.Cmd != protocol.CmdDisconnect || wrappedData.Disconnect.Reason != protocol.DisconnectReasonKicked {
+		t.Fatalf("old client wasn't told it was kicked %+v %v", wrappedData, err)
+	}
+}
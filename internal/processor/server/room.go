@@ -0,0 +1,325 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/replay"
+)
+
+// commands wait here while the tick loop is busy with a tick
+const roomCommandQueueSize = 1024
+
+// roomCommand changes the world of a room, it only runs on the tick loop.
+type roomCommand func(world common.WorldSimulation)
+
+// queuedCommand is a command and the world it was queued for, it is dropped
+// when the world was reset meanwhile.
+type queuedCommand struct {
+	worldID string
+	cmd     roomCommand
+	// done gets whether the command ran, it is nil when nobody waits
+	done chan bool
+}
+
+// roomFrame is what the broadcast loop sends, the tick loop copies it out of
+// the world at the end of a tick and nobody changes it afterwards.
+type roomFrame struct {
+	worldID   string
+	worldType int
+	startTime time.Time
+	tick      int64
+	// filtered for each player of a client in the room
+	playerSnapshots map[string]*protocol.WorldSnapshot
+	// the whole world, only kept when replays are recorded
+	fullSnapshot *protocol.WorldSnapshot
+	events       []*protocol.GameEvent
+	eventSeq     int64
+}
+
+// getEventsAfter returns the events a client hasn't acked yet, a seq ahead
+// of the log was acked in an earlier world and everything is resent.
+func (f *roomFrame) getEventsAfter(seq int64) []*protocol.GameEvent {
+	if seq > f.eventSeq {
+		seq = 0
+	}
+	i := len(f.events)
+	for i > 0 && f.events[i-1].Seq > seq {
+		i--
+	}
+	return f.events[i:]
+}
+
+// room hosts one world with its own tick loop, players and broadcast group.
+// Rooms from the config are permanent, rooms created by players are closed
+// when nobody plays in them. Only the tick loop touches the world, other
+// goroutines send it commands and read the frames it publishes.
+type room struct {
+	id          string
+	name        string
+	isPermanent bool
+	world       common.WorldSimulation
+	worldLock   sync.RWMutex
+	commands    chan *queuedCommand
+	frames      chan *roomFrame
+	// players who sent inputs lately, and since when nobody did
+	lastActiveTimeMap  map[string]time.Time
+	emptyTime          time.Time
+	lastActiveTimeLock sync.RWMutex
+	closeSig           chan bool
+	// closed when the tick loop has returned
+	stopSig chan bool
+	// only used by the broadcast loop
+	recorder       *replay.Recorder
+	recordWorldID  string
+	recordEventSeq int64
+}
+
+func newRoom(id, name string, isPermanent bool, world common.WorldSimulation) *room {
+	return &room{
+		id:                id,
+		name:              name,
+		isPermanent:       isPermanent,
+		world:             world,
+		commands:          make(chan *queuedCommand, roomCommandQueueSize),
+		frames:            make(chan *roomFrame, 1),
+		lastActiveTimeMap: make(map[string]time.Time),
+		emptyTime:         time.Now(),
+		closeSig:          make(chan bool),
+		stopSig:           make(chan bool),
+	}
+}
+
+// post queues a command for the tick loop without waiting for it, it is
+// dropped when the room is closed or its world is reset.
+func (r *room) post(cmd roomCommand) {
+	r.enqueue(cmd, nil)
+}
+
+// call runs a command on the tick loop and waits until it's done, ok is false
+// when the room was closed or its world was reset before the command ran.
+func (r *room) call(cmd roomCommand) (ok bool) {
+	done := make(chan bool, 1)
+	r.enqueue(cmd, done)
+	select {
+	case ok = <-done:
+		return ok
+	case <-r.stopSig:
+		// The command may have run just before the loop returned
+		select {
+		case ok = <-done:
+			return ok
+		default:
+			return false
+		}
+	}
+}
+
+// enqueue tags a command with the world it was queued for
+func (r *room) enqueue(cmd roomCommand, done chan bool) {
+	queued := &queuedCommand{
+		worldID: r.getWorld().GetID(),
+		cmd:     cmd,
+		done:    done,
+	}
+	select {
+	case r.commands <- queued:
+	case <-r.stopSig:
+	}
+}
+
+// runCommands runs queued commands until the tick at tickTime is due, open is
+// false when the room is closed meanwhile.
+func (r *room) runCommands(world common.WorldSimulation, tickTime time.Time) (open bool) {
+	timer := time.NewTimer(time.Until(tickTime))
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.closeSig:
+			return false
+		case queued := <-r.commands:
+			// Players and objects of an earlier world are gone
+			ok := queued.worldID == world.GetID()
+			if ok {
+				queued.cmd(world)
+			}
+			if queued.done != nil {
+				queued.done <- ok
+			}
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// publishFrame hands a frame to the broadcast loop, a frame it hasn't picked
+// up yet is replaced.
+func (r *room) publishFrame(frame *roomFrame) {
+	select {
+	case <-r.frames:
+	default:
+	}
+	// The tick loop is the only sender, so there is room now
+	r.frames <- frame
+}
+
+func (r *room) getWorld() common.WorldSimulation {
+	r.worldLock.RLock()
+	defer r.worldLock.RUnlock()
+	return r.world
+}
+
+// setWorld replaces the world after a game is over, players of the old
+// world are forgotten.
+func (r *room) setWorld(world common.WorldSimulation) {
+	r.worldLock.Lock()
+	r.world = world
+	r.worldLock.Unlock()
+	r.lastActiveTimeLock.Lock()
+	defer r.lastActiveTimeLock.Unlock()
+	r.lastActiveTimeMap = make(map[string]time.Time)
+	r.emptyTime = time.Now()
+}
+
+func (r *room) markActiveTime(playerID string, t time.Time) {
+	r.lastActiveTimeLock.Lock()
+	defer r.lastActiveTimeLock.Unlock()
+	r.lastActiveTimeMap[playerID] = t
+}
+
+func (r *room) removeActiveTime(playerID string) {
+	r.lastActiveTimeLock.Lock()
+	defer r.lastActiveTimeLock.Unlock()
+	delete(r.lastActiveTimeMap, playerID)
+	if len(r.lastActiveTimeMap) == 0 {
+		r.emptyTime = time.Now()
+	}
+}
+
+// getTimeOutPlayerIDs returns players who haven't sent inputs since before t
+func (r *room) getTimeOutPlayerIDs(t time.Time) []string {
+	r.lastActiveTimeLock.RLock()
+	defer r.lastActiveTimeLock.RUnlock()
+	playerIDs := []string{}
+	for playerID, lastActiveTime := range r.lastActiveTimeMap {
+		if lastActiveTime.Before(t) {
+			playerIDs = append(playerIDs, playerID)
+		}
+	}
+	return playerIDs
+}
+
+func (r *room) getPlayerCount() int {
+	r.lastActiveTimeLock.RLock()
+	defer r.lastActiveTimeLock.RUnlock()
+	return len(r.lastActiveTimeMap)
+}
+
+// isIdle is true when a room which isn't permanent had no players for d
+func (r *room) isIdle(d time.Duration) bool {
+	r.lastActiveTimeLock.RLock()
+	defer r.lastActiveTimeLock.RUnlock()
+	return !r.isPermanent && len(r.lastActiveTimeMap) == 0 && time.Since(r.emptyTime) > d
+}
+
+func (r *room) getInfo() *protocol.RoomInfo {
+	return &protocol.RoomInfo{
+		ID:          r.id,
+		Name:        r.name,
+		PlayerCount: r.getPlayerCount(),
+	}
+}
+
+// roomStore finds rooms by id or by name, names are unique ignoring case.
+type roomStore struct {
+	roomMap       map[string]*room
+	defaultRoomID string
+	lock          sync.RWMutex
+}
+
+func newRoomStore() *roomStore {
+	return &roomStore{
+		roomMap: make(map[string]*room),
+	}
+}
+
+// add returns an error code when the name is taken or there are max rooms
+// already, the first room added is the default room.
+func (s *roomStore) add(r *room, maxRooms int) (errorCode int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.roomMap) >= maxRooms {
+		return protocol.ErrorCodeTooManyRooms
+	}
+	for _, other := range s.roomMap {
+		if strings.EqualFold(other.name, r.name) {
+			return protocol.ErrorCodeRoomNameTaken
+		}
+	}
+	s.roomMap[r.id] = r
+	if s.defaultRoomID == "" {
+		s.defaultRoomID = r.id
+	}
+	return protocol.ErrorCodeNone
+}
+
+// remove returns false when the room was removed already
+func (s *roomStore) remove(roomID string) (exists bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exists = s.roomMap[roomID]; exists {
+		delete(s.roomMap, roomID)
+	}
+	return exists
+}
+
+func (s *roomStore) get(roomID string) (r *room, exists bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	r, exists = s.roomMap[roomID]
+	return r, exists
+}
+
+func (s *roomStore) getByName(name string) (r *room, exists bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, r := range s.roomMap {
+		if strings.EqualFold(r.name, name) {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// find looks a room up by id, then by name, the default room is returned
+// when both are empty.
+func (s *roomStore) find(roomID, name string) (r *room, exists bool) {
+	if roomID != "" {
+		return s.get(roomID)
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		return s.getByName(name)
+	}
+	s.lock.RLock()
+	defaultRoomID := s.defaultRoomID
+	s.lock.RUnlock()
+	return s.get(defaultRoomID)
+}
+
+// getAll returns rooms sorted by name
+func (s *roomStore) getAll() []*room {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	rooms := make([]*room, 0, len(s.roomMap))
+	for _, r := range s.roomMap {
+		rooms = append(rooms, r)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].name < rooms[j].name
+	})
+	return rooms
+}
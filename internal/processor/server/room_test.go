@@ -0,0 +1,89 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+func TestRoomFrameGetEventsAfter(t *testing.T) {
+	frame := &roomFrame{
+		events:   []*protocol.GameEvent{{Seq: 3}, {Seq: 4}, {Seq: 5}},
+		eventSeq: 5,
+	}
+	cases := []struct {
+		name string
+		seq  int64
+		want []int64
+	}{
+		{"nothing acked", 0, []int64{3, 4, 5}},
+		{"older than log", 1, []int64{3, 4, 5}},
+		{"some acked", 3, []int64{4, 5}},
+		{"all acked", 5, []int64{}},
+		// The client acked events of an earlier world
+		{"ahead of log", 7, []int64{3, 4, 5}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			events := frame.getEventsAfter(c.seq)
+			if len(events) != len(c.want) {
+				t.Fatalf("got %d events, want %v", len(events), c.want)
+			}
+			for i, event := range events {
+				if event.Seq != c.want[i] {
+					t.Fatalf("got seq %d, want %v", event.Seq, c.want)
+				}
+			}
+		})
+	}
+}
+
+// Commands queued before the world of a room is reset refer to players of
+// the old world, they must not run against the new one.
+func TestRoomDropsCommandsOfResetWorld(t *testing.T) {
+	p := newServerProcessor(config.NewServerConfig())
+	oldWorld := p.newWorld()
+	newWorld := p.newWorld()
+	defer oldWorld.Destroy()
+	defer newWorld.Destroy()
+	r := newRoom("room", "main", true, oldWorld)
+	ran := make(chan string, 3)
+	r.post(func(world common.WorldSimulation) { ran <- "stale post" })
+	called := make(chan bool, 1)
+	go func() {
+		called <- r.call(func(world common.WorldSimulation) { ran <- "stale call" })
+	}()
+	for len(r.commands) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	r.setWorld(newWorld)
+	r.post(func(world common.WorldSimulation) {
+		if world != newWorld {
+			t.Error("command ran against another world")
+		}
+		ran <- "fresh post"
+	})
+	if !r.runCommands(newWorld, time.Now().Add(50*time.Millisecond)) {
+		t.Fatal("room was closed")
+	}
+	close(ran)
+	names := []string{}
+	for name := range ran {
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "fresh post" {
+		t.Fatalf("ran %v", names)
+	}
+	select {
+	case ok := <-called:
+		if ok {
+			t.Fatal("stale call reported it ran")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stale call never returned")
+	}
+}
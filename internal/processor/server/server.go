@@ -2,105 +2,436 @@ package server
 
 import (
 	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/replay"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/world"
 	"github.com/mr-panta/go-logger"
 )
 
+const serverShutdownFlushTime = 500 * time.Millisecond
+
+// clientPlayer is the player which a client controls and its room
+type clientPlayer struct {
+	roomID   string
+	playerID string
+}
+
 type serverProcessor struct {
-	server             ServerNetwork
-	world              common.World
-	lastActiveTimeMap  map[string]time.Time
-	lastActiveTimeLock sync.RWMutex
+	cfg              *config.ServerConfig
+	clock            *ticktime.Clock
+	server           ServerNetwork
+	rooms            *roomStore
+	clientPlayerMap  map[string]*clientPlayer
+	clientPlayerLock sync.RWMutex
+	sessions         *sessionStore
+	// ids and seeds of worlds
+	seeds    *rand.Rand
+	seedLock sync.Mutex
 }
 
-func NewServerProcessor() (common.ServerProcessor, error) {
-	p := &serverProcessor{
-		lastActiveTimeMap: make(map[string]time.Time),
-	}
-	p.server = NewServerNetwork(p.process)
+func NewServerProcessor(cfg *config.ServerConfig) (common.ServerProcessor, error) {
+	p := newServerProcessor(cfg)
+	p.server = NewServerNetwork(cfg, p.process)
 	if err := p.server.Start(); err != nil {
 		return nil, err
 	}
-	p.world = world.NewDefaultWorld(nil, util.GenerateID())
 	return p, nil
 }
 
-func (p *serverProcessor) resetWorld() {
-	p.lastActiveTimeLock.Lock()
-	defer p.lastActiveTimeLock.Unlock()
-	p.lastActiveTimeMap = make(map[string]time.Time)
-	p.world = world.NewDefaultWorld(nil, util.GenerateID())
+// newServerProcessor returns a processor without network
+func newServerProcessor(cfg *config.ServerConfig) *serverProcessor {
+	seed := cfg.World.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p := &serverProcessor{
+		cfg:             cfg,
+		clock:           ticktime.NewClock(),
+		rooms:           newRoomStore(),
+		clientPlayerMap: make(map[string]*clientPlayer),
+		sessions:        newSessionStore(),
+		seeds:           util.NewRand(seed),
+	}
+	p.clock.SetServerStartTime(time.Now())
+	return p
+}
+
+// newWorld starts a world at tick 0, each world keeps its own clock and seed
+// so it can be played again from the same inputs. Ids and seeds of worlds are
+// drawn from the seed of the config, a server started with the same seed
+// plays the same worlds.
+func (p *serverProcessor) newWorld() common.WorldSimulation {
+	worldCfg := p.cfg.World
+	p.seedLock.Lock()
+	worldID := util.GenerateIDFrom(p.seeds)
+	worldCfg.Seed = p.seeds.Int63()
+	p.seedLock.Unlock()
+	clock := ticktime.NewClock()
+	clock.SetServerStartTime(time.Now())
+	w := world.NewDefaultWorld(nil, clock, worldID, &worldCfg)
+	logger.Infof(context.Background(), "new world|world_id:%s|seed:%d", w.GetID(), worldCfg.Seed)
+	return w
+}
+
+// Start opens the rooms from the config, each room runs its own loops.
+func (p *serverProcessor) Start() {
+	for _, name := range p.cfg.Rooms {
+		if _, errorCode := p.createRoom(name, true); errorCode != protocol.ErrorCodeNone {
+			logger.Errorf(context.Background(), "can't create room|name:%s|error:%s",
+				name, protocol.GetErrorMessage(errorCode))
+		}
+	}
+	go p.cleanClients()
+	go p.logNetworkStats()
 }
 
 func (p *serverProcessor) Wait() {
 	p.server.Wait()
 }
 
-func (p *serverProcessor) UpdateWorld() {
-	ticktime.SetServerStartTime(time.Now())
+// createRoom opens a room with a new world, an error code is returned when
+// the name is invalid or taken, or there are too many rooms.
+func (p *serverProcessor) createRoom(name string, isPermanent bool) (r *room, errorCode int) {
+	if !config.IsValidRoomName(name) {
+		return nil, protocol.ErrorCodeInvalidRoomName
+	}
+	r = newRoom(util.GenerateID(), name, isPermanent, p.newWorld())
+	if errorCode = p.rooms.add(r, p.cfg.MaxRooms); errorCode != protocol.ErrorCodeNone {
+		r.getWorld().Destroy()
+		return nil, errorCode
+	}
+	logger.Infof(context.Background(), "open room|room_id:%s|name:%s", r.id, r.name)
+	go p.updateRoom(r)
+	go p.broadcastRoom(r)
+	go p.cleanRoom(r)
+	return r, protocol.ErrorCodeNone
+}
+
+// closeRoom stops the loops of a room, it can't be joined anymore.
+func (p *serverProcessor) closeRoom(r *room) {
+	// The room may be closed by shutdown and by its clean loop
+	if !p.rooms.remove(r.id) {
+		return
+	}
+	logger.Infof(context.Background(), "close room|room_id:%s|name:%s", r.id, r.name)
+	close(r.closeSig)
+	p.resetRoomPlayers(r)
+}
+
+// resetRoom starts a new game in the room, clients see a new world id and
+// join again.
+func (p *serverProcessor) resetRoom(r *room) {
+	p.resetRoomPlayers(r)
+	r.setWorld(p.newWorld())
+}
+
+func (p *serverProcessor) resetRoomPlayers(r *room) {
+	p.sessions.removeRoom(r.id)
+	p.clientPlayerLock.Lock()
+	defer p.clientPlayerLock.Unlock()
+	for clientID, cp := range p.clientPlayerMap {
+		if cp.roomID == r.id {
+			delete(p.clientPlayerMap, clientID)
+		}
+	}
+}
+
+// updateRoom is the only goroutine which touches the world of a room. It
+// runs queued commands between ticks and publishes a frame for the broadcast
+// loop, a new world starts again from tick 0.
+func (p *serverProcessor) updateRoom(r *room) {
+	defer close(r.stopSig)
+	world := r.getWorld()
+	frameTime := time.Now()
 	for tick := int64(0); ; tick++ {
-		tickTime := ticktime.GetTickTime(tick)
-		waitTime := time.Until(tickTime)
-		<-time.NewTimer(waitTime).C
-		if exists := p.world.ServerUpdate(tick); !exists {
-			p.resetWorld()
+		if open := r.runCommands(world, world.GetClock().GetTickTime(tick)); !open {
+			world.Destroy()
+			return
+		}
+		if exists := world.ServerUpdate(tick); !exists {
+			p.resetRoom(r)
+			world = r.getWorld()
+			tick = -1
+			continue
+		}
+		if now := time.Now(); !now.Before(frameTime) {
+			r.publishFrame(p.newRoomFrame(r, world))
+			frameTime = now.Add(time.Second / config.ServerSyncRate)
 		}
 	}
 }
 
-func (p *serverProcessor) BroadcastSnapshot() {
-	ticker := time.NewTicker(time.Second / config.ServerSyncRate)
+// newRoomFrame copies what clients and the replay need out of the world
+func (p *serverProcessor) newRoomFrame(r *room, world common.WorldSimulation) *roomFrame {
+	tick, snapshot := world.GetSnapshot(false)
+	frame := &roomFrame{
+		worldID:         world.GetID(),
+		worldType:       world.GetType(),
+		startTime:       world.GetClock().GetServerStartTime(),
+		tick:            tick,
+		playerSnapshots: make(map[string]*protocol.WorldSnapshot),
+	}
+	// Each player only gets what it can see
+	for _, playerID := range p.getRoomClientPlayerIDs(r.id) {
+		frame.playerSnapshots[playerID] = world.FilterSnapshot(playerID, snapshot)
+	}
+	if p.cfg.ReplayDir != "" {
+		_, frame.fullSnapshot = world.GetSnapshot(true)
+	}
+	frame.events, frame.eventSeq = world.GetGameEvents(0)
+	return frame
+}
+
+func (p *serverProcessor) broadcastRoom(r *room) {
 	ctx := context.Background()
-	for range ticker.C {
-		tick, snapshot := p.world.GetSnapshot(false)
-		req := &protocol.AddWorldSnapshotRequest{
-			Tick:          tick,
-			WorldSnapshot: snapshot,
+	for {
+		var frame *roomFrame
+		select {
+		case <-r.closeSig:
+			p.closeRecorder(r)
+			return
+		case frame = <-r.frames:
+		}
+		p.recordFrame(r, frame)
+		for clientID, playerID := range p.getRoomClientPlayerIDs(r.id) {
+			filteredSnapshot, exists := frame.playerSnapshots[playerID]
+			if !exists {
+				// The player joined after the frame
+				continue
+			}
+			// Events are resent until the client acks them
+			events := frame.getEventsAfter(p.server.GetClientAckEventSeq(clientID))
+			req := &protocol.AddWorldSnapshotRequest{
+				Tick:          frame.tick,
+				WorldSnapshot: filteredSnapshot,
+				Events:        protocol.FilterGameEvents(events, filteredSnapshot),
+				EventSeq:      frame.eventSeq,
+			}
+			if err := p.server.SendTo(clientID, protocol.CmdAddWorldSnapshot, req); err != nil {
+				logger.Errorf(ctx, err.Error())
+			}
+		}
+	}
+}
+
+// recordFrame writes the whole world to its replay file, a new file is
+// started whenever the world is reset.
+func (p *serverProcessor) recordFrame(r *room, frame *roomFrame) {
+	if frame.fullSnapshot == nil {
+		return
+	}
+	ctx := context.Background()
+	if r.recordWorldID != frame.worldID {
+		p.closeRecorder(r)
+		r.recordWorldID = frame.worldID
+		r.recordEventSeq = 0
+		if err := os.MkdirAll(p.cfg.ReplayDir, 0755); err != nil {
+			logger.Errorf(ctx, err.Error())
+			return
 		}
-		if err := p.server.Broadcast(protocol.CmdAddWorldSnapshot, req); err != nil {
+		fileName := filepath.Join(p.cfg.ReplayDir, frame.worldID+replay.FileExt)
+		recorder, err := replay.NewRecorder(fileName, frame.worldID, frame.worldType, frame.startTime)
+		if err != nil {
 			logger.Errorf(ctx, err.Error())
+			return
 		}
+		logger.Infof(ctx, "record replay|room_id:%s|file:%s", r.id, fileName)
+		r.recorder = recorder
 	}
+	if r.recorder == nil {
+		return
+	}
+	events := frame.getEventsAfter(r.recordEventSeq)
+	r.recordEventSeq = frame.eventSeq
+	if err := r.recorder.Record(frame.tick, frame.fullSnapshot, events); err != nil {
+		logger.Errorf(ctx, err.Error())
+	}
+}
+
+func (p *serverProcessor) closeRecorder(r *room) {
+	if r.recorder == nil {
+		return
+	}
+	if err := r.recorder.Close(); err != nil {
+		logger.Errorf(context.Background(), err.Error())
+	}
+	r.recorder = nil
 }
 
-func (p *serverProcessor) CleanWorld() {
+// cleanRoom removes players who stopped sending inputs, and closes the room
+// when it has been idle for too long.
+func (p *serverProcessor) cleanRoom(r *room) {
+	ticker := time.NewTicker(time.Second / config.ServerSyncRate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.closeSig:
+			return
+		case <-ticker.C:
+		}
+		t := r.getWorld().GetClock().GetServerTime().Add(-p.cfg.PlayerTimeOut.Duration)
+		for _, playerID := range r.getTimeOutPlayerIDs(t) {
+			// The client may still be connected without sending inputs
+			if clientID := p.removePlayer(r, playerID); clientID != "" {
+				p.disconnectClient(clientID, protocol.DisconnectReasonTimeOut)
+			}
+		}
+		if r.isIdle(config.RoomIdleTimeOut) {
+			p.closeRoom(r)
+			return
+		}
+	}
+}
+
+// cleanClients forgets the players of clients which are not connected
+// anymore, the players themselves time out in their rooms.
+func (p *serverProcessor) cleanClients() {
 	ticker := time.NewTicker(time.Second / config.ServerSyncRate)
 	for range ticker.C {
-		p.lastActiveTimeLock.RLock()
-		now := ticktime.GetServerTime()
-		for playerID, lastActiveTime := range p.lastActiveTimeMap {
-			if now.Sub(lastActiveTime) > config.PlayerTimeOut {
-				if o, exists := p.world.GetObjectDB().SelectOne(playerID); exists {
-					player := o.(common.Player)
-					player.Die("", "")
-				}
-				p.world.GetObjectDB().Delete(playerID)
+		clientIDs := p.server.GetClientIDs()
+		p.cleanClientPlayers(clientIDs)
+	}
+}
+
+// logNetworkStats logs why connections were torn down and which clients
+// fall behind on pushes.
+func (p *serverProcessor) logNetworkStats() {
+	ctx := context.Background()
+	ticker := time.NewTicker(config.NetworkStatsPeriod)
+	for range ticker.C {
+		stats := p.server.GetClientStats()
+		logger.Infof(ctx, "network stats|clients:%d|errors:%+v", len(stats), p.server.GetErrorCounts())
+		for clientID, s := range stats {
+			if s.QueueDepth > 0 || s.DropCount > 0 {
+				logger.Infof(ctx, "slow client|client_id:%s|queue_depth:%d|drop_count:%d",
+					clientID, s.QueueDepth, s.DropCount)
 			}
 		}
-		p.lastActiveTimeLock.RUnlock()
 	}
 }
 
-func (p *serverProcessor) markActiveTime(playerID string) {
-	p.lastActiveTimeLock.Lock()
-	defer p.lastActiveTimeLock.Unlock()
-	p.lastActiveTimeMap[playerID] = ticktime.GetServerTime()
+func (p *serverProcessor) setClientPlayer(clientID, roomID, playerID string) {
+	p.clientPlayerLock.Lock()
+	defer p.clientPlayerLock.Unlock()
+	p.clientPlayerMap[clientID] = &clientPlayer{
+		roomID:   roomID,
+		playerID: playerID,
+	}
+}
+
+func (p *serverProcessor) removeClientPlayer(clientID string) {
+	p.clientPlayerLock.Lock()
+	defer p.clientPlayerLock.Unlock()
+	delete(p.clientPlayerMap, clientID)
+}
+
+func (p *serverProcessor) cleanClientPlayers(clientIDs []string) {
+	p.clientPlayerLock.Lock()
+	defer p.clientPlayerLock.Unlock()
+	existsMap := make(map[string]bool)
+	for _, clientID := range clientIDs {
+		existsMap[clientID] = true
+	}
+	for clientID := range p.clientPlayerMap {
+		if !existsMap[clientID] {
+			delete(p.clientPlayerMap, clientID)
+		}
+	}
+}
+
+func (p *serverProcessor) getClientPlayer(clientID string) (roomID, playerID string, exists bool) {
+	p.clientPlayerLock.RLock()
+	defer p.clientPlayerLock.RUnlock()
+	cp, exists := p.clientPlayerMap[clientID]
+	if !exists {
+		return "", "", false
+	}
+	return cp.roomID, cp.playerID, true
+}
+
+// getRoomClientPlayerIDs returns the player of each client in a room
+func (p *serverProcessor) getRoomClientPlayerIDs(roomID string) map[string]string {
+	p.clientPlayerLock.RLock()
+	defer p.clientPlayerLock.RUnlock()
+	playerIDs := make(map[string]string)
+	for clientID, cp := range p.clientPlayerMap {
+		if cp.roomID == roomID {
+			playerIDs[clientID] = cp.playerID
+		}
+	}
+	return playerIDs
+}
+
+// removePlayer takes a player out of the world of its room and returns the
+// client which had its session, the world drops it on the next command run.
+func (p *serverProcessor) removePlayer(r *room, playerID string) (clientID string) {
+	r.post(func(world common.WorldSimulation) {
+		if o, exists := world.GetObjectDB().SelectOne(playerID); exists {
+			player := o.(common.Player)
+			player.Die("", "")
+		}
+		world.GetObjectDB().Delete(playerID)
+	})
+	r.removeActiveTime(playerID)
+	return p.sessions.removePlayer(r.id, playerID)
+}
+
+// disconnectClient tells a client why it won't get snapshots anymore
+func (p *serverProcessor) disconnectClient(clientID string, reason int) {
+	p.removeClientPlayer(clientID)
+	req := &protocol.DisconnectRequest{Reason: reason}
+	if err := p.server.SendTo(clientID, protocol.CmdDisconnect, req); err != nil {
+		logger.Errorf(context.Background(), err.Error())
+	}
+}
+
+// Shutdown tells every client that the server is going away, then stops
+// the network.
+func (p *serverProcessor) Shutdown() {
+	ctx := context.Background()
+	logger.Infof(ctx, "shut down server")
+	req := &protocol.DisconnectRequest{Reason: protocol.DisconnectReasonServerShutdown}
+	if err := p.server.Broadcast(protocol.CmdDisconnect, req); err != nil {
+		logger.Errorf(ctx, err.Error())
+	}
+	for _, r := range p.rooms.getAll() {
+		p.closeRoom(r)
+	}
+	// Give push workers time to write the disconnect
+	time.Sleep(serverShutdownFlushTime)
+	if err := p.server.Close(); err != nil {
+		logger.Errorf(ctx, err.Error())
+	}
 }
 
-func (p *serverProcessor) process(cmd int, req interface{}) (resp interface{}) {
+func (p *serverProcessor) process(clientID string, cmd int, req interface{}) (resp interface{}) {
 	switch cmd {
+	case protocol.CmdHandshake:
+		resp = p.processHandshake(clientID, req)
 	case protocol.CmdRegisterPlayer:
-		resp = p.processRegisterPlayer(req)
+		resp = p.processRegisterPlayer(clientID, req)
+	case protocol.CmdResumeSession:
+		resp = p.processResumeSession(clientID, req)
 	case protocol.CmdSetPlayerInput:
-		resp = p.processSetPlayerInput(req)
+		resp = p.processSetPlayerInput(clientID, req)
+	case protocol.CmdDisconnect:
+		resp = p.processDisconnect(clientID, req)
+	case protocol.CmdTimeSync:
+		resp = p.processTimeSync(req)
+	case protocol.CmdListRooms:
+		resp = p.processListRooms()
+	case protocol.CmdCreateRoom:
+		resp = p.processCreateRoom(clientID, req)
 	}
 	return resp
 }
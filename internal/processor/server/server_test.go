@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/network"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+)
+
+// testServer keeps what the processor pushes instead of sending it
+type testServer struct {
+	clientIDs []string
+	pushes    map[string][][]byte
+	lock      sync.Mutex
+}
+
+func newTestServer(clientIDs ...string) *testServer {
+	return &testServer{
+		clientIDs: clientIDs,
+		pushes:    make(map[string][][]byte),
+	}
+}
+
+func (s *testServer) Start() error { return nil }
+func (s *testServer) Wait()        {}
+func (s *testServer) Close() error { return nil }
+func (s *testServer) Broadcast(data []byte) {
+	s.Multicast(s.GetClientIDs(), data)
+}
+
+func (s *testServer) Multicast(clientIDs []string, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, clientID := range clientIDs {
+		s.pushes[clientID] = append(s.pushes[clientID], data)
+	}
+}
+
+func (s *testServer) MulticastLatest(clientIDs []string, data []byte) {
+	s.Multicast(clientIDs, data)
+}
+
+func (s *testServer) SendTo(clientID string, data []byte) {
+	s.Multicast([]string{clientID}, data)
+}
+
+func (s *testServer) GetClientIDs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.clientIDs...)
+}
+
+func (s *testServer) GetErrorCounts() network.ErrorCounts {
+	return network.ErrorCounts{}
+}
+
+func (s *testServer) GetClientStats() map[string]network.ClientStats {
+	return nil
+}
+
+// takePushes returns and forgets what was pushed to a client
+func (s *testServer) takePushes(clientID string) [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pushes := s.pushes[clientID]
+	delete(s.pushes, clientID)
+	return pushes
+}
+
+// newTestServerProcessor is a processor without rooms whose network pushes
+// to server, requests are passed to the process of the returned network.
+func newTestServerProcessor(cfg *config.ServerConfig, server network.Server) (*serverProcessor, network.Process) {
+	p := newServerProcessor(cfg)
+	s := NewServerNetwork(cfg, p.process).(*serverNetwork)
+	s.server = server
+	p.server = s
+	return p, s.translateProcess(p.process)
+}
+
+// handshake makes clientID a client of the newest version
+func handshake(process network.Process, clientID string, codecType int) *protocol.HandshakeResponse {
+	codec := protocol.GetCodec(codecType)
+	reqBytes, _ := codec.Marshal(&protocol.WrappedData{
+		Cmd: protocol.CmdHandshake,
+		Handshake: &protocol.HandshakeRequest{
+			MinVersion:   protocol.ProtocolVersion,
+			MaxVersion:   protocol.ProtocolVersion,
+			Codecs:       []int{codecType},
+			Capabilities: protocol.GetSupportedCapabilities(),
+		},
+	})
+	resp := &protocol.HandshakeResponse{}
+	if err := codec.Unmarshal(process(clientID, reqBytes), resp); err != nil {
+		return nil
+	}
+	return resp
+}
+
+func TestWorldsAreDrawnFromSeed(t *testing.T) {
+	cfg := config.NewServerConfig()
+	cfg.World.Seed = 7
+	a := newServerProcessor(cfg)
+	b := newServerProcessor(cfg)
+	codec := protocol.GetCodec(protocol.CodecBinary)
+	worldIDs := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		worldA := a.newWorld()
+		worldB := b.newWorld()
+		_, snapshotA := worldA.GetSnapshot(true)
+		_, snapshotB := worldB.GetSnapshot(true)
+		dataA, _ := codec.Marshal(snapshotA)
+		dataB, _ := codec.Marshal(snapshotB)
+		if !bytes.Equal(dataA, dataB) {
+			t.Fatalf("world %d differs", i)
+		}
+		// A new game in a room must look new to clients and the recorder
+		if worldIDs[worldA.GetID()] {
+			t.Fatalf("world %d has the id of an earlier world", i)
+		}
+		worldIDs[worldA.GetID()] = true
+		worldA.Destroy()
+		worldB.Destroy()
+	}
+}
+
+// Clients come and go in several rooms while their tick and broadcast loops
+// run, run it with -race.
+func TestConcurrentClients(t *testing.T) {
+	replayDir, err := ioutil.TempDir("", "replays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(replayDir)
+	cfg := config.NewServerConfig()
+	cfg.MuxAddr = "127.0.0.1:0"
+	cfg.TCPAddrA = "127.0.0.1:0"
+	cfg.TCPAddrB = "127.0.0.1:0"
+	cfg.UDPAddr = "127.0.0.1:0"
+	cfg.ReplayDir = replayDir
+	cfg.Rooms = config.StringList{"main", "two"}
+	cfg.World.MinPlayers = 4
+	sp, err := NewServerProcessor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Shutdown()
+	p := sp.(*serverProcessor)
+	p.Start()
+	var wg sync.WaitGroup
+	var registered int32
+	deadline := time.Now().Add(2 * time.Second)
+	for c := 0; c < 8; c++ {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clientID := fmt.Sprintf("client-%d", c)
+			roomName := []string{"main", "two", fmt.Sprintf("room-%d", c)}[c%3]
+			for time.Now().Before(deadline) {
+				p.process(clientID, protocol.CmdHandshake, &protocol.HandshakeRequest{
+					MinVersion:   protocol.ProtocolVersion,
+					MaxVersion:   protocol.ProtocolVersion,
+					Codecs:       []int{protocol.CodecBinary},
+					Capabilities: protocol.GetSupportedCapabilities(),
+				})
+				p.process(clientID, protocol.CmdCreateRoom, &protocol.CreateRoomRequest{Name: roomName})
+				resp := p.process(clientID, protocol.CmdRegisterPlayer, &protocol.RegisterPlayerRequest{
+					PlayerName: clientID,
+					RoomName:   roomName,
+				}).(*protocol.RegisterPlayerResponse)
+				if resp.ErrorCode != protocol.ErrorCodeNone {
+					continue
+				}
+				atomic.AddInt32(&registered, 1)
+				for seq := int64(1); seq < 50 && time.Now().Before(deadline); seq++ {
+					p.process(clientID, protocol.CmdSetPlayerInput, &protocol.SetPlayerInputRequest{
+						SessionToken: resp.SessionToken,
+						Seq:          seq,
+						InputSnapshot: &protocol.InputSnapshot{
+							Right:     seq%2 == 0,
+							Up:        seq%3 == 0,
+							Fire:      true,
+							CursorDir: util.V(1, 1),
+							Duration:  int64(10 * time.Millisecond),
+						},
+					})
+					if seq%20 == 0 {
+						p.process(clientID, protocol.CmdResumeSession, &protocol.ResumeSessionRequest{
+							SessionToken: resp.SessionToken,
+						})
+						p.process(clientID, protocol.CmdListRooms, &protocol.ListRoomsRequest{})
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+				p.process(clientID, protocol.CmdDisconnect, &protocol.DisconnectRequest{})
+			}
+		}()
+	}
+	wg.Wait()
+	if registered == 0 {
+		t.Fatal("no player was registered")
+	}
+	for _, name := range cfg.Rooms {
+		if _, exists := p.rooms.getByName(name); !exists {
+			t.Fatalf("permanent room %s was closed", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+)
+
+type session struct {
+	roomID   string
+	playerID string
+	clientID string
+}
+
+// sessionStore binds secret tokens to a player in a room and to the network
+// client which the token was issued to.
+type sessionStore struct {
+	sessionMap map[string]*session
+	lock       sync.RWMutex
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessionMap: make(map[string]*session),
+	}
+}
+
+func (s *sessionStore) create(clientID, roomID, playerID string) (token string, err error) {
+	if token, err = util.GenerateToken(); err != nil {
+		return "", err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessionMap[token] = &session{
+		roomID:   roomID,
+		playerID: playerID,
+		clientID: clientID,
+	}
+	return token, nil
+}
+
+// validate returns the player of a token, ok is false when the token is
+// unknown or it is used from another client.
+func (s *sessionStore) validate(token, clientID string) (roomID, playerID string, ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	sess, exists := s.sessionMap[token]
+	if !exists || sess.clientID != clientID {
+		return "", "", false
+	}
+	return sess.roomID, sess.playerID, true
+}
+
+// resume moves a session to a new client, the client which had it before
+// can't use it anymore.
+func (s *sessionStore) resume(token, clientID string) (roomID, playerID, prevClientID string, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess, exists := s.sessionMap[token]
+	if !exists {
+		return "", "", "", false
+	}
+	prevClientID = sess.clientID
+	sess.clientID = clientID
+	return sess.roomID, sess.playerID, prevClientID, true
+}
+
+// removePlayer forgets the session of a player and returns the client which
+// had it, clientID is empty when the player has no session.
+func (s *sessionStore) removePlayer(roomID, playerID string) (clientID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for token, sess := range s.sessionMap {
+		if sess.roomID == roomID && sess.playerID == playerID {
+			clientID = sess.clientID
+			delete(s.sessionMap, token)
+		}
+	}
+	return clientID
+}
+
+// removeRoom forgets the sessions of every player in a room
+func (s *sessionStore) removeRoom(roomID string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for token, sess := range s.sessionMap {
+		if sess.roomID == roomID {
+			delete(s.sessionMap, token)
+		}
+	}
+}
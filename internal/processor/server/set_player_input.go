@@ -1,12 +1,40 @@
 package server
 
 import (
+	"context"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/go-logger"
 )
 
-func (p *serverProcessor) processSetPlayerInput(request interface{}) (resp *protocol.SetPlayerInputResponse) {
+func (p *serverProcessor) processSetPlayerInput(clientID string, request interface{}) (resp *protocol.SetPlayerInputResponse) {
 	req := request.(*protocol.SetPlayerInputRequest)
-	p.world.SetInputSnapshot(req.PlayerID, req.InputSnapshot)
-	p.markActiveTime(req.PlayerID)
+	// The player comes from the session, so a client can only drive its own.
+	// Legacy clients have no session, they drive the player they registered.
+	var roomID, playerID string
+	var ok bool
+	if p.server.GetClientVersion(clientID) < protocol.ProtocolVersionSessions {
+		roomID, playerID, ok = p.getClientPlayer(clientID)
+	} else {
+		roomID, playerID, ok = p.sessions.validate(req.SessionToken, clientID)
+	}
+	r, exists := p.rooms.get(roomID)
+	if !ok || !exists {
+		logger.Debugf(context.Background(), "reject input|client_id:%s", clientID)
+		return &protocol.SetPlayerInputResponse{
+			ErrorCode: protocol.ErrorCodeInvalidSession,
+		}
+	}
+	// Inputs are applied by the tick loop before the next tick
+	r.post(func(world common.WorldSimulation) {
+		// Resent inputs come first, the player skips ones it already has
+		for i, inputSS := range req.PrevInputSnapshots {
+			seq := req.Seq - int64(len(req.PrevInputSnapshots)-i)
+			world.SetInputSnapshot(playerID, seq, inputSS)
+		}
+		world.SetInputSnapshot(playerID, req.Seq, req.InputSnapshot)
+	})
+	r.markActiveTime(playerID, r.getWorld().GetClock().GetServerTime())
 	return &protocol.SetPlayerInputResponse{}
 }
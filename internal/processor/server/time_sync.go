@@ -0,0 +1,17 @@
+package server
+
+import (
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+func (p *serverProcessor) processTimeSync(request interface{}) (resp *protocol.TimeSyncResponse) {
+	receiveTime := p.clock.GetServerTime()
+	resp = &protocol.TimeSyncResponse{
+		ServerReceiveTime: receiveTime.UnixNano(),
+	}
+	if req := request.(*protocol.TimeSyncRequest); req != nil {
+		resp.ClientSendTime = req.ClientSendTime
+	}
+	resp.ServerSendTime = p.clock.GetServerTime().UnixNano()
+	return resp
+}
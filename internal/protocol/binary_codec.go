@@ -0,0 +1,540 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Every binary frame starts with this byte, json frames never do.
+const binaryCodecMagic = 0xb1
+
+// Vecs are decoded into blocks of this many instead of one by one
+const binaryVecBlockSize = 32
+
+var errBinaryCodecShortData = errors.New("binary codec: unexpected end of data")
+
+// binaryCodec writes protocol objects compactly:
+//   - pointers get a presence byte
+//   - bool fields of a struct are packed into bit fields in front of the struct
+//   - ints are zigzag varints, uints are varints
+//   - floats are float64, positions and times keep the precision they have
+//     with json, so a vec is always 16 bytes
+//   - strings are interned per frame, a repeated id costs one byte
+//   - slices and maps are prefixed with their length, map keys are sorted
+//
+// Messages sent every tick have hand written encoders, see
+// binary_codec_snapshot.go. Everything else is walked with reflection, which
+// writes the same bytes, so new messages are picked up without touching the
+// codec.
+type binaryCodec struct {
+	plans    sync.Map
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+type binaryStructPlan struct {
+	boolFields  []int
+	otherFields []int
+}
+
+func newBinaryCodec() *binaryCodec {
+	c := &binaryCodec{}
+	c.encoders.New = func() interface{} { return newBinaryEncoder(c) }
+	c.decoders.New = func() interface{} { return newBinaryDecoder(c) }
+	return c
+}
+
+func (c *binaryCodec) GetType() int {
+	return CodecBinary
+}
+
+func (c *binaryCodec) getPlan(t reflect.Type) *binaryStructPlan {
+	if plan, exists := c.plans.Load(t); exists {
+		return plan.(*binaryStructPlan)
+	}
+	plan := &binaryStructPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || strings.HasPrefix(field.Tag.Get("json"), "-") {
+			continue
+		}
+		if field.Type.Kind() == reflect.Bool {
+			plan.boolFields = append(plan.boolFields, i)
+		} else {
+			plan.otherFields = append(plan.otherFields, i)
+		}
+	}
+	c.plans.Store(t, plan)
+	return plan
+}
+
+func (c *binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	e := c.encoders.Get().(*binaryEncoder)
+	data, err := e.marshal(v)
+	e.reset()
+	c.encoders.Put(e)
+	return data, err
+}
+
+func (c *binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	d := c.decoders.Get().(*binaryDecoder)
+	err := d.unmarshal(data, v)
+	d.reset()
+	c.decoders.Put(d)
+	return err
+}
+
+// Encoder
+
+type binaryEncoder struct {
+	codec    *binaryCodec
+	buf      []byte
+	scratch  [binary.MaxVarintLen64]byte
+	strIndex map[string]int
+	keys     []string
+	err      error
+	// only walk with reflection, tests check it writes the same bytes
+	reflectOnly bool
+}
+
+func newBinaryEncoder(c *binaryCodec) *binaryEncoder {
+	e := &binaryEncoder{
+		codec:    c,
+		buf:      make([]byte, 0, 512),
+		strIndex: make(map[string]int),
+	}
+	e.reset()
+	return e
+}
+
+// reset forgets the last frame, the buffers are kept for the next one
+func (e *binaryEncoder) reset() {
+	e.buf = e.buf[:0]
+	for s := range e.strIndex {
+		delete(e.strIndex, s)
+	}
+	e.strIndex[""] = 0
+	e.err = nil
+}
+
+func (e *binaryEncoder) marshal(v interface{}) ([]byte, error) {
+	e.buf = append(e.buf, binaryCodecMagic)
+	if !e.encodeKnown(v) {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("binary codec: marshal non-pointer %T", v)
+		}
+		e.encode(rv)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+func (e *binaryEncoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *binaryEncoder) writeVarint(x int64) {
+	n := binary.PutVarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *binaryEncoder) writeUvarint(x uint64) {
+	n := binary.PutUvarint(e.scratch[:], x)
+	e.buf = append(e.buf, e.scratch[:n]...)
+}
+
+func (e *binaryEncoder) writeInt(x int) {
+	e.writeVarint(int64(x))
+}
+
+func (e *binaryEncoder) writeFloat(f float64) {
+	binary.LittleEndian.PutUint64(e.scratch[:8], math.Float64bits(f))
+	e.buf = append(e.buf, e.scratch[:8]...)
+}
+
+func (e *binaryEncoder) writeString(s string) {
+	if i, exists := e.strIndex[s]; exists {
+		e.writeUvarint(uint64(i + 1))
+		return
+	}
+	e.strIndex[s] = len(e.strIndex)
+	e.writeUvarint(0)
+	e.writeUvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *binaryEncoder) writeStrings(ss []string) {
+	e.writeUvarint(uint64(len(ss)))
+	for _, s := range ss {
+		e.writeString(s)
+	}
+}
+
+// writePresent writes the presence byte of a pointer
+func (e *binaryEncoder) writePresent(present bool) bool {
+	if present {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+	return present
+}
+
+// writeBits packs up to 8 bool fields into a byte, the first is the lowest bit
+func (e *binaryEncoder) writeBits(flags ...bool) {
+	var bits byte
+	for i, flag := range flags {
+		if flag {
+			bits |= 1 << uint(i)
+		}
+	}
+	e.buf = append(e.buf, bits)
+}
+
+func (e *binaryEncoder) encode(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if e.encodeKnown(v.Interface()) {
+			return
+		}
+		if !e.writePresent(!v.IsNil()) {
+			return
+		}
+		e.encode(v.Elem())
+	case reflect.Struct:
+		plan := e.codec.getPlan(v.Type())
+		if n := len(plan.boolFields); n > 0 {
+			start := len(e.buf)
+			for i := 0; i < (n+7)/8; i++ {
+				e.buf = append(e.buf, 0)
+			}
+			for i, fieldIndex := range plan.boolFields {
+				if v.Field(fieldIndex).Bool() {
+					e.buf[start+i/8] |= 1 << uint(i%8)
+				}
+			}
+		}
+		for _, fieldIndex := range plan.otherFields {
+			e.encode(v.Field(fieldIndex))
+		}
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeVarint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.writeUvarint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.writeFloat(v.Float())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Slice:
+		e.writeUvarint(uint64(v.Len()))
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.buf = append(e.buf, v.Bytes()...)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			e.encode(v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			e.fail(fmt.Errorf("binary codec: unsupported map key %v", v.Type().Key()))
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		e.writeUvarint(uint64(len(keys)))
+		for _, key := range keys {
+			e.writeString(key.String())
+			e.encode(v.MapIndex(key))
+		}
+	default:
+		e.fail(fmt.Errorf("binary codec: unsupported type %v", v.Type()))
+	}
+}
+
+// Decoder
+
+// binaryDecoder keeps the first error, reads after it return zero values, so
+// decoders only check it once at the end.
+type binaryDecoder struct {
+	codec *binaryCodec
+	data  []byte
+	pos   int
+	// every string of a frame is a slice of str, they share one allocation
+	str  string
+	strs []string
+	vecs []Vec
+	err  error
+	// only walk with reflection, tests check it reads the same values
+	reflectOnly bool
+}
+
+func newBinaryDecoder(c *binaryCodec) *binaryDecoder {
+	return &binaryDecoder{
+		codec: c,
+		strs:  []string{""},
+	}
+}
+
+// reset forgets the last frame, the unused vecs of a block are kept for the
+// next one
+func (d *binaryDecoder) reset() {
+	d.data = nil
+	d.pos = 0
+	d.str = ""
+	for i := range d.strs {
+		d.strs[i] = ""
+	}
+	d.strs = d.strs[:1]
+	d.err = nil
+}
+
+func (d *binaryDecoder) unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0] != binaryCodecMagic {
+		return errors.New("binary codec: invalid frame")
+	}
+	d.data = data
+	d.pos = 1
+	if !d.decodeKnownInto(v) {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("binary codec: unmarshal into non-pointer %T", v)
+		}
+		if d.readPresent() {
+			d.decode(rv.Elem())
+		}
+	}
+	return d.err
+}
+
+func (d *binaryDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *binaryDecoder) remaining() int {
+	return len(d.data) - d.pos
+}
+
+func (d *binaryDecoder) readByte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.remaining() < 1 {
+		d.fail(errBinaryCodecShortData)
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *binaryDecoder) readVarint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail(errBinaryCodecShortData)
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+func (d *binaryDecoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail(errBinaryCodecShortData)
+		return 0
+	}
+	d.pos += n
+	return x
+}
+
+func (d *binaryDecoder) readInt() int {
+	return int(d.readVarint())
+}
+
+// readLength reads a length which must fit in the rest of the frame, so a
+// broken frame can not make us allocate a huge slice.
+func (d *binaryDecoder) readLength() int {
+	n := d.readUvarint()
+	if n > uint64(d.remaining()) {
+		d.fail(errBinaryCodecShortData)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *binaryDecoder) readFloat() float64 {
+	if d.err != nil {
+		return 0
+	}
+	if d.remaining() < 8 {
+		d.fail(errBinaryCodecShortData)
+		return 0
+	}
+	bits := binary.LittleEndian.Uint64(d.data[d.pos:])
+	d.pos += 8
+	return math.Float64frombits(bits)
+}
+
+func (d *binaryDecoder) readString() string {
+	i := d.readUvarint()
+	if i > 0 {
+		if i > uint64(len(d.strs)) {
+			d.fail(errors.New("binary codec: invalid string reference"))
+			return ""
+		}
+		return d.strs[i-1]
+	}
+	n := d.readLength()
+	if d.err != nil {
+		return ""
+	}
+	if d.str == "" {
+		d.str = string(d.data)
+	}
+	s := d.str[d.pos : d.pos+n]
+	d.pos += n
+	d.strs = append(d.strs, s)
+	return s
+}
+
+func (d *binaryDecoder) readStrings() []string {
+	n := d.readLength()
+	if n == 0 {
+		return nil
+	}
+	ss := make([]string, n)
+	for i := range ss {
+		ss[i] = d.readString()
+	}
+	return ss
+}
+
+// readPresent reads the presence byte of a pointer
+func (d *binaryDecoder) readPresent() bool {
+	return d.readByte() != 0
+}
+
+// readBits reads a byte of packed bool fields
+func (d *binaryDecoder) readBits() byte {
+	return d.readByte()
+}
+
+func (d *binaryDecoder) newVec() *Vec {
+	if len(d.vecs) == 0 {
+		d.vecs = make([]Vec, binaryVecBlockSize)
+	}
+	v := &d.vecs[0]
+	d.vecs = d.vecs[1:]
+	return v
+}
+
+func (d *binaryDecoder) decode(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if known, ok := d.decodeKnown(v.Type()); ok {
+			v.Set(known)
+			return
+		}
+		if !d.readPresent() {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(v.Elem())
+	case reflect.Struct:
+		plan := d.codec.getPlan(v.Type())
+		if n := len(plan.boolFields); n > 0 {
+			size := (n + 7) / 8
+			if d.remaining() < size {
+				d.fail(errBinaryCodecShortData)
+				return
+			}
+			bits := d.data[d.pos : d.pos+size]
+			d.pos += size
+			for i, fieldIndex := range plan.boolFields {
+				v.Field(fieldIndex).SetBool(bits[i/8]&(1<<uint(i%8)) != 0)
+			}
+		}
+		for _, fieldIndex := range plan.otherFields {
+			d.decode(v.Field(fieldIndex))
+		}
+	case reflect.Bool:
+		v.SetBool(d.readByte() != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(d.readVarint())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(d.readUvarint())
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(d.readFloat())
+	case reflect.String:
+		v.SetString(d.readString())
+	case reflect.Slice:
+		n := d.readLength()
+		if n == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte{}, d.data[d.pos:d.pos+n]...))
+			d.pos += n
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			d.decode(slice.Index(i))
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			d.fail(fmt.Errorf("binary codec: unsupported map key %v", v.Type().Key()))
+			return
+		}
+		n := d.readLength()
+		if n == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		for i := 0; i < n; i++ {
+			key := d.readString()
+			value := reflect.New(v.Type().Elem()).Elem()
+			d.decode(value)
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), value)
+		}
+		v.Set(m)
+	default:
+		d.fail(fmt.Errorf("binary codec: unsupported type %v", v.Type()))
+	}
+}
@@ -0,0 +1,748 @@
+package protocol
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Hand written encoders of the messages sent every tick. They write the same
+// bytes as the reflection walker: bool fields packed in front, then the other
+// fields in the order they are declared. A field added to one of these
+// structs must be added here as well.
+
+var (
+	binaryAddWorldSnapshotRequestType = reflect.TypeOf((*AddWorldSnapshotRequest)(nil))
+	binarySetPlayerInputRequestType   = reflect.TypeOf((*SetPlayerInputRequest)(nil))
+	binaryInputSnapshotType           = reflect.TypeOf((*InputSnapshot)(nil))
+	binaryWorldSnapshotType           = reflect.TypeOf((*WorldSnapshot)(nil))
+	binaryObjectSnapshotType          = reflect.TypeOf((*ObjectSnapshot)(nil))
+	binaryPlayerSnapshotType          = reflect.TypeOf((*PlayerSnapshot)(nil))
+	binaryGameEventType               = reflect.TypeOf((*GameEvent)(nil))
+	binaryVecType                     = reflect.TypeOf((*Vec)(nil))
+)
+
+// encodeKnown writes v with its hand written encoder, it returns false when
+// v has none.
+func (e *binaryEncoder) encodeKnown(v interface{}) bool {
+	if e.reflectOnly {
+		return false
+	}
+	switch x := v.(type) {
+	case *AddWorldSnapshotRequest:
+		e.writeAddWorldSnapshotRequest(x)
+	case *SetPlayerInputRequest:
+		e.writeSetPlayerInputRequest(x)
+	case *InputSnapshot:
+		e.writeInputSnapshot(x)
+	case *WorldSnapshot:
+		e.writeWorldSnapshot(x)
+	case *ObjectSnapshot:
+		e.writeObjectSnapshot(x)
+	case *PlayerSnapshot:
+		e.writePlayerSnapshot(x)
+	case *GameEvent:
+		e.writeGameEvent(x)
+	case *Vec:
+		e.writeVec(x)
+	default:
+		return false
+	}
+	return true
+}
+
+// decodeKnown reads a pointer of type t with its hand written decoder, it
+// returns false when t has none.
+func (d *binaryDecoder) decodeKnown(t reflect.Type) (reflect.Value, bool) {
+	if d.reflectOnly {
+		return reflect.Value{}, false
+	}
+	switch t {
+	case binaryAddWorldSnapshotRequestType:
+		return reflect.ValueOf(d.readAddWorldSnapshotRequest()), true
+	case binarySetPlayerInputRequestType:
+		return reflect.ValueOf(d.readSetPlayerInputRequest()), true
+	case binaryInputSnapshotType:
+		return reflect.ValueOf(d.readInputSnapshot()), true
+	case binaryWorldSnapshotType:
+		return reflect.ValueOf(d.readWorldSnapshot()), true
+	case binaryObjectSnapshotType:
+		return reflect.ValueOf(d.readObjectSnapshot()), true
+	case binaryPlayerSnapshotType:
+		return reflect.ValueOf(d.readPlayerSnapshot()), true
+	case binaryGameEventType:
+		return reflect.ValueOf(d.readGameEvent()), true
+	case binaryVecType:
+		return reflect.ValueOf(d.readVec()), true
+	}
+	return reflect.Value{}, false
+}
+
+// decodeKnownInto reads the frame into v with its hand written decoder, it
+// returns false when v has none.
+func (d *binaryDecoder) decodeKnownInto(v interface{}) bool {
+	if d.reflectOnly {
+		return false
+	}
+	switch x := v.(type) {
+	case *AddWorldSnapshotRequest:
+		if d.readPresent() {
+			d.readAddWorldSnapshotRequestInto(x)
+		}
+	case *SetPlayerInputRequest:
+		if d.readPresent() {
+			d.readSetPlayerInputRequestInto(x)
+		}
+	case *WorldSnapshot:
+		if d.readPresent() {
+			d.readWorldSnapshotInto(x)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Common
+
+func (e *binaryEncoder) writeVec(v *Vec) {
+	if !e.writePresent(v != nil) {
+		return
+	}
+	e.writeFloat(v.X)
+	e.writeFloat(v.Y)
+}
+
+func (d *binaryDecoder) readVec() *Vec {
+	if !d.readPresent() {
+		return nil
+	}
+	v := d.newVec()
+	v.X = d.readFloat()
+	v.Y = d.readFloat()
+	return v
+}
+
+func (e *binaryEncoder) writeRect(r *Rect) {
+	if !e.writePresent(r != nil) {
+		return
+	}
+	e.writeVec(r.Min)
+	e.writeVec(r.Max)
+}
+
+func (d *binaryDecoder) readRect() *Rect {
+	if !d.readPresent() {
+		return nil
+	}
+	return &Rect{
+		Min: d.readVec(),
+		Max: d.readVec(),
+	}
+}
+
+// Requests
+
+func (e *binaryEncoder) writeAddWorldSnapshotRequest(req *AddWorldSnapshotRequest) {
+	if !e.writePresent(req != nil) {
+		return
+	}
+	e.writeVarint(req.Tick)
+	e.writeWorldSnapshot(req.WorldSnapshot)
+	e.writeUvarint(uint64(len(req.Events)))
+	for _, event := range req.Events {
+		e.writeGameEvent(event)
+	}
+	e.writeVarint(req.EventSeq)
+}
+
+func (d *binaryDecoder) readAddWorldSnapshotRequest() *AddWorldSnapshotRequest {
+	if !d.readPresent() {
+		return nil
+	}
+	req := &AddWorldSnapshotRequest{}
+	d.readAddWorldSnapshotRequestInto(req)
+	return req
+}
+
+func (d *binaryDecoder) readAddWorldSnapshotRequestInto(req *AddWorldSnapshotRequest) {
+	req.Tick = d.readVarint()
+	req.WorldSnapshot = d.readWorldSnapshot()
+	if n := d.readLength(); n > 0 {
+		req.Events = make([]*GameEvent, n)
+		for i := range req.Events {
+			req.Events[i] = d.readGameEvent()
+		}
+	}
+	req.EventSeq = d.readVarint()
+}
+
+func (e *binaryEncoder) writeSetPlayerInputRequest(req *SetPlayerInputRequest) {
+	if !e.writePresent(req != nil) {
+		return
+	}
+	e.writeString(req.SessionToken)
+	e.writeInputSnapshot(req.InputSnapshot)
+	e.writeVarint(req.AckTick)
+	e.writeVarint(req.AckEventSeq)
+	e.writeVarint(req.Seq)
+	e.writeUvarint(uint64(len(req.PrevInputSnapshots)))
+	for _, input := range req.PrevInputSnapshots {
+		e.writeInputSnapshot(input)
+	}
+}
+
+func (d *binaryDecoder) readSetPlayerInputRequest() *SetPlayerInputRequest {
+	if !d.readPresent() {
+		return nil
+	}
+	req := &SetPlayerInputRequest{}
+	d.readSetPlayerInputRequestInto(req)
+	return req
+}
+
+func (d *binaryDecoder) readSetPlayerInputRequestInto(req *SetPlayerInputRequest) {
+	req.SessionToken = d.readString()
+	req.InputSnapshot = d.readInputSnapshot()
+	req.AckTick = d.readVarint()
+	req.AckEventSeq = d.readVarint()
+	req.Seq = d.readVarint()
+	if n := d.readLength(); n > 0 {
+		req.PrevInputSnapshots = make([]*InputSnapshot, n)
+		for i := range req.PrevInputSnapshots {
+			req.PrevInputSnapshots[i] = d.readInputSnapshot()
+		}
+	}
+}
+
+func (e *binaryEncoder) writeInputSnapshot(input *InputSnapshot) {
+	if !e.writePresent(input != nil) {
+		return
+	}
+	e.writeBits(input.Fire, input.Melee, input.Focus, input.Up,
+		input.Left, input.Down, input.Right, input.Reload)
+	e.writeBits(input.Drop, input.Use1stItem, input.Use2ndItem, input.Use3rdItem)
+	e.writeVec(input.CursorDir)
+	e.writeVarint(input.ViewTime)
+	e.writeVarint(input.Duration)
+	e.writeVarint(input.Time)
+}
+
+func (d *binaryDecoder) readInputSnapshot() *InputSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	bits := d.readBits()
+	moreBits := d.readBits()
+	return &InputSnapshot{
+		Fire:       bits&(1<<0) != 0,
+		Melee:      bits&(1<<1) != 0,
+		Focus:      bits&(1<<2) != 0,
+		Up:         bits&(1<<3) != 0,
+		Left:       bits&(1<<4) != 0,
+		Down:       bits&(1<<5) != 0,
+		Right:      bits&(1<<6) != 0,
+		Reload:     bits&(1<<7) != 0,
+		Drop:       moreBits&(1<<0) != 0,
+		Use1stItem: moreBits&(1<<1) != 0,
+		Use2ndItem: moreBits&(1<<2) != 0,
+		Use3rdItem: moreBits&(1<<3) != 0,
+		CursorDir:  d.readVec(),
+		ViewTime:   d.readVarint(),
+		Duration:   d.readVarint(),
+		Time:       d.readVarint(),
+	}
+}
+
+func (e *binaryEncoder) writeGameEvent(event *GameEvent) {
+	if !e.writePresent(event != nil) {
+		return
+	}
+	e.writeVarint(event.Seq)
+	e.writeVarint(event.Tick)
+	e.writeInt(event.Type)
+	e.writeString(event.ObjectID)
+	e.writeString(event.PlayerID)
+	e.writeVec(event.Pos)
+	e.writeFloat(event.Value)
+}
+
+func (d *binaryDecoder) readGameEvent() *GameEvent {
+	if !d.readPresent() {
+		return nil
+	}
+	return &GameEvent{
+		Seq:      d.readVarint(),
+		Tick:     d.readVarint(),
+		Type:     d.readInt(),
+		ObjectID: d.readString(),
+		PlayerID: d.readString(),
+		Pos:      d.readVec(),
+		Value:    d.readFloat(),
+	}
+}
+
+// World
+
+func (e *binaryEncoder) writeWorldSnapshot(ws *WorldSnapshot) {
+	if !e.writePresent(ws != nil) {
+		return
+	}
+	e.writeBits(ws.IsDelta)
+	e.writeString(ws.ID)
+	e.writeInt(ws.Type)
+	e.writeKillFeedSnapshot(ws.KillFeedSnapshot)
+	e.writeUvarint(uint64(len(ws.ObjectSnapshots)))
+	for _, ss := range ws.ObjectSnapshots {
+		e.writeObjectSnapshot(ss)
+	}
+	e.writeInt(ws.FieldWidth)
+	e.writeInt(ws.FieldHeight)
+	e.writeInt(ws.RespawnTimeMS)
+	e.writeInt(ws.InitTimeMS)
+	e.writeVarint(ws.BaseTick)
+	e.writeUvarint(uint64(len(ws.ObjectDeltas)))
+	for _, delta := range ws.ObjectDeltas {
+		e.writeObjectDelta(delta)
+	}
+	e.writeStrings(ws.RemovedIDs)
+}
+
+func (d *binaryDecoder) readWorldSnapshot() *WorldSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ws := &WorldSnapshot{}
+	d.readWorldSnapshotInto(ws)
+	return ws
+}
+
+func (d *binaryDecoder) readWorldSnapshotInto(ws *WorldSnapshot) {
+	ws.IsDelta = d.readBits()&1 != 0
+	ws.ID = d.readString()
+	ws.Type = d.readInt()
+	ws.KillFeedSnapshot = d.readKillFeedSnapshot()
+	if n := d.readLength(); n > 0 {
+		// Object snapshots share one allocation
+		ws.ObjectSnapshots = make([]*ObjectSnapshot, n)
+		block := make([]ObjectSnapshot, n)
+		for i := range ws.ObjectSnapshots {
+			if d.readPresent() {
+				d.readObjectSnapshotInto(&block[i])
+				ws.ObjectSnapshots[i] = &block[i]
+			}
+		}
+	}
+	ws.FieldWidth = d.readInt()
+	ws.FieldHeight = d.readInt()
+	ws.RespawnTimeMS = d.readInt()
+	ws.InitTimeMS = d.readInt()
+	ws.BaseTick = d.readVarint()
+	if n := d.readLength(); n > 0 {
+		ws.ObjectDeltas = make([]*ObjectDelta, n)
+		for i := range ws.ObjectDeltas {
+			ws.ObjectDeltas[i] = d.readObjectDelta()
+		}
+	}
+	ws.RemovedIDs = d.readStrings()
+}
+
+func (e *binaryEncoder) writeKillFeedSnapshot(ss *KillFeedSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeUvarint(uint64(len(ss.Rows)))
+	for _, row := range ss.Rows {
+		if e.writePresent(row != nil) {
+			e.writeVarint(row.CreateTime)
+			e.writeString(row.KillerPlayerID)
+			e.writeString(row.VictimPlayerID)
+			e.writeString(row.WeaponID)
+		}
+	}
+}
+
+func (d *binaryDecoder) readKillFeedSnapshot() *KillFeedSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ss := &KillFeedSnapshot{}
+	if n := d.readLength(); n > 0 {
+		ss.Rows = make([]*KillFeedRow, n)
+		for i := range ss.Rows {
+			if d.readPresent() {
+				ss.Rows[i] = &KillFeedRow{
+					CreateTime:     d.readVarint(),
+					KillerPlayerID: d.readString(),
+					VictimPlayerID: d.readString(),
+					WeaponID:       d.readString(),
+				}
+			}
+		}
+	}
+	return ss
+}
+
+func (e *binaryEncoder) writeObjectDelta(delta *ObjectDelta) {
+	if !e.writePresent(delta != nil) {
+		return
+	}
+	e.writeString(delta.ID)
+	e.writeUvarint(uint64(len(delta.Masks)))
+	for _, mask := range delta.Masks {
+		e.writeUvarint(mask)
+	}
+	e.writeObjectSnapshot(delta.Snapshot)
+}
+
+func (d *binaryDecoder) readObjectDelta() *ObjectDelta {
+	if !d.readPresent() {
+		return nil
+	}
+	delta := &ObjectDelta{ID: d.readString()}
+	if n := d.readLength(); n > 0 {
+		delta.Masks = make([]uint64, n)
+		for i := range delta.Masks {
+			delta.Masks[i] = d.readUvarint()
+		}
+	}
+	delta.Snapshot = d.readObjectSnapshot()
+	return delta
+}
+
+func (e *binaryEncoder) writeObjectSnapshot(ss *ObjectSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeString(ss.ID)
+	e.writeInt(ss.Type)
+	e.writePlayerSnapshot(ss.Player)
+	e.writeItemSnapshot(ss.Item)
+	e.writeWeaponSnapshot(ss.Weapon)
+	e.writeBulletSnapshot(ss.Bullet)
+	if e.writePresent(ss.Tree != nil) {
+		e.writeBits(ss.Tree.Right)
+		e.writeVec(ss.Tree.Pos)
+		e.writeString(ss.Tree.TreeType)
+	}
+	if e.writePresent(ss.Terrain != nil) {
+		e.writeVec(ss.Terrain.Pos)
+		e.writeInt(ss.Terrain.TerrainType)
+	}
+	if e.writePresent(ss.Boundary != nil) {
+		e.writeRect(ss.Boundary.Collider)
+	}
+}
+
+func (d *binaryDecoder) readObjectSnapshot() *ObjectSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ss := &ObjectSnapshot{}
+	d.readObjectSnapshotInto(ss)
+	return ss
+}
+
+func (d *binaryDecoder) readObjectSnapshotInto(ss *ObjectSnapshot) {
+	ss.ID = d.readString()
+	ss.Type = d.readInt()
+	ss.Player = d.readPlayerSnapshot()
+	ss.Item = d.readItemSnapshot()
+	ss.Weapon = d.readWeaponSnapshot()
+	ss.Bullet = d.readBulletSnapshot()
+	if d.readPresent() {
+		right := d.readBits()&1 != 0
+		ss.Tree = &TreeSnapshot{
+			Pos:      d.readVec(),
+			TreeType: d.readString(),
+			Right:    right,
+		}
+	}
+	if d.readPresent() {
+		ss.Terrain = &TerrainSnapshot{
+			Pos:         d.readVec(),
+			TerrainType: d.readInt(),
+		}
+	}
+	if d.readPresent() {
+		ss.Boundary = &BoundarySnapshot{Collider: d.readRect()}
+	}
+}
+
+// Player
+
+func (e *binaryEncoder) writePlayerSnapshot(ss *PlayerSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeBits(ss.IsInvulnerable, ss.IsVisible, ss.IsHidden, ss.IsBot)
+	e.writeString(ss.PlayerName)
+	e.writeString(ss.MeleeWeaponID)
+	e.writeString(ss.WeaponID)
+	e.writeStrings(ss.ItemIDs)
+	e.writeInt(ss.Kill)
+	e.writeInt(ss.Death)
+	e.writeInt(ss.Streak)
+	e.writeInt(ss.MaxStreak)
+	e.writeVec(ss.CursorDir)
+	e.writeVec(ss.Pos)
+	e.writeVec(ss.MoveDir)
+	e.writeFloat(ss.MoveSpeed)
+	e.writeFloat(ss.MaxMoveSpeed)
+	e.writeFloat(ss.HP)
+	e.writeFloat(ss.Armor)
+	e.writeVarint(ss.RespawnTime)
+	e.writeVarint(ss.HitTime)
+	e.writeVarint(ss.MeleeTime)
+	e.writeVarint(ss.TriggerTime)
+	e.writeVarint(ss.PickupTime)
+	e.writeInt(ss.HitVisibleMS)
+	e.writeInt(ss.TriggerVisibleMS)
+	e.writeVarint(ss.InputSeq)
+}
+
+func (d *binaryDecoder) readPlayerSnapshot() *PlayerSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	bits := d.readBits()
+	return &PlayerSnapshot{
+		IsInvulnerable:   bits&(1<<0) != 0,
+		IsVisible:        bits&(1<<1) != 0,
+		IsHidden:         bits&(1<<2) != 0,
+		IsBot:            bits&(1<<3) != 0,
+		PlayerName:       d.readString(),
+		MeleeWeaponID:    d.readString(),
+		WeaponID:         d.readString(),
+		ItemIDs:          d.readStrings(),
+		Kill:             d.readInt(),
+		Death:            d.readInt(),
+		Streak:           d.readInt(),
+		MaxStreak:        d.readInt(),
+		CursorDir:        d.readVec(),
+		Pos:              d.readVec(),
+		MoveDir:          d.readVec(),
+		MoveSpeed:        d.readFloat(),
+		MaxMoveSpeed:     d.readFloat(),
+		HP:               d.readFloat(),
+		Armor:            d.readFloat(),
+		RespawnTime:      d.readVarint(),
+		HitTime:          d.readVarint(),
+		MeleeTime:        d.readVarint(),
+		TriggerTime:      d.readVarint(),
+		PickupTime:       d.readVarint(),
+		HitVisibleMS:     d.readInt(),
+		TriggerVisibleMS: d.readInt(),
+		InputSeq:         d.readVarint(),
+	}
+}
+
+// Weapon
+
+func (e *binaryEncoder) writeBulletSnapshot(ss *BulletSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeString(ss.PlayerID)
+	e.writeString(ss.WeaponID)
+	e.writeVec(ss.InitPos)
+	e.writeVec(ss.Dir)
+	e.writeFloat(ss.Speed)
+	e.writeFloat(ss.MaxRange)
+	e.writeFloat(ss.Damage)
+	e.writeFloat(ss.Length)
+	e.writeVarint(ss.FireTime)
+	e.writeVarint(ss.DeleteTime)
+}
+
+func (d *binaryDecoder) readBulletSnapshot() *BulletSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	return &BulletSnapshot{
+		PlayerID:   d.readString(),
+		WeaponID:   d.readString(),
+		InitPos:    d.readVec(),
+		Dir:        d.readVec(),
+		Speed:      d.readFloat(),
+		MaxRange:   d.readFloat(),
+		Damage:     d.readFloat(),
+		Length:     d.readFloat(),
+		FireTime:   d.readVarint(),
+		DeleteTime: d.readVarint(),
+	}
+}
+
+func (e *binaryEncoder) writeWeaponSnapshot(ss *WeaponSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	// Guns share their fields
+	e.writeGunSnapshot(ss.M4)
+	e.writeGunSnapshot((*WeaponM4Snapshot)(ss.Shotgun))
+	e.writeGunSnapshot((*WeaponM4Snapshot)(ss.Sniper))
+	e.writeGunSnapshot((*WeaponM4Snapshot)(ss.Pistol))
+	e.writeGunSnapshot((*WeaponM4Snapshot)(ss.SMG))
+	if e.writePresent(ss.Knife != nil) {
+		e.writeString(ss.Knife.PlayerID)
+		e.writeVarint(ss.Knife.TriggerTime)
+	}
+}
+
+func (d *binaryDecoder) readWeaponSnapshot() *WeaponSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ss := &WeaponSnapshot{
+		M4:      d.readGunSnapshot(),
+		Shotgun: (*WeaponShotgunSnapshot)(d.readGunSnapshot()),
+		Sniper:  (*WeaponSniperSnapshot)(d.readGunSnapshot()),
+		Pistol:  (*WeaponPistolSnapshot)(d.readGunSnapshot()),
+		SMG:     (*WeaponSMGSnapshot)(d.readGunSnapshot()),
+	}
+	if d.readPresent() {
+		ss.Knife = &WeaponKnifeSnapshot{
+			PlayerID:    d.readString(),
+			TriggerTime: d.readVarint(),
+		}
+	}
+	return ss
+}
+
+func (e *binaryEncoder) writeGunSnapshot(ss *WeaponM4Snapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeString(ss.PlayerID)
+	e.writeInt(ss.Mag)
+	e.writeInt(ss.Ammo)
+	e.writeVarint(ss.TriggerTime)
+	e.writeVarint(ss.ReloadTime)
+}
+
+func (d *binaryDecoder) readGunSnapshot() *WeaponM4Snapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	return &WeaponM4Snapshot{
+		PlayerID:    d.readString(),
+		Mag:         d.readInt(),
+		Ammo:        d.readInt(),
+		TriggerTime: d.readVarint(),
+		ReloadTime:  d.readVarint(),
+	}
+}
+
+// Item
+
+func (e *binaryEncoder) writeItemSnapshot(ss *ItemSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	if e.writePresent(ss.Weapon != nil) {
+		e.writeVec(ss.Weapon.Pos)
+		e.writeString(ss.Weapon.WeaponID)
+	}
+	if e.writePresent(ss.Ammo != nil) {
+		e.writeVec(ss.Ammo.Pos)
+	}
+	if e.writePresent(ss.AmmoSM != nil) {
+		e.writeVec(ss.AmmoSM.Pos)
+	}
+	if e.writePresent(ss.Armor != nil) {
+		e.writeVec(ss.Armor.Pos)
+		e.writeFloat(ss.Armor.Armor)
+	}
+	e.writeItemSkullSnapshot(ss.Skull)
+	if e.writePresent(ss.LandMine != nil) {
+		e.writeBits(ss.LandMine.IsActive, ss.LandMine.IsExploded)
+		e.writeVec(ss.LandMine.Pos)
+		e.writeString(ss.LandMine.PlayerID)
+		e.writeInt(ss.LandMine.SlotIndex)
+	}
+}
+
+func (d *binaryDecoder) readItemSnapshot() *ItemSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ss := &ItemSnapshot{}
+	if d.readPresent() {
+		ss.Weapon = &ItemWeaponSnapshot{
+			Pos:      d.readVec(),
+			WeaponID: d.readString(),
+		}
+	}
+	if d.readPresent() {
+		ss.Ammo = &ItemAmmoSnapshot{Pos: d.readVec()}
+	}
+	if d.readPresent() {
+		ss.AmmoSM = &ItemAmmoSMSnapshot{Pos: d.readVec()}
+	}
+	if d.readPresent() {
+		ss.Armor = &ItemArmorSnapshot{
+			Pos:   d.readVec(),
+			Armor: d.readFloat(),
+		}
+	}
+	ss.Skull = d.readItemSkullSnapshot()
+	if d.readPresent() {
+		bits := d.readBits()
+		ss.LandMine = &ItemLandMineSnapshot{
+			IsActive:   bits&(1<<0) != 0,
+			IsExploded: bits&(1<<1) != 0,
+			Pos:        d.readVec(),
+			PlayerID:   d.readString(),
+			SlotIndex:  d.readInt(),
+		}
+	}
+	return ss
+}
+
+func (e *binaryEncoder) writeItemSkullSnapshot(ss *ItemSkullSnapshot) {
+	if !e.writePresent(ss != nil) {
+		return
+	}
+	e.writeVec(ss.Pos)
+	e.writeString(ss.PlayerID)
+	e.keys = e.keys[:0]
+	for playerID := range ss.RecordMap {
+		e.keys = append(e.keys, playerID)
+	}
+	sort.Strings(e.keys)
+	e.writeUvarint(uint64(len(e.keys)))
+	for _, playerID := range e.keys {
+		e.writeString(playerID)
+		record := ss.RecordMap[playerID]
+		if e.writePresent(record != nil) {
+			e.writeInt(record.RemainingMS)
+			e.writeVarint(record.PickupTime)
+			e.writeVarint(record.DropTime)
+		}
+	}
+}
+
+func (d *binaryDecoder) readItemSkullSnapshot() *ItemSkullSnapshot {
+	if !d.readPresent() {
+		return nil
+	}
+	ss := &ItemSkullSnapshot{
+		Pos:      d.readVec(),
+		PlayerID: d.readString(),
+	}
+	if n := d.readLength(); n > 0 {
+		ss.RecordMap = make(map[string]*ItemSkullRecord, n)
+		for i := 0; i < n; i++ {
+			playerID := d.readString()
+			var record *ItemSkullRecord
+			if d.readPresent() {
+				record = &ItemSkullRecord{
+					RemainingMS: d.readInt(),
+					PickupTime:  d.readVarint(),
+					DropTime:    d.readVarint(),
+				}
+			}
+			ss.RecordMap[playerID] = record
+		}
+	}
+	return ss
+}
@@ -5,6 +5,9 @@ package protocol
 type AddWorldSnapshotRequest struct {
 	Tick          int64          `json:"tick,omitempty"`
 	WorldSnapshot *WorldSnapshot `json:"world_snapshot,omitempty"`
+	// Events after the acked seq up to EventSeq, which the client acks next
+	Events   []*GameEvent `json:"events,omitempty"`
+	EventSeq int64        `json:"event_seq,omitempty"`
 }
 
 type AddWorldSnapshotResponse struct{}
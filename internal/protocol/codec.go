@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"encoding/json"
+)
+
+const (
+	CodecJSON   = 1
+	CodecBinary = 2
+)
+
+// Codec converts protocol objects to bytes on the wire. Every frame can be
+// decoded with the codec returned by DetectCodec, so peers only need to agree
+// on which codec they send.
+type Codec interface {
+	GetType() int
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	jsonCodecInstance   = &jsonCodec{}
+	binaryCodecInstance = newBinaryCodec()
+	// preferred codecs go first
+	supportedCodecs = []int{CodecBinary, CodecJSON}
+)
+
+func GetCodec(codecType int) Codec {
+	switch codecType {
+	case CodecBinary:
+		return binaryCodecInstance
+	default:
+		return jsonCodecInstance
+	}
+}
+
+func DetectCodec(data []byte) Codec {
+	if len(data) > 0 && data[0] == binaryCodecMagic {
+		return binaryCodecInstance
+	}
+	return jsonCodecInstance
+}
+
+func GetSupportedCodecs() []int {
+	return append([]int{}, supportedCodecs...)
+}
+
+// SelectCodec returns the most preferred codec which is offered by a peer,
+// peers which offer nothing get json.
+func SelectCodec(offered []int) int {
+	for _, codecType := range supportedCodecs {
+		for _, o := range offered {
+			if o == codecType {
+				return codecType
+			}
+		}
+	}
+	return CodecJSON
+}
+
+// JSON
+
+type jsonCodec struct{}
+
+func (c *jsonCodec) GetType() int {
+	return CodecJSON
+}
+
+func (c *jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,43 @@
+//go:build !race
+// +build !race
+
+package protocol
+
+import (
+	"testing"
+)
+
+// The binary codec is there to save server work, it must not allocate more
+// than json. Both marshal a small request into one allocation, the result. The race detector drops pooled encoders, so allocations are only
+// counted without it.
+func TestBinaryCodecAllocatesLessThanJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		v    *WrappedData
+	}{
+		{"AddWorldSnapshot", newTestWrappedData()},
+		{"SetPlayerInput", newTestSetPlayerInput()},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			allocs := make(map[int][2]float64)
+			for _, codecType := range []int{CodecJSON, CodecBinary} {
+				codec := GetCodec(codecType)
+				data, err := codec.Marshal(c.v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				allocs[codecType] = [2]float64{
+					testing.AllocsPerRun(100, func() { codec.Marshal(c.v) }),
+					testing.AllocsPerRun(100, func() { codec.Unmarshal(data, &WrappedData{}) }),
+				}
+			}
+			json, binary := allocs[CodecJSON], allocs[CodecBinary]
+			if binary[0] > json[0] || binary[1] >= json[1] {
+				t.Fatalf("binary allocates %v per marshal and unmarshal, json %v", binary, json)
+			}
+			t.Logf("binary allocates %v per marshal and unmarshal, json %v", binary, json)
+		})
+	}
+}
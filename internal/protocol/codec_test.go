@@ -0,0 +1,326 @@
+package protocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func newTestWorldSnapshot() *WorldSnapshot {
+	return &WorldSnapshot{
+		ID:   "world-1",
+		Type: 1,
+		KillFeedSnapshot: &KillFeedSnapshot{
+			Rows: []*KillFeedRow{
+				{CreateTime: 1589000000123456789, KillerPlayerID: "p1", VictimPlayerID: "p2", WeaponID: "w1"},
+			},
+		},
+		ObjectSnapshots: []*ObjectSnapshot{
+			{
+				ID:   "p1",
+				Type: 1,
+				Player: &PlayerSnapshot{
+					PlayerName:    "alice",
+					MeleeWeaponID: "k1",
+					WeaponID:      "w1",
+					ItemIDs:       []string{"i1", "i2"},
+					Kill:          3,
+					Death:         -1,
+					CursorDir:     &Vec{X: 0.123456789012345, Y: -0.987654321098765},
+					Pos:           &Vec{X: 1234.5678901234567, Y: 8765.4321098765432},
+					MoveDir:       &Vec{X: 1, Y: 0},
+					MoveSpeed:     312.00000000001,
+					HP:            99.99999999,
+					RespawnTime:   1589000000987654321,
+					IsVisible:     true,
+					InputSeq:      42,
+				},
+			},
+			{
+				ID:   "w1",
+				Type: 2,
+				Weapon: &WeaponSnapshot{
+					M4: &WeaponM4Snapshot{PlayerID: "p1", Mag: 30, Ammo: 90, TriggerTime: 1589000000000000001},
+				},
+			},
+			{
+				ID:   "b1",
+				Type: 3,
+				Bullet: &BulletSnapshot{
+					PlayerID: "p1",
+					WeaponID: "w1",
+					InitPos:  &Vec{X: 1234.5678901234567, Y: 8765.4321098765432},
+					Dir:      &Vec{X: 0.6, Y: 0.8},
+					Speed:    2400,
+					FireTime: 1589000000000000002,
+				},
+			},
+			{
+				ID:   "s1",
+				Type: 4,
+				Item: &ItemSnapshot{
+					Skull: &ItemSkullSnapshot{
+						Pos:      &Vec{X: 10.1, Y: 20.2},
+						PlayerID: "p1",
+						RecordMap: map[string]*ItemSkullRecord{
+							"p1": {RemainingMS: 1000, PickupTime: 1589000000000000003},
+							"p2": {RemainingMS: 2000, DropTime: 1589000000000000004},
+						},
+					},
+				},
+			},
+			{
+				ID:   "t1",
+				Type: 5,
+				Tree: &TreeSnapshot{Pos: &Vec{X: 64, Y: 128}, TreeType: "oak", Right: true},
+			},
+			{
+				ID:   "x1",
+				Type: 6,
+				Boundary: &BoundarySnapshot{
+					Collider: &Rect{Min: &Vec{X: -200, Y: -200}, Max: &Vec{X: 0, Y: 4096}},
+				},
+			},
+			{
+				ID:      "g1",
+				Type:    7,
+				Terrain: &TerrainSnapshot{Pos: &Vec{X: 32, Y: 32}, TerrainType: 2},
+			},
+			{
+				ID:   "bot1",
+				Type: 1,
+				Player: &PlayerSnapshot{
+					PlayerName: "bot",
+					Streak:     2,
+					MaxStreak:  5,
+					IsHidden:   true,
+					IsBot:      true,
+				},
+			},
+			{
+				ID:   "w2",
+				Type: 2,
+				Weapon: &WeaponSnapshot{
+					Shotgun: &WeaponShotgunSnapshot{PlayerID: "bot1", Mag: 2, ReloadTime: 1589000000000000005},
+					Sniper:  &WeaponSniperSnapshot{Ammo: 10},
+					Pistol:  &WeaponPistolSnapshot{Mag: 7},
+					SMG:     &WeaponSMGSnapshot{TriggerTime: 1},
+					Knife:   &WeaponKnifeSnapshot{PlayerID: "bot1", TriggerTime: 2},
+				},
+			},
+			{
+				ID:   "i1",
+				Type: 4,
+				Item: &ItemSnapshot{
+					Weapon:   &ItemWeaponSnapshot{Pos: &Vec{X: 1, Y: 2}, WeaponID: "w2"},
+					Ammo:     &ItemAmmoSnapshot{Pos: &Vec{X: 3, Y: 4}},
+					AmmoSM:   &ItemAmmoSMSnapshot{Pos: &Vec{X: 5, Y: 6}},
+					Armor:    &ItemArmorSnapshot{Pos: &Vec{X: 7, Y: 8}, Armor: 50},
+					LandMine: &ItemLandMineSnapshot{Pos: &Vec{X: 9, Y: 10}, PlayerID: "p1", SlotIndex: 1, IsExploded: true},
+				},
+			},
+		},
+		FieldWidth:    64,
+		FieldHeight:   64,
+		RespawnTimeMS: 5000,
+		InitTimeMS:    60000,
+		IsDelta:       true,
+		BaseTick:      100,
+		ObjectDeltas: []*ObjectDelta{
+			{ID: "p1", Masks: []uint64{1 << 63, 5}, Snapshot: &ObjectSnapshot{Player: &PlayerSnapshot{HP: 0.1}}},
+		},
+		RemovedIDs: []string{"b0"},
+	}
+}
+
+func newTestWrappedData() *WrappedData {
+	return &WrappedData{
+		Cmd: CmdAddWorldSnapshot,
+		AddWorldSnapshot: &AddWorldSnapshotRequest{
+			Tick:          1234,
+			WorldSnapshot: newTestWorldSnapshot(),
+			Events: []*GameEvent{
+				{Seq: 7, Tick: 1230, Type: GameEventFired, ObjectID: "w1", PlayerID: "p1", Pos: &Vec{X: 1.25, Y: 2.5}},
+				{Seq: 8, Tick: 1233, Type: GameEventDamaged, ObjectID: "p2", PlayerID: "p1", Value: 33.333333333333336},
+			},
+			EventSeq: 8,
+		},
+	}
+}
+
+func newTestSetPlayerInput() *WrappedData {
+	return &WrappedData{
+		Cmd: CmdSetPlayerInput,
+		SetPlayerInput: &SetPlayerInputRequest{
+			SessionToken: "token",
+			InputSnapshot: &InputSnapshot{
+				CursorDir:  &Vec{X: 0.6, Y: -0.8},
+				Fire:       true,
+				Right:      true,
+				Reload:     true,
+				Use3rdItem: true,
+				ViewTime:   1589000000000000006,
+				Duration:   16666667,
+				Time:       1589000000000000007,
+			},
+			AckTick:     1233,
+			AckEventSeq: 8,
+			Seq:         12,
+			PrevInputSnapshots: []*InputSnapshot{
+				{CursorDir: &Vec{X: 1}, Up: true, Drop: true},
+				{Left: true, Use1stItem: true},
+			},
+		},
+	}
+}
+
+// Both codecs must decode to the same value, so the binary codec can't lose
+// precision or fields which json keeps.
+func TestBinaryCodecRoundTrip(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+		newV func() interface{}
+	}{
+		{"WorldSnapshot", newTestWorldSnapshot(), func() interface{} { return &WorldSnapshot{} }},
+		{"WrappedData", newTestWrappedData(), func() interface{} { return &WrappedData{} }},
+		{"SetPlayerInput", newTestSetPlayerInput(), func() interface{} { return &WrappedData{} }},
+		{"SetPlayerInputRequest", newTestSetPlayerInput().SetPlayerInput, func() interface{} { return &SetPlayerInputRequest{} }},
+		{"AddWorldSnapshotRequest", newTestWrappedData().AddWorldSnapshot, func() interface{} { return &AddWorldSnapshotRequest{} }},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			fromJSON := c.newV()
+			data, err := GetCodec(CodecJSON).Marshal(c.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := DetectCodec(data).Unmarshal(data, fromJSON); err != nil {
+				t.Fatal(err)
+			}
+			fromBinary := c.newV()
+			data, err = GetCodec(CodecBinary).Marshal(c.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if DetectCodec(data).GetType() != CodecBinary {
+				t.Fatal("binary frame is not detected")
+			}
+			if err := DetectCodec(data).Unmarshal(data, fromBinary); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fromJSON, c.v) {
+				t.Fatal("json codec changed the value")
+			}
+			if !reflect.DeepEqual(fromBinary, fromJSON) {
+				t.Fatalf("binary codec doesn't match json\nbinary: %+v\njson:   %+v", fromBinary, fromJSON)
+			}
+		})
+	}
+}
+
+// The hand written encoders must write what the reflection walker writes,
+// so either can read the other.
+func TestBinaryCodecMatchesWalker(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+		newV func() interface{}
+	}{
+		{"WorldSnapshot", newTestWorldSnapshot(), func() interface{} { return &WorldSnapshot{} }},
+		{"WrappedData", newTestWrappedData(), func() interface{} { return &WrappedData{} }},
+		{"SetPlayerInput", newTestSetPlayerInput(), func() interface{} { return &WrappedData{} }},
+		{"InputSnapshot", newTestSetPlayerInput().SetPlayerInput.InputSnapshot, func() interface{} { return &InputSnapshot{} }},
+		{"empty WorldSnapshot", &WorldSnapshot{}, func() interface{} { return &WorldSnapshot{} }},
+	}
+	codec := GetCodec(CodecBinary).(*binaryCodec)
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			data, err := codec.Marshal(c.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			walker := newBinaryEncoder(codec)
+			walker.reflectOnly = true
+			walked, err := walker.marshal(c.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, walked) {
+				t.Fatalf("got %d bytes, the walker wrote %d\n%x\n%x", len(data), len(walked), data, walked)
+			}
+			fromKnown := c.newV()
+			if err := codec.Unmarshal(data, fromKnown); err != nil {
+				t.Fatal(err)
+			}
+			fromWalker := c.newV()
+			reader := newBinaryDecoder(codec)
+			reader.reflectOnly = true
+			if err := reader.unmarshal(data, fromWalker); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fromKnown, fromWalker) {
+				t.Fatalf("decoders differ\nknown:  %+v\nwalker: %+v", fromKnown, fromWalker)
+			}
+		})
+	}
+}
+
+func TestBinaryCodecShortData(t *testing.T) {
+	for _, v := range []*WrappedData{newTestWrappedData(), newTestSetPlayerInput()} {
+		data, err := GetCodec(CodecBinary).Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < len(data); n++ {
+			if err := GetCodec(CodecBinary).Unmarshal(data[:n], &WrappedData{}); err == nil {
+				t.Fatalf("no error for %d of %d bytes", n, len(data))
+			}
+		}
+	}
+}
+
+func benchmarkMarshal(b *testing.B, codec Codec) {
+	v := newTestWrappedData()
+	data, err := codec.Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, codec Codec) {
+	data, err := codec.Marshal(newTestWrappedData())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := codec.Unmarshal(data, &WrappedData{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// SetBytes reports the frame size, so MB/s and B/op compare the bandwidth
+// as well as the CPU of both codecs.
+func BenchmarkMarshal(b *testing.B) {
+	b.Run("JSON", func(b *testing.B) { benchmarkMarshal(b, GetCodec(CodecJSON)) })
+	b.Run("Binary", func(b *testing.B) { benchmarkMarshal(b, GetCodec(CodecBinary)) })
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	b.Run("JSON", func(b *testing.B) { benchmarkUnmarshal(b, GetCodec(CodecJSON)) })
+	b.Run("Binary", func(b *testing.B) { benchmarkUnmarshal(b, GetCodec(CodecBinary)) })
+}
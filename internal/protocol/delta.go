@@ -0,0 +1,266 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+var (
+	errDeltaBaseMismatch = errors.New("delta base mismatch")
+	errDeltaInvalidMasks = errors.New("delta masks are invalid")
+	deltaLeafTypes       = map[reflect.Type]bool{
+		reflect.TypeOf(&Vec{}):  true,
+		reflect.TypeOf(&Rect{}): true,
+	}
+)
+
+// DiffWorldSnapshot returns cur as a delta relative to base. Objects which
+// did not change are left out, changed objects only carry changed fields and
+// new objects are sent in full.
+func DiffWorldSnapshot(baseTick int64, base, cur *WorldSnapshot) *WorldSnapshot {
+	return diffWorldSnapshot(baseTick, base, cur, diffObjectSnapshot)
+}
+
+func diffWorldSnapshot(baseTick int64, base, cur *WorldSnapshot,
+	diffObject func(base, cur *ObjectSnapshot) *ObjectDelta) *WorldSnapshot {
+	delta := *cur
+	delta.IsDelta = true
+	delta.BaseTick = baseTick
+	delta.ObjectSnapshots = nil
+	baseMap := make(map[string]*ObjectSnapshot)
+	for _, ss := range base.ObjectSnapshots {
+		baseMap[ss.ID] = ss
+	}
+	for _, ss := range cur.ObjectSnapshots {
+		baseSS, exists := baseMap[ss.ID]
+		if !exists {
+			delta.ObjectSnapshots = append(delta.ObjectSnapshots, ss)
+			continue
+		}
+		delete(baseMap, ss.ID)
+		if objectDelta := diffObject(baseSS, ss); objectDelta != nil {
+			delta.ObjectDeltas = append(delta.ObjectDeltas, objectDelta)
+		}
+	}
+	for _, ss := range base.ObjectSnapshots {
+		if _, exists := baseMap[ss.ID]; exists {
+			delta.RemovedIDs = append(delta.RemovedIDs, ss.ID)
+		}
+	}
+	return &delta
+}
+
+// diffObjectSnapshot returns nil when the object did not change
+func diffObjectSnapshot(base, cur *ObjectSnapshot) *ObjectDelta {
+	if base == cur {
+		return nil
+	}
+	objectDelta := &ObjectDelta{ID: cur.ID}
+	d := diffStruct(reflect.ValueOf(base).Elem(), reflect.ValueOf(cur).Elem(), &objectDelta.Masks)
+	if objectDelta.Masks[0] == 0 {
+		return nil
+	}
+	objectDelta.Snapshot = d.Addr().Interface().(*ObjectSnapshot)
+	return objectDelta
+}
+
+// WorldSnapshotDiffer diffs the snapshots of one world for every client. The
+// snapshots filtered for each client share the objects of the world snapshot
+// they come from, so an object is diffed once per tick for all clients which
+// have the same baseline.
+type WorldSnapshotDiffer struct {
+	tick   int64
+	deltas map[[2]*ObjectSnapshot]*ObjectDelta
+	lock   sync.Mutex
+}
+
+func NewWorldSnapshotDiffer() *WorldSnapshotDiffer {
+	return &WorldSnapshotDiffer{
+		deltas: make(map[[2]*ObjectSnapshot]*ObjectDelta),
+	}
+}
+
+// Diff is DiffWorldSnapshot for the snapshot of tick, the deltas of earlier
+// ticks are forgotten.
+func (d *WorldSnapshotDiffer) Diff(tick, baseTick int64, base, cur *WorldSnapshot) *WorldSnapshot {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if tick != d.tick {
+		d.tick = tick
+		d.deltas = make(map[[2]*ObjectSnapshot]*ObjectDelta)
+	}
+	return diffWorldSnapshot(baseTick, base, cur, func(baseSS, ss *ObjectSnapshot) *ObjectDelta {
+		key := [2]*ObjectSnapshot{baseSS, ss}
+		objectDelta, exists := d.deltas[key]
+		if !exists {
+			objectDelta = diffObjectSnapshot(baseSS, ss)
+			d.deltas[key] = objectDelta
+		}
+		return objectDelta
+	})
+}
+
+// PatchWorldSnapshot rebuilds the full snapshot from a delta and its base,
+// base is not modified.
+func PatchWorldSnapshot(base, delta *WorldSnapshot) (*WorldSnapshot, error) {
+	if base.ID != delta.ID {
+		return nil, errDeltaBaseMismatch
+	}
+	full := *delta
+	full.IsDelta = false
+	full.BaseTick = 0
+	full.ObjectDeltas = nil
+	full.RemovedIDs = nil
+	full.ObjectSnapshots = nil
+	removedMap := make(map[string]bool)
+	for _, id := range delta.RemovedIDs {
+		removedMap[id] = true
+	}
+	deltaMap := make(map[string]*ObjectDelta)
+	for _, objectDelta := range delta.ObjectDeltas {
+		deltaMap[objectDelta.ID] = objectDelta
+	}
+	for _, ss := range base.ObjectSnapshots {
+		if removedMap[ss.ID] {
+			continue
+		}
+		if objectDelta, exists := deltaMap[ss.ID]; exists && objectDelta.Snapshot != nil {
+			pos := 0
+			v, err := patchStruct(
+				reflect.ValueOf(ss).Elem(),
+				reflect.ValueOf(objectDelta.Snapshot).Elem(),
+				objectDelta.Masks,
+				&pos,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if pos != len(objectDelta.Masks) {
+				return nil, errDeltaInvalidMasks
+			}
+			ss = v.Addr().Interface().(*ObjectSnapshot)
+			delete(deltaMap, ss.ID)
+		}
+		full.ObjectSnapshots = append(full.ObjectSnapshots, ss)
+	}
+	if len(deltaMap) > 0 {
+		return nil, errDeltaBaseMismatch
+	}
+	full.ObjectSnapshots = append(full.ObjectSnapshots, delta.ObjectSnapshots...)
+	return &full, nil
+}
+
+// diffStruct appends two masks per visited struct in pre-order, the first
+// one marks changed fields and the second one marks fields which hold a
+// nested delta instead of the new value.
+func diffStruct(base, cur reflect.Value, masks *[]uint64) reflect.Value {
+	t := cur.Type()
+	delta := reflect.New(t).Elem()
+	maskIndex := len(*masks)
+	*masks = append(*masks, 0, 0)
+	var changed, nested uint64
+	for i := 0; i < t.NumField(); i++ {
+		baseField, curField := base.Field(i), cur.Field(i)
+		if reflect.DeepEqual(baseField.Interface(), curField.Interface()) {
+			continue
+		}
+		changed |= 1 << uint(i)
+		if isNestedDelta(curField) && !baseField.IsNil() && !curField.IsNil() {
+			nested |= 1 << uint(i)
+			d := diffStruct(baseField.Elem(), curField.Elem(), masks)
+			delta.Field(i).Set(d.Addr())
+		} else {
+			delta.Field(i).Set(curField)
+		}
+	}
+	(*masks)[maskIndex] = changed
+	(*masks)[maskIndex+1] = nested
+	return delta
+}
+
+func patchStruct(base, delta reflect.Value, masks []uint64, pos *int) (reflect.Value, error) {
+	if *pos+2 > len(masks) {
+		return reflect.Value{}, errDeltaInvalidMasks
+	}
+	changed, nested := masks[*pos], masks[*pos+1]
+	*pos += 2
+	t := base.Type()
+	// Fields the struct doesn't have
+	if changed>>uint(t.NumField()) != 0 || nested&^changed != 0 {
+		return reflect.Value{}, errDeltaInvalidMasks
+	}
+	full := reflect.New(t).Elem()
+	full.Set(base)
+	for i := 0; i < t.NumField(); i++ {
+		if changed&(1<<uint(i)) == 0 {
+			continue
+		}
+		if nested&(1<<uint(i)) == 0 {
+			full.Field(i).Set(delta.Field(i))
+			continue
+		}
+		baseField, deltaField := base.Field(i), delta.Field(i)
+		if baseField.IsNil() || deltaField.IsNil() {
+			return reflect.Value{}, errDeltaInvalidMasks
+		}
+		v, err := patchStruct(baseField.Elem(), deltaField.Elem(), masks, pos)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		full.Field(i).Set(v.Addr())
+	}
+	return full, nil
+}
+
+func isNestedDelta(v reflect.Value) bool {
+	t := v.Type()
+	return t.Kind() == reflect.Ptr &&
+		t.Elem().Kind() == reflect.Struct &&
+		t.Elem().NumField() <= 64 &&
+		!deltaLeafTypes[t]
+}
+
+// WorldSnapshotHistory keeps the latest full snapshots by tick, they are
+// used as delta baselines.
+type WorldSnapshotHistory struct {
+	size      int
+	ticks     []int64
+	snapshots []*WorldSnapshot
+	lock      sync.RWMutex
+}
+
+func NewWorldSnapshotHistory(size int) *WorldSnapshotHistory {
+	return &WorldSnapshotHistory{
+		size: size,
+	}
+}
+
+func (h *WorldSnapshotHistory) Add(tick int64, snapshot *WorldSnapshot) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.ticks = append(h.ticks, tick)
+	h.snapshots = append(h.snapshots, snapshot)
+	if len(h.ticks) > h.size {
+		h.ticks = h.ticks[1:]
+		h.snapshots = h.snapshots[1:]
+	}
+}
+
+func (h *WorldSnapshotHistory) Get(tick int64) (*WorldSnapshot, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for i := len(h.ticks) - 1; i >= 0; i-- {
+		if h.ticks[i] == tick {
+			return h.snapshots[i], true
+		}
+	}
+	return nil, false
+}
+
+func (h *WorldSnapshotHistory) Reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.ticks = nil
+	h.snapshots = nil
+}
@@ -0,0 +1,316 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestDeltaBase is a snapshot with a player, a gun and a tree
+func newTestDeltaBase() *WorldSnapshot {
+	return &WorldSnapshot{
+		ID:          "world-1",
+		Type:        1,
+		FieldWidth:  64,
+		FieldHeight: 64,
+		ObjectSnapshots: []*ObjectSnapshot{
+			{
+				ID:   "p1",
+				Type: 1,
+				Player: &PlayerSnapshot{
+					PlayerName: "alice",
+					WeaponID:   "w1",
+					ItemIDs:    []string{"i1"},
+					Kill:       3,
+					Pos:        &Vec{X: 10, Y: 20},
+					HP:         50,
+					IsVisible:  true,
+				},
+			},
+			{
+				ID:     "w1",
+				Type:   2,
+				Weapon: &WeaponSnapshot{M4: &WeaponM4Snapshot{PlayerID: "p1", Mag: 30, Ammo: 90}},
+			},
+			{
+				ID:   "t1",
+				Type: 5,
+				Tree: &TreeSnapshot{Pos: &Vec{X: 64, Y: 128}, TreeType: "oak"},
+			},
+		},
+	}
+}
+
+// copyTestSnapshot deep copies a snapshot
+func copyTestSnapshot(ws *WorldSnapshot) *WorldSnapshot {
+	data, err := GetCodec(CodecJSON).Marshal(ws)
+	if err != nil {
+		panic(err)
+	}
+	cp := &WorldSnapshot{}
+	if err := GetCodec(CodecJSON).Unmarshal(data, cp); err != nil {
+		panic(err)
+	}
+	return cp
+}
+
+func TestDiffThenPatch(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(ws *WorldSnapshot)
+		// ids of objects sent as deltas, in full and removed
+		deltaIDs   []string
+		fullIDs    []string
+		removedIDs []string
+	}{
+		{
+			name:   "unchanged",
+			change: func(ws *WorldSnapshot) {},
+		},
+		{
+			name: "added object",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots = append(ws.ObjectSnapshots, &ObjectSnapshot{
+					ID:     "b1",
+					Type:   3,
+					Bullet: &BulletSnapshot{PlayerID: "p1", InitPos: &Vec{X: 1, Y: 2}, Dir: &Vec{X: 1}},
+				})
+			},
+			fullIDs: []string{"b1"},
+		},
+		{
+			name: "removed object",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots = ws.ObjectSnapshots[:2]
+			},
+			removedIDs: []string{"t1"},
+		},
+		{
+			name: "changed fields",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[0].Player.Kill = 4
+				ws.ObjectSnapshots[0].Player.Pos = &Vec{X: 11, Y: 20}
+				ws.ObjectSnapshots[0].Player.ItemIDs = []string{"i1", "i2"}
+			},
+			deltaIDs: []string{"p1"},
+		},
+		{
+			name: "zero values",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[0].Player.HP = 0
+				ws.ObjectSnapshots[0].Player.IsVisible = false
+				ws.ObjectSnapshots[0].Player.WeaponID = ""
+				ws.ObjectSnapshots[0].Player.ItemIDs = nil
+				ws.ObjectSnapshots[1].Weapon.M4.Mag = 0
+			},
+			deltaIDs: []string{"p1", "w1"},
+		},
+		{
+			name: "vec set to nil",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[0].Player.Pos = nil
+				ws.ObjectSnapshots[0].Player.CursorDir = &Vec{X: 1}
+			},
+			deltaIDs: []string{"p1"},
+		},
+		{
+			name: "weapon variant replaced",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[1].Weapon = &WeaponSnapshot{Shotgun: &WeaponShotgunSnapshot{Mag: 2}}
+			},
+			deltaIDs: []string{"w1"},
+		},
+		{
+			name: "player set to nil",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[0].Player = nil
+			},
+			deltaIDs: []string{"p1"},
+		},
+		{
+			name: "player set",
+			change: func(ws *WorldSnapshot) {
+				ws.ObjectSnapshots[2].Player = &PlayerSnapshot{PlayerName: "bob"}
+			},
+			deltaIDs: []string{"t1"},
+		},
+		{
+			name: "changed everywhere",
+			change: func(ws *WorldSnapshot) {
+				ws.FieldWidth = 0
+				ws.ObjectSnapshots[0].Player.Kill = 0
+				ws.ObjectSnapshots[1].Weapon.M4 = nil
+				ws.ObjectSnapshots = append(ws.ObjectSnapshots[:2], &ObjectSnapshot{ID: "t2", Type: 5})
+			},
+			deltaIDs:   []string{"p1", "w1"},
+			fullIDs:    []string{"t2"},
+			removedIDs: []string{"t1"},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			base := newTestDeltaBase()
+			cur := copyTestSnapshot(base)
+			c.change(cur)
+			baseCopy := copyTestSnapshot(base)
+			curCopy := copyTestSnapshot(cur)
+			delta := DiffWorldSnapshot(7, base, cur)
+			if !delta.IsDelta || delta.BaseTick != 7 {
+				t.Fatalf("delta isn't marked %+v", delta)
+			}
+			deltaIDs := []string{}
+			for _, objectDelta := range delta.ObjectDeltas {
+				deltaIDs = append(deltaIDs, objectDelta.ID)
+			}
+			fullIDs := []string{}
+			for _, ss := range delta.ObjectSnapshots {
+				fullIDs = append(fullIDs, ss.ID)
+			}
+			if !equalIDs(deltaIDs, c.deltaIDs) || !equalIDs(fullIDs, c.fullIDs) || !equalIDs(delta.RemovedIDs, c.removedIDs) {
+				t.Fatalf("got deltas %v, full %v, removed %v", deltaIDs, fullIDs, delta.RemovedIDs)
+			}
+			// The delta goes over the wire before it is patched
+			for _, codecType := range []int{CodecJSON, CodecBinary} {
+				data, err := GetCodec(codecType).Marshal(delta)
+				if err != nil {
+					t.Fatal(err)
+				}
+				received := &WorldSnapshot{}
+				if err := GetCodec(codecType).Unmarshal(data, received); err != nil {
+					t.Fatal(err)
+				}
+				full, err := PatchWorldSnapshot(base, received)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(copyTestSnapshot(full), curCopy) {
+					t.Fatalf("codec %d patched\n%+v\nwant\n%+v", codecType, full, cur)
+				}
+			}
+			if !reflect.DeepEqual(copyTestSnapshot(base), baseCopy) || !reflect.DeepEqual(copyTestSnapshot(cur), curCopy) {
+				t.Fatal("diff or patch changed their input")
+			}
+		})
+	}
+}
+
+func equalIDs(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+func TestPatchRejectsInvalidDelta(t *testing.T) {
+	base := newTestDeltaBase()
+	cur := copyTestSnapshot(base)
+	cur.ObjectSnapshots[0].Player.Pos = &Vec{X: 11, Y: 20}
+	cur.ObjectSnapshots[1].Weapon.M4.Mag = 29
+	cases := []struct {
+		name   string
+		change func(delta *WorldSnapshot)
+		err    error
+	}{
+		{"other world", func(delta *WorldSnapshot) { delta.ID = "world-2" }, errDeltaBaseMismatch},
+		{"unknown object", func(delta *WorldSnapshot) { delta.ObjectDeltas[0].ID = "p2" }, errDeltaBaseMismatch},
+		{"missing masks", func(delta *WorldSnapshot) {
+			delta.ObjectDeltas[0].Masks = delta.ObjectDeltas[0].Masks[:2]
+		}, errDeltaInvalidMasks},
+		{"extra masks", func(delta *WorldSnapshot) {
+			delta.ObjectDeltas[0].Masks = append(delta.ObjectDeltas[0].Masks, 0, 0)
+		}, errDeltaInvalidMasks},
+		{"field out of range", func(delta *WorldSnapshot) {
+			delta.ObjectDeltas[0].Masks[0] |= 1 << 63
+		}, errDeltaInvalidMasks},
+		{"nested but unchanged", func(delta *WorldSnapshot) {
+			delta.ObjectDeltas[0].Masks[1] |= 1 << 1
+		}, errDeltaInvalidMasks},
+		{"nested in nil base", func(delta *WorldSnapshot) {
+			// The tree has no player to patch
+			delta.ObjectDeltas[0].ID = "t1"
+			delta.ObjectDeltas[1].ID = "p1"
+		}, errDeltaInvalidMasks},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			delta := DiffWorldSnapshot(1, base, cur)
+			if len(delta.ObjectDeltas) != 2 {
+				t.Fatalf("got %d deltas", len(delta.ObjectDeltas))
+			}
+			c.change(delta)
+			if _, err := PatchWorldSnapshot(base, delta); err != c.err {
+				t.Fatalf("got %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+// Clients on the same baseline get the same object deltas, each pair of
+// objects is only diffed once per tick.
+func TestWorldSnapshotDifferSharesDeltas(t *testing.T) {
+	base := newTestDeltaBase()
+	cur := copyTestSnapshot(base)
+	cur.ObjectSnapshots[0].Player.Kill = 4
+	// Filtered snapshots share the objects of the world snapshot
+	baseA, baseB := *base, *base
+	curA, curB := *cur, *cur
+	differ := NewWorldSnapshotDiffer()
+	deltaA := differ.Diff(2, 1, &baseA, &curA)
+	deltaB := differ.Diff(2, 1, &baseB, &curB)
+	if !reflect.DeepEqual(deltaA, DiffWorldSnapshot(1, base, cur)) {
+		t.Fatalf("differ got %+v", deltaA)
+	}
+	if len(deltaA.ObjectDeltas) != 1 || deltaA.ObjectDeltas[0] != deltaB.ObjectDeltas[0] {
+		t.Fatal("clients on the same baseline didn't share the delta")
+	}
+	// A client which sees another version of the object gets its own
+	curC := *cur
+	curC.ObjectSnapshots = append([]*ObjectSnapshot{}, cur.ObjectSnapshots...)
+	hidden := *cur.ObjectSnapshots[0]
+	hidden.Player = &PlayerSnapshot{PlayerName: "alice", IsHidden: true}
+	curC.ObjectSnapshots[0] = &hidden
+	deltaC := differ.Diff(2, 1, &baseA, &curC)
+	if deltaC.ObjectDeltas[0] == deltaA.ObjectDeltas[0] || deltaC.ObjectDeltas[0].Snapshot.Player.IsHidden != true {
+		t.Fatalf("hidden object got the shared delta %+v", deltaC.ObjectDeltas[0].Snapshot.Player)
+	}
+	// Deltas of the last tick are forgotten
+	deltaD := differ.Diff(3, 1, &baseA, &curA)
+	if deltaD.ObjectDeltas[0] == deltaA.ObjectDeltas[0] {
+		t.Fatal("delta of an earlier tick was reused")
+	}
+}
+
+func TestWorldSnapshotHistory(t *testing.T) {
+	h := NewWorldSnapshotHistory(3)
+	snapshots := make(map[int64]*WorldSnapshot)
+	for tick := int64(1); tick <= 5; tick++ {
+		snapshots[tick] = &WorldSnapshot{ID: "world-1", BaseTick: tick}
+		h.Add(tick, snapshots[tick])
+	}
+	cases := []struct {
+		name   string
+		tick   int64
+		exists bool
+	}{
+		{"evicted", 1, false},
+		{"evicted last", 2, false},
+		{"oldest", 3, true},
+		{"latest", 5, true},
+		{"not yet", 6, false},
+		{"none", 0, false},
+	}
+	for _, c := range cases {
+		snapshot, exists := h.Get(c.tick)
+		if exists != c.exists {
+			t.Fatalf("%s: tick %d exists is %v", c.name, c.tick, exists)
+		}
+		if exists && snapshot != snapshots[c.tick] {
+			t.Fatalf("%s: tick %d got the snapshot of tick %d", c.name, c.tick, snapshot.BaseTick)
+		}
+		if !exists && snapshot != nil {
+			t.Fatalf("%s: tick %d got a snapshot", c.name, c.tick)
+		}
+	}
+	h.Reset()
+	if _, exists := h.Get(5); exists {
+		t.Fatal("reset kept snapshots")
+	}
+}
@@ -0,0 +1,26 @@
+package protocol
+
+// Disconnect requests carry a reason, the other side shows it or logs it.
+const (
+	DisconnectReasonNone = iota
+	DisconnectReasonQuit
+	DisconnectReasonKicked
+	DisconnectReasonServerShutdown
+	DisconnectReasonTimeOut
+	DisconnectReasonVersionMismatch
+)
+
+var disconnectMessages = map[int]string{
+	DisconnectReasonQuit:            "PLAYER LEFT THE GAME",
+	DisconnectReasonKicked:          "KICKED FROM THE SERVER",
+	DisconnectReasonServerShutdown:  "SERVER IS SHUTTING DOWN",
+	DisconnectReasonTimeOut:         "CONNECTION TIMED OUT",
+	DisconnectReasonVersionMismatch: "CLIENT/SERVER VERSION MISMATCH",
+}
+
+func GetDisconnectMessage(reason int) string {
+	if message, exists := disconnectMessages[reason]; exists {
+		return message
+	}
+	return "DISCONNECTED"
+}
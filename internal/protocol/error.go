@@ -0,0 +1,32 @@
+package protocol
+
+// Responses carry an error code, clients turn it into a message.
+const (
+	ErrorCodeNone = iota
+	ErrorCodeUnsupportedVersion
+	ErrorCodeHandshakeRequired
+	ErrorCodeInvalidPlayerName
+	ErrorCodeInvalidSession
+	ErrorCodeRoomNotFound
+	ErrorCodeInvalidRoomName
+	ErrorCodeRoomNameTaken
+	ErrorCodeTooManyRooms
+)
+
+var errorMessages = map[int]string{
+	ErrorCodeUnsupportedVersion: "CLIENT/SERVER VERSION MISMATCH",
+	ErrorCodeHandshakeRequired:  "HANDSHAKE IS REQUIRED",
+	ErrorCodeInvalidPlayerName:  "PLAYER NAME MUST BE NON-EMPTY AND SHORTER THAN 16 CHARACTERS",
+	ErrorCodeInvalidSession:     "SESSION IS INVALID",
+	ErrorCodeRoomNotFound:       "ROOM NOT FOUND",
+	ErrorCodeInvalidRoomName:    "ROOM NAME MUST BE NON-EMPTY AND SHORTER THAN 16 CHARACTERS",
+	ErrorCodeRoomNameTaken:      "ROOM NAME IS TAKEN",
+	ErrorCodeTooManyRooms:       "TOO MANY ROOMS",
+}
+
+func GetErrorMessage(code int) string {
+	if message, exists := errorMessages[code]; exists {
+		return message
+	}
+	return "UNKNOWN ERROR"
+}
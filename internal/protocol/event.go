@@ -0,0 +1,100 @@
+package protocol
+
+import "sync"
+
+// Game events are discrete things which happened in the world. They are sent
+// with every snapshot until the client acks them, so they arrive once and in
+// order even when snapshots are dropped.
+const (
+	GameEventFired    = 1
+	GameEventHit      = 2
+	GameEventDamaged  = 3
+	GameEventKilled   = 4
+	GameEventPickedUp = 5
+	GameEventExploded = 6
+	GameEventReloaded = 7
+)
+
+// GameEvent happened to ObjectID, like the weapon which fired or the player
+// who was damaged, PlayerID is the player who caused it.
+type GameEvent struct {
+	Seq      int64   `json:"seq,omitempty"`
+	Tick     int64   `json:"tick,omitempty"`
+	Type     int     `json:"type,omitempty"`
+	ObjectID string  `json:"object_id,omitempty"`
+	PlayerID string  `json:"player_id,omitempty"`
+	Pos      *Vec    `json:"pos,omitempty"`
+	Value    float64 `json:"value,omitempty"`
+}
+
+// GameEventLog numbers events and keeps the latest ones, a client which is
+// further behind than size misses the oldest.
+type GameEventLog struct {
+	size   int
+	seq    int64
+	events []*GameEvent
+	lock   sync.RWMutex
+}
+
+func NewGameEventLog(size int) *GameEventLog {
+	return &GameEventLog{
+		size: size,
+	}
+}
+
+// Add sets the seq of event, event must not be modified after.
+func (l *GameEventLog) Add(event *GameEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.seq++
+	event.Seq = l.seq
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = l.events[1:]
+	}
+}
+
+// GetAfter returns the events after seq and the seq of the last event. A seq
+// ahead of the log is from an older log, so every event is returned.
+func (l *GameEventLog) GetAfter(seq int64) (events []*GameEvent, lastSeq int64) {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if seq > l.seq {
+		seq = 0
+	}
+	i := len(l.events)
+	for i > 0 && l.events[i-1].Seq > seq {
+		i--
+	}
+	return l.events[i:], l.seq
+}
+
+// FilterGameEvents keeps the events of objects or players in snapshot, the
+// rest happened where the player can't see. Events of hidden players lose
+// their pos and who caused them.
+func FilterGameEvents(events []*GameEvent, snapshot *WorldSnapshot) []*GameEvent {
+	existsMap := make(map[string]bool)
+	hiddenMap := make(map[string]bool)
+	for _, ss := range snapshot.ObjectSnapshots {
+		existsMap[ss.ID] = true
+		if ss.Player != nil && ss.Player.IsHidden {
+			hiddenMap[ss.ID] = true
+		}
+	}
+	filtered := []*GameEvent{}
+	for _, event := range events {
+		if hiddenMap[event.ObjectID] || hiddenMap[event.PlayerID] {
+			// Events are shared by every client
+			e := *event
+			e.Pos = nil
+			if hiddenMap[e.PlayerID] {
+				e.PlayerID = ""
+			}
+			event = &e
+		}
+		if existsMap[event.ObjectID] || existsMap[event.PlayerID] {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
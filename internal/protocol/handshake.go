@@ -0,0 +1,57 @@
+package protocol
+
+// Protocol versions, the server speaks every version from MinProtocolVersion
+// to ProtocolVersion. Bump ProtocolVersion when the wire format changes and
+// only raise MinProtocolVersion when old clients can't be served anymore.
+const (
+	// ProtocolVersionLegacy clients register without a handshake and are
+	// only served json, the binary layout of their structs is gone. They know
+	// RegisterPlayer, SetPlayerInput and AddWorldSnapshot.
+	ProtocolVersionLegacy = 1
+	// ProtocolVersionSessions adds the handshake, sessions, resuming, time
+	// sync, rooms, game events and float64 in the binary codec.
+	ProtocolVersionSessions = 2
+
+	ProtocolVersion    = ProtocolVersionSessions
+	MinProtocolVersion = ProtocolVersionLegacy
+)
+
+// cmdVersions are the versions which added commands, commands which are not
+// listed exist since the first version.
+var cmdVersions = map[int]int{
+	CmdResumeSession: ProtocolVersionSessions,
+	CmdDisconnect:    ProtocolVersionSessions,
+	CmdTimeSync:      ProtocolVersionSessions,
+	CmdListRooms:     ProtocolVersionSessions,
+	CmdCreateRoom:    ProtocolVersionSessions,
+}
+
+// IsCmdSupported tells whether a peer of a version knows a command
+func IsCmdSupported(cmd, version int) bool {
+	return version >= cmdVersions[cmd]
+}
+
+// Capabilities are optional features, a feature is only used when both
+// peers offer it.
+const (
+	CapabilityDeltaSnapshot uint64 = 1 << iota
+	CapabilityExtraItemSlots
+)
+
+var supportedCapabilities = CapabilityDeltaSnapshot | CapabilityExtraItemSlots
+
+func GetSupportedCapabilities() uint64 {
+	return supportedCapabilities
+}
+
+// SelectVersion returns the newest version in both ranges.
+func SelectVersion(minVersion, maxVersion int) (version int, ok bool) {
+	version = ProtocolVersion
+	if maxVersion < version {
+		version = maxVersion
+	}
+	if version < minVersion || version < MinProtocolVersion {
+		return 0, false
+	}
+	return version, true
+}
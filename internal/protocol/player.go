@@ -25,4 +25,9 @@ type PlayerSnapshot struct {
 	TriggerVisibleMS int      `json:"trigger_visible_ms,omitempty"`
 	IsInvulnerable   bool     `json:"is_invulnerable,omitempty"`
 	IsVisible        bool     `json:"is_visible,omitempty"`
+	// Hidden from the receiver, position is not real
+	IsHidden bool `json:"is_hidden,omitempty"`
+	// Last input seq applied to Pos
+	InputSeq int64 `json:"input_seq,omitempty"`
+	IsBot    bool  `json:"is_bot,omitempty"`
 }
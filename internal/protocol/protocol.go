@@ -8,8 +8,15 @@ const (
 	// Server APIs
 	CmdRegisterPlayer = 1
 	CmdSetPlayerInput = 2
+	CmdHandshake      = 3
+	CmdResumeSession  = 4
+	CmdTimeSync       = 6
+	CmdListRooms      = 7
+	CmdCreateRoom     = 8
 	// Client APIs
 	CmdAddWorldSnapshot = 1
+	// Both ways
+	CmdDisconnect = 5
 )
 
 // Wrapper Objects
@@ -24,8 +31,15 @@ type WrappedData struct {
 	// Server APIs
 	RegisterPlayer *RegisterPlayerRequest `json:"register_player,omitempty"`
 	SetPlayerInput *SetPlayerInputRequest `json:"set_player_input,omitempty"`
+	Handshake      *HandshakeRequest      `json:"handshake,omitempty"`
+	ResumeSession  *ResumeSessionRequest  `json:"resume_session,omitempty"`
+	TimeSync       *TimeSyncRequest       `json:"time_sync,omitempty"`
+	ListRooms      *ListRoomsRequest      `json:"list_rooms,omitempty"`
+	CreateRoom     *CreateRoomRequest     `json:"create_room,omitempty"`
 	// Client APIs
 	AddWorldSnapshot *AddWorldSnapshotRequest `json:"add_world_snapshot,omitempty"`
+	// Both ways
+	Disconnect *DisconnectRequest `json:"disconnect,omitempty"`
 }
 
 // Common Objects
@@ -53,11 +67,18 @@ func (r *Rect) Convert() pixel.Rect {
 
 type WorldSnapshot struct {
 	ID               string            `json:"id,omitempty"`
-	Type             int               `json:"type,omitempty`
+	Type             int               `json:"type,omitempty"`
 	KillFeedSnapshot *KillFeedSnapshot `json:"kill_feed_snapshot,omitempty"`
 	ObjectSnapshots  []*ObjectSnapshot `json:"object_snapshots,omitempty"`
 	FieldWidth       int               `json:"field_width,omitempty"`
 	FieldHeight      int               `json:"field_height,omitempty"`
+	RespawnTimeMS    int               `json:"respawn_time_ms,omitempty"`
+	InitTimeMS       int               `json:"init_time_ms,omitempty"`
+	// Delta
+	IsDelta      bool           `json:"is_delta,omitempty"`
+	BaseTick     int64          `json:"base_tick,omitempty"`
+	ObjectDeltas []*ObjectDelta `json:"object_deltas,omitempty"`
+	RemovedIDs   []string       `json:"removed_ids,omitempty"`
 }
 
 type InputSnapshot struct {
@@ -74,6 +95,12 @@ type InputSnapshot struct {
 	Use1stItem bool `json:"use_1st_item,omitempty"`
 	Use2ndItem bool `json:"use_2nd_item,omitempty"`
 	Use3rdItem bool `json:"use_3rd_item,omitempty"`
+	// Server time which the client was rendering
+	ViewTime int64 `json:"view_time,omitempty"`
+	// Nanoseconds the input was held before it was sampled
+	Duration int64 `json:"duration,omitempty"`
+	// Server time the input was sampled at
+	Time int64 `json:"time,omitempty"`
 }
 
 type KillFeedSnapshot struct {
@@ -98,3 +125,9 @@ type ObjectSnapshot struct {
 	Terrain  *TerrainSnapshot  `json:"terrain,omitempty"`
 	Boundary *BoundarySnapshot `json:"boundary,omitempty"`
 }
+
+type ObjectDelta struct {
+	ID       string          `json:"id,omitempty"`
+	Masks    []uint64        `json:"masks,omitempty"`
+	Snapshot *ObjectSnapshot `json:"snapshot,omitempty"`
+}
@@ -1,29 +1,135 @@
 package protocol
 
+// Handshake
+
+type HandshakeRequest struct {
+	MinVersion   int    `json:"min_version,omitempty"`
+	MaxVersion   int    `json:"max_version,omitempty"`
+	GameVersion  string `json:"game_version,omitempty"`
+	Codecs       []int  `json:"codecs,omitempty"`
+	Capabilities uint64 `json:"capabilities,omitempty"`
+}
+
+type HandshakeResponse struct {
+	ErrorCode int `json:"error_code,omitempty"`
+	// Versions which the server speaks
+	MinVersion int `json:"min_version,omitempty"`
+	MaxVersion int `json:"max_version,omitempty"`
+	// Selected
+	Version      int    `json:"version,omitempty"`
+	Codec        int    `json:"codec,omitempty"`
+	Capabilities uint64 `json:"capabilities,omitempty"`
+}
+
 // RegisterPlayer
 
 type RegisterPlayerRequest struct {
 	PlayerName string `json:"player_name,omitempty"`
-	Version    string `json:"version,omitempty"`
+	// Room to join by id or else by name, the default room when both are empty
+	RoomID   string `json:"room_id,omitempty"`
+	RoomName string `json:"room_name,omitempty"`
+	// Legacy clients send these instead of handshaking
+	Version string `json:"version,omitempty"`
+	Codecs  []int  `json:"codecs,omitempty"`
 }
 
 type RegisterPlayerResponse struct {
-	// Debug
+	ErrorCode int `json:"error_code,omitempty"`
+	// Info
+	PlayerID string `json:"player_id,omitempty"`
+	// SessionToken is a secret which authenticates later requests, it is
+	// only valid from the connection which registered
+	SessionToken  string         `json:"session_token,omitempty"`
+	ServerTime    int64          `json:"server_time,omitempty"`
+	StartTime     int64          `json:"start_time,omitempty"`
+	Tick          int64          `json:"tick,omitempty"`
+	WorldSnapshot *WorldSnapshot `json:"world_snapshot,omitempty"`
+	Room          *RoomInfo      `json:"room,omitempty"`
+	// PlayerTimeOut is how long, in nanoseconds, the player is kept without
+	// input, a client can resume its session until then
+	PlayerTimeOut int64 `json:"player_time_out,omitempty"`
+	// Legacy clients check OK and DebugMessage instead of ErrorCode
 	OK           bool   `json:"ok,omitempty"`
 	DebugMessage string `json:"debug_message,omitempty"`
+	Codec        int    `json:"codec,omitempty"`
+}
+
+// ResumeSession
+
+type ResumeSessionRequest struct {
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+type ResumeSessionResponse struct {
+	ErrorCode int `json:"error_code,omitempty"`
 	// Info
 	PlayerID      string         `json:"player_id,omitempty"`
 	ServerTime    int64          `json:"server_time,omitempty"`
 	StartTime     int64          `json:"start_time,omitempty"`
 	Tick          int64          `json:"tick,omitempty"`
 	WorldSnapshot *WorldSnapshot `json:"world_snapshot,omitempty"`
+	PlayerTimeOut int64          `json:"player_time_out,omitempty"`
 }
 
 // SetPlayerInput
 
 type SetPlayerInputRequest struct {
-	PlayerID      string         `json:"player_id,omitempty"`
+	SessionToken  string         `json:"session_token,omitempty"`
 	InputSnapshot *InputSnapshot `json:"input_snapshot,omitempty"`
+	AckTick       int64          `json:"ack_tick,omitempty"`
+	AckEventSeq   int64          `json:"ack_event_seq,omitempty"`
+	// Seq of InputSnapshot, prev inputs are resent in case they were lost
+	// and take the seqs right before it
+	Seq                int64            `json:"seq,omitempty"`
+	PrevInputSnapshots []*InputSnapshot `json:"prev_input_snapshots,omitempty"`
+}
+
+type SetPlayerInputResponse struct {
+	ErrorCode int `json:"error_code,omitempty"`
 }
 
-type SetPlayerInputResponse struct{}
+// TimeSync, times are unix nanoseconds
+
+type TimeSyncRequest struct {
+	ClientSendTime int64 `json:"client_send_time,omitempty"`
+}
+
+type TimeSyncResponse struct {
+	ClientSendTime    int64 `json:"client_send_time,omitempty"`
+	ServerReceiveTime int64 `json:"server_receive_time,omitempty"`
+	ServerSendTime    int64 `json:"server_send_time,omitempty"`
+}
+
+// Disconnect, it is also pushed by the server before it drops a client
+
+type DisconnectRequest struct {
+	Reason int `json:"reason,omitempty"`
+}
+
+type DisconnectResponse struct {
+	ErrorCode int `json:"error_code,omitempty"`
+}
+
+// Rooms
+
+type RoomInfo struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	PlayerCount int    `json:"player_count,omitempty"`
+}
+
+type ListRoomsRequest struct{}
+
+type ListRoomsResponse struct {
+	ErrorCode int         `json:"error_code,omitempty"`
+	Rooms     []*RoomInfo `json:"rooms,omitempty"`
+}
+
+type CreateRoomRequest struct {
+	Name string `json:"name,omitempty"`
+}
+
+type CreateRoomResponse struct {
+	ErrorCode int       `json:"error_code,omitempty"`
+	Room      *RoomInfo `json:"room,omitempty"`
+}
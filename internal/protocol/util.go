@@ -12,10 +12,10 @@ type TickSnapshot struct {
 	Snapshot *ObjectSnapshot
 }
 
-func GetSnapshotByTime(t time.Time, tickSnapshots []*TickSnapshot) (
+func GetSnapshotByTime(clock *ticktime.Clock, t time.Time, tickSnapshots []*TickSnapshot) (
 	ssA, ssB *ObjectSnapshot, d float64) {
 	var tickA, tickB int64
-	tick := ticktime.GetTick(t)
+	tick := clock.GetTick(t)
 	if len(tickSnapshots) == 0 {
 		return nil, nil, 0
 	} else if ts := tickSnapshots[len(tickSnapshots)-1]; ts.Tick <= tick {
@@ -40,7 +40,7 @@ func GetSnapshotByTime(t time.Time, tickSnapshots []*TickSnapshot) (
 		return nil, nil, 0
 	}
 	if tickA != tickB {
-		x := float64(t.Sub(ticktime.GetTickTime(tickA)).Nanoseconds())
+		x := float64(t.Sub(clock.GetTickTime(tickA)).Nanoseconds())
 		y := float64((tickB - tickA) * config.Timestep.Nanoseconds())
 		d = x / y
 	}
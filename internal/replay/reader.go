@@ -0,0 +1,144 @@
+package replay
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// Reader holds every frame of a replay file in memory and walks them in
+// order, delta frames are patched into full snapshots on the way.
+type Reader struct {
+	header    *Header
+	frames    []*Frame
+	keyframes []int
+	index     int
+	currSS    *protocol.WorldSnapshot
+}
+
+func Open(fileName string) (*Reader, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	buf := bufio.NewReader(file)
+	prefix := make([]byte, len(fileMagic)+2)
+	if _, err := io.ReadFull(buf, prefix); err != nil || string(prefix[:len(fileMagic)]) != fileMagic {
+		return nil, errInvalidFile
+	}
+	if binary.BigEndian.Uint16(prefix[len(fileMagic):]) != FileVersion {
+		return nil, errUnsupportedVersion
+	}
+	zr, err := zlib.NewReader(buf)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	r := &Reader{header: &Header{}}
+	records := bufio.NewReader(zr)
+	codec := protocol.GetCodec(protocol.CodecBinary)
+	if err := readRecord(records, codec, r.header); err != nil {
+		return nil, errInvalidFile
+	}
+	for {
+		frame := &Frame{}
+		// A file of a server which was stopped ends without a complete
+		// record, everything before it is still usable.
+		if err := readRecord(records, codec, frame); err != nil {
+			break
+		}
+		if frame.WorldSnapshot == nil {
+			return nil, errInvalidFile
+		}
+		if !frame.WorldSnapshot.IsDelta {
+			r.keyframes = append(r.keyframes, len(r.frames))
+		}
+		r.frames = append(r.frames, frame)
+	}
+	if len(r.keyframes) == 0 {
+		return nil, errNoFrames
+	}
+	// Deltas before the first keyframe can't be patched
+	first := r.keyframes[0]
+	r.frames = r.frames[first:]
+	for i := range r.keyframes {
+		r.keyframes[i] -= first
+	}
+	return r, nil
+}
+
+func readRecord(rd *bufio.Reader, codec protocol.Codec, v interface{}) error {
+	size, err := binary.ReadUvarint(rd)
+	if err != nil {
+		return err
+	}
+	if size == 0 || size > maxRecordSize {
+		return errInvalidFile
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rd, data); err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
+}
+
+func (r *Reader) GetHeader() *Header {
+	return r.header
+}
+
+func (r *Reader) GetFirstTick() int64 {
+	return r.frames[0].Tick
+}
+
+func (r *Reader) GetLastTick() int64 {
+	return r.frames[len(r.frames)-1].Tick
+}
+
+// SeekTick moves to the last keyframe at or before tick, frames after it have to
+// be read with Next until the wanted tick is reached.
+func (r *Reader) SeekTick(tick int64) {
+	i := sort.Search(len(r.keyframes), func(i int) bool {
+		return r.frames[r.keyframes[i]].Tick > tick
+	})
+	if i > 0 {
+		i--
+	}
+	r.index = r.keyframes[i]
+	r.currSS = nil
+}
+
+// PeekTick returns the tick of the frame which Next will return.
+func (r *Reader) PeekTick() (tick int64, exists bool) {
+	if r.index >= len(r.frames) {
+		return 0, false
+	}
+	return r.frames[r.index].Tick, true
+}
+
+// Next returns the next frame with a full snapshot, io.EOF is returned after
+// the last frame.
+func (r *Reader) Next() (*Frame, error) {
+	if r.index >= len(r.frames) {
+		return nil, io.EOF
+	}
+	frame := r.frames[r.index]
+	r.index++
+	ss := frame.WorldSnapshot
+	if ss.IsDelta {
+		if r.currSS == nil {
+			return nil, errInvalidFile
+		}
+		var err error
+		if ss, err = protocol.PatchWorldSnapshot(r.currSS, ss); err != nil {
+			return nil, err
+		}
+	}
+	r.currSS = ss
+	return &Frame{Tick: frame.Tick, WorldSnapshot: ss, Events: frame.Events}, nil
+}
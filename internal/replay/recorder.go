@@ -0,0 +1,116 @@
+package replay
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// Recorder appends world snapshots to a replay file.
+type Recorder struct {
+	file       *os.File
+	buf        *bufio.Writer
+	zw         *zlib.Writer
+	codec      protocol.Codec
+	frameCount int
+	prevTick   int64
+	prevSS     *protocol.WorldSnapshot
+	lock       sync.Mutex
+}
+
+func NewRecorder(fileName, worldID string, worldType int, startTime time.Time) (*Recorder, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		file:  file,
+		buf:   bufio.NewWriter(file),
+		codec: protocol.GetCodec(protocol.CodecBinary),
+	}
+	prefix := make([]byte, len(fileMagic)+2)
+	copy(prefix, fileMagic)
+	binary.BigEndian.PutUint16(prefix[len(fileMagic):], FileVersion)
+	if _, err := r.buf.Write(prefix); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	r.zw = zlib.NewWriter(r.buf)
+	header := &Header{
+		Version:     FileVersion,
+		GameVersion: config.Version,
+		WorldID:     worldID,
+		WorldType:   worldType,
+		StartTime:   startTime.UnixNano(),
+		CreateTime:  time.Now().UnixNano(),
+	}
+	if err := r.writeRecord(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record writes a full world snapshot with the game events since the last
+// record, snapshot must not be modified after.
+func (r *Recorder) Record(tick int64, snapshot *protocol.WorldSnapshot, events []*protocol.GameEvent) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	frame := &Frame{Tick: tick, WorldSnapshot: snapshot, Events: events}
+	isKeyframe := r.prevSS == nil || r.frameCount%keyframePeriod == 0
+	if !isKeyframe {
+		frame.WorldSnapshot = protocol.DiffWorldSnapshot(r.prevTick, r.prevSS, snapshot)
+	}
+	if err := r.writeRecord(frame); err != nil {
+		return err
+	}
+	r.frameCount++
+	r.prevTick = tick
+	r.prevSS = snapshot
+	// Keyframes are flushed, so a crashed server still leaves a playable file
+	if isKeyframe {
+		return r.flush()
+	}
+	return nil
+}
+
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.zw.Close(); err != nil {
+		_ = r.file.Close()
+		return err
+	}
+	if err := r.buf.Flush(); err != nil {
+		_ = r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func (r *Recorder) writeRecord(v interface{}) error {
+	data, err := r.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+	if _, err := r.zw.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err = r.zw.Write(data)
+	return err
+}
+
+func (r *Recorder) flush() error {
+	if err := r.zw.Flush(); err != nil {
+		return err
+	}
+	return r.buf.Flush()
+}
@@ -0,0 +1,46 @@
+package replay
+
+import (
+	"errors"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// A replay file starts with fileMagic and a big endian uint16 format version,
+// the rest is a zlib stream of records. Each record is a uvarint length and a
+// binary codec frame, the first record is the Header and every other record
+// is a Frame. The binary codec is positional, so FileVersion is bumped when
+// the codec or any struct in a record changes, files of other versions are
+// rejected.
+const (
+	FileVersion = 3
+	FileExt     = ".replay"
+	fileMagic   = "SRPL"
+	// a full snapshot is written every keyframePeriod frames, other frames
+	// are deltas against the frame before
+	keyframePeriod = 5 * config.ServerSyncRate
+	maxRecordSize  = 16 << 20
+)
+
+var (
+	errInvalidFile        = errors.New("replay: invalid file")
+	errUnsupportedVersion = errors.New("replay: unsupported file version")
+	errNoFrames           = errors.New("replay: file has no frames")
+)
+
+type Header struct {
+	Version     int    `json:"version,omitempty"`
+	GameVersion string `json:"game_version,omitempty"`
+	WorldID     string `json:"world_id,omitempty"`
+	WorldType   int    `json:"world_type,omitempty"`
+	StartTime   int64  `json:"start_time,omitempty"`
+	CreateTime  int64  `json:"create_time,omitempty"`
+}
+
+type Frame struct {
+	Tick          int64                   `json:"tick,omitempty"`
+	WorldSnapshot *protocol.WorldSnapshot `json:"world_snapshot,omitempty"`
+	// game events since the frame before
+	Events []*protocol.GameEvent `json:"events,omitempty"`
+}
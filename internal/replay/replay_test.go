@@ -0,0 +1,160 @@
+package replay
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// fileLayouts has the layout of the records of each FileVersion, a change
+// which isn't listed here needs a new FileVersion. Codec changes don't show
+// up here, version 3 is the first with float64 floats.
+var fileLayouts = map[int]string{
+	3: "d2ea4803b9ebe7909140d48f78eb85614b709af3e3a11ccf93c97e2b521d125b",
+}
+
+// getLayout describes the fields of t in the order the binary codec writes them
+func getLayout(t reflect.Type, b *strings.Builder) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		b.WriteString("*")
+		getLayout(t.Elem(), b)
+	case reflect.Slice:
+		b.WriteString("[]")
+		getLayout(t.Elem(), b)
+	case reflect.Map:
+		b.WriteString("map[")
+		getLayout(t.Key(), b)
+		b.WriteString("]")
+		getLayout(t.Elem(), b)
+	case reflect.Struct:
+		b.WriteString("{")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || strings.HasPrefix(f.Tag.Get("json"), "-") {
+				continue
+			}
+			b.WriteString(f.Name + " ")
+			getLayout(f.Type, b)
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
+
+func TestFileVersionMatchesLayout(t *testing.T) {
+	b := &strings.Builder{}
+	getLayout(reflect.TypeOf(Header{}), b)
+	getLayout(reflect.TypeOf(Frame{}), b)
+	layout := fmt.Sprintf("%x", sha256.Sum256([]byte(b.String())))
+	if fileLayouts[FileVersion] != layout {
+		t.Fatalf("replay records changed, bump FileVersion and add its layout %s", layout)
+	}
+}
+
+func newTestFrames() []*Frame {
+	frames := []*Frame{}
+	for tick := int64(1); tick <= 2*keyframePeriod; tick++ {
+		frames = append(frames, &Frame{
+			Tick: tick,
+			WorldSnapshot: &protocol.WorldSnapshot{
+				ID:   "world-1",
+				Type: 1,
+				ObjectSnapshots: []*protocol.ObjectSnapshot{
+					{
+						ID:   "p1",
+						Type: 1,
+						Player: &protocol.PlayerSnapshot{
+							Pos: &protocol.Vec{X: 1234.5678901234567 + float64(tick), Y: -0.1},
+							HP:  100,
+						},
+					},
+				},
+			},
+			Events: []*protocol.GameEvent{
+				{Seq: tick, Tick: tick, Type: protocol.GameEventFired, ObjectID: "w1", PlayerID: "p1"},
+			},
+		})
+	}
+	return frames
+}
+
+func recordTestFile(t *testing.T, fileName string, frames []*Frame) {
+	r, err := NewRecorder(fileName, "world-1", 1, time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		if err := r.Record(frame.Tick, frame.WorldSnapshot, frame.Events); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "world-1"+FileExt)
+	frames := newTestFrames()
+	recordTestFile(t, fileName, frames)
+	r, err := Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetHeader().Version != FileVersion || r.GetHeader().WorldID != "world-1" {
+		t.Fatalf("got header %+v", r.GetHeader())
+	}
+	for _, want := range frames {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("tick %d: %v", want.Tick, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("tick %d: got %+v, want %+v", want.Tick, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v after the last frame", err)
+	}
+}
+
+func TestOpenRejectsOtherVersions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "world-1"+FileExt)
+	recordTestFile(t, fileName, newTestFrames())
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []uint16{FileVersion - 1, FileVersion + 1} {
+		binary.BigEndian.PutUint16(data[len(fileMagic):], version)
+		if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(fileName); err != errUnsupportedVersion {
+			t.Fatalf("version %d: got %v", version, err)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadCommonKillSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	commonKillBuffer = beep.NewBuffer(format)
 	commonKillBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadCommonPickupSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	commonPickupBuffer = beep.NewBuffer(format)
 	commonPickupBuffer.Append(resampled)
 	return nil
@@ -59,7 +58,7 @@ func loadCommonPickupSound(assetPath string) (err error) {
 
 func PlayCommonKill() {
 	streamer := commonKillBuffer.Streamer(0, commonKillBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -69,7 +68,7 @@ func PlayCommonKill() {
 
 func PlayCommonPickup() {
 	streamer := commonPickupBuffer.Streamer(0, commonPickupBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -31,7 +30,7 @@ func loadItemExplosionSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	itemExplosionBuffer = beep.NewBuffer(format)
 	itemExplosionBuffer.Append(resampled)
 	return nil
@@ -40,7 +39,7 @@ func loadItemExplosionSound(assetPath string) (err error) {
 func PlayItemExplosion(dist float64) {
 	k := 1.0 / 500.0
 	streamer := itemExplosionBuffer.Streamer(0, itemExplosionBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
 	"github.com/faiface/beep"
-	"github.com/faiface/beep/speaker"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 )
 
@@ -17,19 +15,23 @@ const (
 	volumeDiff = 0.5
 )
 
+// SampleRate is the rate which sounds are resampled to, the output plays
+// at this rate.
+const SampleRate = beep.SampleRate(44100)
+
 var (
-	sampleRate = beep.SampleRate(44100)
-	mute       = false
-	volume     = -2.0
+	mute   = false
+	volume = -2.0
+	// output plays sounds, the server has none so the package builds and
+	// runs without an audio device
+	output func(s ...beep.Streamer)
 )
 
 type loadSoundFunc func(assetPath string) error
 
-func LoadAllSounds() (err error) {
-	err = speaker.Init(sampleRate, sampleRate.N(time.Second/20))
-	if err != nil {
-		return err
-	}
+// LoadAllSounds decodes the sound assets, they are played through output.
+func LoadAllSounds(playFunc func(s ...beep.Streamer)) (err error) {
+	output = playFunc
 	path := "./"
 	if !config.EnvGorun() {
 		if path, err = filepath.Abs(filepath.Dir(os.Args[0])); err != nil {
@@ -55,6 +57,12 @@ func LoadAllSounds() (err error) {
 	return nil
 }
 
+func play(s beep.Streamer) {
+	if output != nil {
+		output(s)
+	}
+}
+
 func ToggleMute() {
 	mute = !mute
 }
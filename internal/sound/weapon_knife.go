@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -31,7 +30,7 @@ func loadWeaponKnifeStabSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponKnifeStabBuffer = beep.NewBuffer(format)
 	weaponKnifeStabBuffer.Append(resampled)
 	return nil
@@ -40,7 +39,7 @@ func loadWeaponKnifeStabSound(assetPath string) (err error) {
 func PlayWeaponKnifeStab(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponKnifeStabBuffer.Streamer(0, weaponKnifeStabBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadWeaponM4FireSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponM4FireBuffer = beep.NewBuffer(format)
 	weaponM4FireBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadWeaponM4ReloadSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponM4ReloadBuffer = beep.NewBuffer(format)
 	weaponM4ReloadBuffer.Append(resampled)
 	return nil
@@ -60,7 +59,7 @@ func loadWeaponM4ReloadSound(assetPath string) (err error) {
 func PlayWeaponM4Fire(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponM4FireBuffer.Streamer(0, weaponM4FireBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -71,7 +70,7 @@ func PlayWeaponM4Fire(dist float64) {
 func PlayWeaponM4Reload(dist float64) {
 	k := 1.0 / 100.0
 	streamer := weaponM4ReloadBuffer.Streamer(0, weaponM4ReloadBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadWeaponPistolFireSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponPistolFireBuffer = beep.NewBuffer(format)
 	weaponPistolFireBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadWeaponPistolReloadSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponPistolReloadBuffer = beep.NewBuffer(format)
 	weaponPistolReloadBuffer.Append(resampled)
 	return nil
@@ -60,7 +59,7 @@ func loadWeaponPistolReloadSound(assetPath string) (err error) {
 func PlayWeaponPistolFire(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponPistolFireBuffer.Streamer(0, weaponPistolFireBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -71,7 +70,7 @@ func PlayWeaponPistolFire(dist float64) {
 func PlayWeaponPistolReload(dist float64) {
 	k := 1.0 / 100.0
 	streamer := weaponPistolReloadBuffer.Streamer(0, weaponPistolReloadBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadWeaponShotgunFireSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponShotgunFireBuffer = beep.NewBuffer(format)
 	weaponShotgunFireBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadWeaponShotgunReloadSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponShotgunReloadBuffer = beep.NewBuffer(format)
 	weaponShotgunReloadBuffer.Append(resampled)
 	return nil
@@ -60,7 +59,7 @@ func loadWeaponShotgunReloadSound(assetPath string) (err error) {
 func PlayWeaponShotgunFire(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponShotgunFireBuffer.Streamer(0, weaponShotgunFireBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -71,7 +70,7 @@ func PlayWeaponShotgunFire(dist float64) {
 func PlayWeaponShotgunReload(dist float64) {
 	k := 1.0 / 100.0
 	streamer := weaponShotgunReloadBuffer.Streamer(0, weaponShotgunReloadBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadWeaponSMGFireSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponSMGFireBuffer = beep.NewBuffer(format)
 	weaponSMGFireBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadWeaponSMGReloadSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponSMGReloadBuffer = beep.NewBuffer(format)
 	weaponSMGReloadBuffer.Append(resampled)
 	return nil
@@ -60,7 +59,7 @@ func loadWeaponSMGReloadSound(assetPath string) (err error) {
 func PlayWeaponSMGFire(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponSMGFireBuffer.Streamer(0, weaponSMGFireBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -71,7 +70,7 @@ func PlayWeaponSMGFire(dist float64) {
 func PlayWeaponSMGReload(dist float64) {
 	k := 1.0 / 100.0
 	streamer := weaponSMGReloadBuffer.Streamer(0, weaponSMGReloadBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
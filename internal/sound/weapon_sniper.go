@@ -6,7 +6,6 @@ import (
 	"github.com/faiface/beep"
 	"github.com/faiface/beep/effects"
 	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
 )
 
 // buffer
@@ -35,7 +34,7 @@ func loadWeaponSniperFireSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponSniperFireBuffer = beep.NewBuffer(format)
 	weaponSniperFireBuffer.Append(resampled)
 	return nil
@@ -51,7 +50,7 @@ func loadWeaponSniperReloadSound(assetPath string) (err error) {
 		return err
 	}
 	defer streamer.Close()
-	resampled := beep.Resample(4, format.SampleRate, sampleRate, streamer)
+	resampled := beep.Resample(4, format.SampleRate, SampleRate, streamer)
 	weaponSniperReloadBuffer = beep.NewBuffer(format)
 	weaponSniperReloadBuffer.Append(resampled)
 	return nil
@@ -60,7 +59,7 @@ func loadWeaponSniperReloadSound(assetPath string) (err error) {
 func PlayWeaponSniperFire(dist float64) {
 	k := 1.0 / 500.0
 	streamer := weaponSniperFireBuffer.Streamer(0, weaponSniperFireBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -71,7 +70,7 @@ func PlayWeaponSniperFire(dist float64) {
 func PlayWeaponSniperReload(dist float64) {
 	k := 1.0 / 100.0
 	streamer := weaponSniperReloadBuffer.Streamer(0, weaponSniperReloadBuffer.Len())
-	speaker.Play(&effects.Volume{
+	play(&effects.Volume{
 		Silent:   mute,
 		Streamer: streamer,
 		Base:     2,
@@ -0,0 +1,85 @@
+package ticktime
+
+import (
+	"time"
+)
+
+// defaultClock backs the package functions, it's the clock of the game
+// window which animations and the menu read.
+var defaultClock = NewClock()
+
+func GetDefaultClock() *Clock {
+	return defaultClock
+}
+
+func SetServerTime(t time.Time, p time.Duration) {
+	defaultClock.SetServerTime(t, p)
+}
+
+func SetServerStartTime(t time.Time) {
+	defaultClock.SetServerStartTime(t)
+}
+
+func SetPing(d time.Duration) {
+	defaultClock.SetPing(d)
+}
+
+func GetServerTime() time.Time {
+	return defaultClock.GetServerTime()
+}
+
+func GetLerpTime() time.Time {
+	return defaultClock.GetLerpTime()
+}
+
+func GetServerStartTime() time.Time {
+	return defaultClock.GetServerStartTime()
+}
+
+func GetTick(t time.Time) int64 {
+	return defaultClock.GetTick(t)
+}
+
+func GetTickTime(tick int64) time.Time {
+	return defaultClock.GetTickTime(tick)
+}
+
+func GetPing() time.Duration {
+	return defaultClock.GetPing()
+}
+
+func IsZeroTime(t time.Time) bool {
+	return defaultClock.IsZeroTime(t)
+}
+
+func GetServerTimeMS() int64 {
+	return defaultClock.GetServerTimeMS()
+}
+
+func SetFPS(v int) {
+	defaultClock.SetFPS(v)
+}
+
+func GetFPS() int {
+	return defaultClock.GetFPS()
+}
+
+func AddTimeSample(clientSendTime, serverReceiveTime, serverSendTime, clientReceiveTime time.Time) {
+	defaultClock.AddTimeSample(clientSendTime, serverReceiveTime, serverSendTime, clientReceiveTime)
+}
+
+func AddSnapshotArrival(tick int64) {
+	defaultClock.AddSnapshotArrival(tick)
+}
+
+func GetLerpPeriod() time.Duration {
+	return defaultClock.GetLerpPeriod()
+}
+
+func SetExtrapolation(d time.Duration) {
+	defaultClock.SetExtrapolation(d)
+}
+
+func GetExtrapolation() time.Duration {
+	return defaultClock.GetExtrapolation()
+}
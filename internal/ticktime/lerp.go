@@ -0,0 +1,89 @@
+package ticktime
+
+import (
+	"math"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+)
+
+// extrapolationVisibleTime keeps the extrapolation shown on the hud for a
+// moment, it's set on every update while it lasts
+const extrapolationVisibleTime = 100 * time.Millisecond
+
+// AddSnapshotArrival measures the jitter and loss of world snapshots as they
+// arrive and moves the lerp period towards a delay that covers them, so
+// remote objects have a snapshot on both sides of the lerp time.
+func (c *Clock) AddSnapshotArrival(tick int64) {
+	now := c.GetServerTime()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// Late snapshots are covered by the jitter of the ones around them
+	if tick <= c.lastArrivalTick {
+		return
+	}
+	interval := time.Second / config.ServerSyncRate
+	latency := now.Sub(c.getTickTime(tick))
+	if c.lastArrivalTick > 0 {
+		// Jitter and loss are smoothed the same way as rtp jitter
+		d := latency - c.lastLatency
+		if d < 0 {
+			d = -d
+		}
+		c.jitter += (d - c.jitter) / 16
+		gap := time.Duration(tick-c.lastArrivalTick) * config.Timestep
+		lost := math.Max(0, math.Round(float64(gap)/float64(interval))-1)
+		c.lossRate += (lost/(lost+1) - c.lossRate) / 16
+	}
+	c.lastArrivalTick = tick
+	c.lastLatency = latency
+	target := time.Duration(float64(interval)*(1+config.LerpLossFactor*c.lossRate)) +
+		config.LerpJitterFactor*c.jitter
+	if target < config.MinLerpPeriod {
+		target = config.MinLerpPeriod
+	} else if target > config.MaxLerpPeriod {
+		target = config.MaxLerpPeriod
+	}
+	// Step slowly so the lerp time never jumps
+	if d := target - c.lerpPeriod; d > config.LerpPeriodStep {
+		c.lerpPeriod += config.LerpPeriodStep
+	} else if d < -config.LerpPeriodStep {
+		c.lerpPeriod -= config.LerpPeriodStep
+	} else {
+		c.lerpPeriod = target
+	}
+}
+
+// resetSnapshotArrival is called with lock held when the server time is set
+func (c *Clock) resetSnapshotArrival() {
+	c.lastArrivalTick = 0
+	c.lastLatency = 0
+}
+
+func (c *Clock) GetLerpPeriod() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.lerpPeriod
+}
+
+// SetExtrapolation reports that an object is shown d past its last snapshot
+func (c *Clock) SetExtrapolation(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if d > c.extrapolation || now.Sub(c.extrapolationTime) > extrapolationVisibleTime {
+		c.extrapolation = d
+		c.extrapolationTime = now
+	}
+}
+
+// GetExtrapolation returns the longest extrapolation of the last moment,
+// zero means every object has snapshots to lerp between.
+func (c *Clock) GetExtrapolation() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if time.Since(c.extrapolationTime) > extrapolationVisibleTime {
+		return 0
+	}
+	return c.extrapolation
+}
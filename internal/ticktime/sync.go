@@ -0,0 +1,70 @@
+package ticktime
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+)
+
+type timeSample struct {
+	offset time.Duration
+	rtt    time.Duration
+}
+
+// AddTimeSample refines the server time offset with one NTP style exchange,
+// the times are when the client sent the request, when the server received
+// it, when the server sent the response and when the client received it.
+// The offset is estimated from the samples with the lowest rtt and the
+// current offset moves towards it gradually.
+func (c *Clock) AddTimeSample(clientSendTime, serverReceiveTime, serverSendTime, clientReceiveTime time.Time) {
+	rtt := clientReceiveTime.Sub(clientSendTime) - serverSendTime.Sub(serverReceiveTime)
+	if rtt < 0 {
+		return
+	}
+	offset := (serverReceiveTime.Sub(clientSendTime) + serverSendTime.Sub(clientReceiveTime)) / 2
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.samples = append(c.samples, timeSample{offset: offset, rtt: rtt})
+	if len(c.samples) > config.TimeSyncSamples {
+		c.samples = c.samples[1:]
+	}
+	target, medianRTT := estimateOffset(c.samples)
+	c.ping = medianRTT
+	d := target - c.diff
+	if d > config.TimeSyncJumpThreshold || d < -config.TimeSyncJumpThreshold {
+		c.diff = target
+		return
+	}
+	c.diff += time.Duration(float64(d) * config.TimeSyncSmoothing)
+}
+
+// estimateOffset drops samples whose rtt is far above the median, a delayed
+// request or response makes the offset of its sample wrong by up to half of
+// the delay. The median offset of the rest is returned.
+func estimateOffset(samples []timeSample) (offset, medianRTT time.Duration) {
+	rtts := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		rtts[i] = sample.rtt
+	}
+	medianRTT = getMedian(rtts)
+	maxRTT := time.Duration(float64(medianRTT) * config.TimeSyncOutlierFactor)
+	offsets := []time.Duration{}
+	for _, sample := range samples {
+		if sample.rtt <= maxRTT {
+			offsets = append(offsets, sample.offset)
+		}
+	}
+	return getMedian(offsets), medianRTT
+}
+
+func getMedian(list []time.Duration) time.Duration {
+	if len(list) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, list...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted[len(sorted)/2]
+}
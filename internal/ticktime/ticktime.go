@@ -1,68 +1,116 @@
 package ticktime
 
 import (
+	"sync"
 	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
 )
 
-var (
+// Clock maps local time to the server time and ticks of one game, worlds
+// and processors hold their own clock so several can run in a process.
+type Clock struct {
 	startTime time.Time
 	diff      time.Duration
 	ping      time.Duration
 	fps       int
-)
+	// clock sync samples
+	samples []timeSample
+	// arrival stats of world snapshots
+	lerpPeriod        time.Duration
+	lastArrivalTick   int64
+	lastLatency       time.Duration
+	jitter            time.Duration
+	lossRate          float64
+	extrapolation     time.Duration
+	extrapolationTime time.Time
+	// diff and ping are refined by clock sync while the world reads them
+	lock sync.RWMutex
+}
+
+func NewClock() *Clock {
+	return &Clock{
+		lerpPeriod: config.LerpPeriod,
+	}
+}
 
-func SetServerTime(t time.Time, p time.Duration) {
-	diff = time.Until(t) + p/2
+// SetServerTime sets the offset from a single request, clock sync samples
+// taken before are dropped.
+func (c *Clock) SetServerTime(t time.Time, p time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.diff = time.Until(t) + p/2
+	c.samples = nil
+	c.resetSnapshotArrival()
 }
 
-func SetServerStartTime(t time.Time) {
-	startTime = t
+func (c *Clock) SetServerStartTime(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.startTime = t
 }
 
-func SetPing(d time.Duration) {
-	ping = d
+func (c *Clock) SetPing(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.ping = d
 }
 
-func GetServerTime() time.Time {
-	return time.Now().Add(diff)
+func (c *Clock) GetServerTime() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return time.Now().Add(c.diff)
 }
 
-func GetLerpTime() time.Time {
-	return GetServerTime().Add(-config.LerpPeriod)
+func (c *Clock) GetLerpTime() time.Time {
+	return c.GetServerTime().Add(-c.GetLerpPeriod())
 }
 
-func GetServerStartTime() time.Time {
-	return startTime
+func (c *Clock) GetServerStartTime() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.startTime
 }
 
-func GetTick(t time.Time) int64 {
-	d := t.Sub(startTime)
+func (c *Clock) GetTick(t time.Time) int64 {
+	d := t.Sub(c.GetServerStartTime())
 	return d.Nanoseconds() / config.Timestep.Nanoseconds()
 }
 
-func GetTickTime(tick int64) time.Time {
+func (c *Clock) GetTickTime(tick int64) time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.getTickTime(tick)
+}
+
+// getTickTime is called with lock held
+func (c *Clock) getTickTime(tick int64) time.Time {
 	d := time.Duration((tick * config.Timestep.Nanoseconds()))
-	return startTime.Add(d)
+	return c.startTime.Add(d)
 }
 
-func GetPing() time.Duration {
-	return ping
+func (c *Clock) GetPing() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.ping
 }
 
-func IsZeroTime(t time.Time) bool {
-	return t.Before(startTime)
+func (c *Clock) IsZeroTime(t time.Time) bool {
+	return t.Before(c.GetServerStartTime())
 }
 
-func GetServerTimeMS() int64 {
-	return GetServerTime().UnixNano() / 1000000
+func (c *Clock) GetServerTimeMS() int64 {
+	return c.GetServerTime().UnixNano() / 1000000
 }
 
-func SetFPS(v int) {
-	fps = v
+func (c *Clock) SetFPS(v int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fps = v
 }
 
-func GetFPS() int {
-	return fps
+func (c *Clock) GetFPS() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.fps
 }
@@ -2,6 +2,7 @@ package util
 
 import (
 	"math/rand"
+	"sort"
 
 	"github.com/faiface/pixel"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
@@ -28,9 +29,9 @@ func ConvertRect(r pixel.Rect) *protocol.Rect {
 	}
 }
 
-func RandomVec(r pixel.Rect) pixel.Vec {
-	x := r.Min.X + rand.Float64()*r.W()
-	y := r.Min.Y + rand.Float64()*r.H()
+func RandomVec(rng *rand.Rand, r pixel.Rect) pixel.Vec {
+	x := r.Min.X + rng.Float64()*r.W()
+	y := r.Min.Y + rng.Float64()*r.H()
 	return pixel.V(x, y)
 }
 
@@ -38,6 +39,46 @@ func GetHighVec() pixel.Vec {
 	return pixel.V(1000000, 1000000)
 }
 
+// IsRectCovered tells whether every point of r is in one of covers. The
+// edges of covers split r into cells, each cell must be inside a cover.
+func IsRectCovered(r pixel.Rect, covers []pixel.Rect) bool {
+	xs := []float64{r.Min.X, r.Max.X}
+	ys := []float64{r.Min.Y, r.Max.Y}
+	for _, c := range covers {
+		for _, x := range []float64{c.Min.X, c.Max.X} {
+			if x > r.Min.X && x < r.Max.X {
+				xs = append(xs, x)
+			}
+		}
+		for _, y := range []float64{c.Min.Y, c.Max.Y} {
+			if y > r.Min.Y && y < r.Max.Y {
+				ys = append(ys, y)
+			}
+		}
+	}
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+	for i := 1; i < len(xs); i++ {
+		for j := 1; j < len(ys); j++ {
+			if xs[i] == xs[i-1] || ys[j] == ys[j-1] {
+				continue
+			}
+			center := pixel.V((xs[i-1]+xs[i])/2, (ys[j-1]+ys[j])/2)
+			isCovered := false
+			for _, c := range covers {
+				if c.Contains(center) {
+					isCovered = true
+					break
+				}
+			}
+			if !isCovered {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func LerpScalar(a, b, t float64) float64 {
 	return (b-a)*t + a
 }
@@ -32,8 +32,14 @@ func (db *inMemDB) SelectOne(key string) (value interface{}, exists bool) {
 	return nil, false
 }
 
+// SelectAll returns a copy of the rows, so they can be iterated while rows
+// are deleted.
 func (db *inMemDB) SelectAll() []interface{} {
-	return db.rows
+	db.indexLock.RLock()
+	defer db.indexLock.RUnlock()
+	rows := make([]interface{}, len(db.rows))
+	copy(rows, db.rows)
+	return rows
 }
 
 func (db *inMemDB) Set(key string, value interface{}) {
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"container/heap"
+	"image"
+	"math"
+)
+
+var pathDirs = []image.Point{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+// FindPath finds the shortest path between two cells of a grid with A*.
+// Moves go in 8 directions and a diagonal move can't cut a blocked corner.
+// The path doesn't include start, nil is returned when goal can't be reached.
+func FindPath(width, height int, isBlocked func(x, y int) bool, start, goal image.Point) []image.Point {
+	inGrid := func(p image.Point) bool {
+		return p.X >= 0 && p.Y >= 0 && p.X < width && p.Y < height
+	}
+	if !inGrid(start) || !inGrid(goal) || isBlocked(goal.X, goal.Y) {
+		return nil
+	}
+	if start == goal {
+		return []image.Point{}
+	}
+	index := func(p image.Point) int {
+		return p.Y*width + p.X
+	}
+	costs := make([]float64, width*height)
+	for i := range costs {
+		costs[i] = math.Inf(1)
+	}
+	parents := make([]int, width*height)
+	closed := make([]bool, width*height)
+	costs[index(start)] = 0
+	open := &pathHeap{{pos: start, score: pathHeuristic(start, goal)}}
+	for open.Len() > 0 {
+		node := heap.Pop(open).(*pathNode)
+		i := index(node.pos)
+		if closed[i] {
+			continue
+		}
+		closed[i] = true
+		if node.pos == goal {
+			return buildPath(parents, width, start, goal)
+		}
+		for _, dir := range pathDirs {
+			next := node.pos.Add(dir)
+			if !inGrid(next) || isBlocked(next.X, next.Y) || closed[index(next)] {
+				continue
+			}
+			cost := 1.0
+			if dir.X != 0 && dir.Y != 0 {
+				if isBlocked(node.pos.X+dir.X, node.pos.Y) || isBlocked(node.pos.X, node.pos.Y+dir.Y) {
+					continue
+				}
+				cost = math.Sqrt2
+			}
+			j := index(next)
+			if c := costs[i] + cost; c < costs[j] {
+				costs[j] = c
+				parents[j] = i
+				heap.Push(open, &pathNode{pos: next, score: c + pathHeuristic(next, goal)})
+			}
+		}
+	}
+	return nil
+}
+
+// pathHeuristic is the octile distance, it never overestimates 8-way moves
+func pathHeuristic(a, b image.Point) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+func buildPath(parents []int, width int, start, goal image.Point) []image.Point {
+	path := []image.Point{}
+	startIndex := start.Y*width + start.X
+	for i := goal.Y*width + goal.X; i != startIndex; i = parents[i] {
+		path = append(path, image.Pt(i%width, i/width))
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+type pathNode struct {
+	pos   image.Point
+	score float64
+}
+
+type pathHeap []*pathNode
+
+func (h pathHeap) Len() int            { return len(h) }
+func (h pathHeap) Less(i, j int) bool  { return h[i].score < h[j].score }
+func (h pathHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x interface{}) { *h = append(*h, x.(*pathNode)) }
+
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	*h = old[:n-1]
+	return node
+}
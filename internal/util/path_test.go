@@ -0,0 +1,125 @@
+package util
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// parseTestGrid reads a grid drawn top row first, # is blocked, S is start,
+// G is goal, X is both and @ is a blocked goal
+func parseTestGrid(rows []string) (width, height int, isBlocked func(x, y int) bool, start, goal image.Point) {
+	width, height = len(rows[0]), len(rows)
+	blocked := make([]bool, width*height)
+	for i, row := range rows {
+		y := height - 1 - i
+		for x, c := range row {
+			switch c {
+			case '#':
+				blocked[y*width+x] = true
+			case 'S':
+				start = image.Pt(x, y)
+			case 'G':
+				goal = image.Pt(x, y)
+			case 'X':
+				start, goal = image.Pt(x, y), image.Pt(x, y)
+			case '@':
+				blocked[y*width+x] = true
+				goal = image.Pt(x, y)
+			}
+		}
+	}
+	isBlocked = func(x, y int) bool {
+		return x < 0 || y < 0 || x >= width || y >= height || blocked[y*width+x]
+	}
+	return width, height, isBlocked, start, goal
+}
+
+// checkPath fails unless path walks from start to goal by legal moves, it
+// returns the cost of the path
+func checkPath(t *testing.T, path []image.Point, isBlocked func(x, y int) bool, start, goal image.Point) float64 {
+	cost := 0.0
+	pos := start
+	for _, next := range path {
+		dir := next.Sub(pos)
+		if dir.X < -1 || dir.X > 1 || dir.Y < -1 || dir.Y > 1 || dir == image.ZP {
+			t.Fatalf("path jumps from %v to %v", pos, next)
+		}
+		if isBlocked(next.X, next.Y) {
+			t.Fatalf("path goes through blocked %v", next)
+		}
+		if dir.X != 0 && dir.Y != 0 {
+			if isBlocked(pos.X+dir.X, pos.Y) || isBlocked(pos.X, pos.Y+dir.Y) {
+				t.Fatalf("path cuts a corner from %v to %v", pos, next)
+			}
+			cost += math.Sqrt2
+		} else {
+			cost++
+		}
+		pos = next
+	}
+	if pos != goal {
+		t.Fatalf("path ends at %v, not %v", pos, goal)
+	}
+	return cost
+}
+
+func TestFindPath(t *testing.T) {
+	cases := []struct {
+		name  string
+		grid  []string
+		found bool
+		cost  float64
+	}{
+		{"straight", []string{
+			"S...G",
+		}, true, 4},
+		{"diagonal", []string{
+			"...G",
+			"....",
+			"S...",
+		}, true, 1 + 2*math.Sqrt2},
+		{"around a wall", []string{
+			".....",
+			".###.",
+			"S#.#G",
+			".#...",
+		}, true, 8},
+		{"no corner cutting", []string{
+			"#G",
+			"S#",
+		}, false, 0},
+		{"start is goal", []string{
+			"...",
+			".X.",
+			"...",
+		}, true, 0},
+		{"blocked goal", []string{
+			"S.@",
+		}, false, 0},
+		{"no route", []string{
+			"..#..",
+			"S.#.G",
+			"..#..",
+		}, false, 0},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			width, height, isBlocked, start, goal := parseTestGrid(c.grid)
+			path := FindPath(width, height, isBlocked, start, goal)
+			if !c.found {
+				if path != nil {
+					t.Fatalf("found path %v", path)
+				}
+				return
+			}
+			if path == nil {
+				t.Fatal("path wasn't found")
+			}
+			if cost := checkPath(t, path, isBlocked, start, goal); math.Abs(cost-c.cost) > 1e-9 {
+				t.Fatalf("path %v costs %v, shortest costs %v", path, cost, c.cost)
+			}
+		})
+	}
+}
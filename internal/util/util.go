@@ -1,7 +1,10 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
@@ -14,9 +17,13 @@ func init() {
 var letterRunes = []rune("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func RandString(n int) string {
+	return randString(rand.Intn, n)
+}
+
+func randString(intn func(n int) int, n int) string {
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+		b[i] = letterRunes[intn(len(letterRunes))]
 	}
 	return string(b)
 }
@@ -24,3 +31,42 @@ func RandString(n int) string {
 func GenerateID() string {
 	return RandString(config.IDLength)
 }
+
+// GenerateIDFrom draws an id from rng, a world uses its own so a seed
+// reproduces its object ids.
+func GenerateIDFrom(rng *rand.Rand) string {
+	return randString(rng.Intn, config.IDLength)
+}
+
+// NewRand returns a seeded random source which is safe to share between
+// goroutines.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed)})
+}
+
+type lockedSource struct {
+	lock sync.Mutex
+	src  rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.src.Seed(seed)
+}
+
+// GenerateToken returns a secret from crypto/rand, unlike ids it can't be
+// guessed from other values.
+func GenerateToken() (string, error) {
+	b := make([]byte, config.TokenLength)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -5,10 +5,10 @@ import (
 	"image/color"
 	"math/rand"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/animation"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
 	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
@@ -28,20 +28,22 @@ var (
 )
 
 const (
-	minNextItemPerd             = 10
-	maxNextItemPerd             = 20
-	defaultWorldFieldWidth      = 20
-	defaultWorldFieldHeight     = 20
-	defaultWorldTreeAmount      = 16
-	defaultWorldTerrainAmount   = 8
 	defaultWorldMinSpawnDist    = 48
 	defaultWorldBoundarySize    = 200
 	defaultWorldRestartCooldown = 5 * time.Second
+	// players farther than this are never sent
+	defaultWorldAOIRange = 1200
+	// server scope is a bit larger, input reaches the server late
+	defaultWorldScopeMargin = 32
 )
 
 type defaultWorld struct {
 	// common
 	id          string
+	isServer    bool
+	clock       *ticktime.Clock
+	rand        *rand.Rand
+	cfg         *config.WorldConfig
 	destroyed   bool
 	objectDB    common.ObjectDB
 	hud         common.Hud
@@ -50,11 +52,13 @@ type defaultWorld struct {
 	fieldHeight int
 	skullID     string
 	// client
-	win              *pixelgl.Window
+	win              common.Window
 	toggleFPSLimit   func()
 	batch            *pixel.Batch
 	currRawInput     *common.RawInput
 	prevRawInput     *common.RawInput
+	inputSeq         int64
+	inputTime        time.Time
 	currSettingInput *common.RawInput
 	prevSettingInput *common.RawInput
 	mainPlayerID     string
@@ -63,26 +67,44 @@ type defaultWorld struct {
 	water            common.Water
 	frameCount       int
 	fpsUpdateTime    time.Time
+	pendingEvents    []*protocol.GameEvent
+	lastEventSeq     int64
+	eventLock        sync.Mutex
 	// server
-	tick         int64
-	nextItemTime time.Time
-	destroyTime  time.Time
-}
-
-func NewDefaultWorld(clientProcessor common.ClientProcessor, id string) common.World {
+	eventLog           *protocol.GameEventLog
+	tick               int64
+	nextItemTime       time.Time
+	destroyTime        time.Time
+	botMap             map[string]*defaultWorldBot
+	botCount           int
+	botLock            sync.Mutex
+	nextBotBalanceTime time.Time
+	navWidth           int
+	navHeight          int
+	navBlocked         []bool
+}
+
+func NewDefaultWorld(clientProcessor common.ClientProcessor, clock *ticktime.Clock, id string,
+	cfg *config.WorldConfig) common.World {
+	rng := util.NewRand(cfg.Seed)
 	world := &defaultWorld{
 		// common
 		id:          id,
-		objectDB:    common.NewObjectDB(),
-		fieldWidth:  defaultWorldFieldWidth,
-		fieldHeight: defaultWorldFieldHeight,
+		isServer:    clientProcessor == nil,
+		clock:       clock,
+		rand:        rng,
+		cfg:         cfg,
+		objectDB:    common.NewObjectDB(rng),
+		fieldWidth:  cfg.FieldWidth,
+		fieldHeight: cfg.FieldHeight,
 		// client
 		currRawInput:     &common.RawInput{},
 		prevRawInput:     &common.RawInput{},
 		currSettingInput: &common.RawInput{},
 		prevSettingInput: &common.RawInput{},
 		// server
-		nextItemTime: ticktime.GetServerTime(),
+		botMap:   make(map[string]*defaultWorldBot),
+		eventLog: protocol.NewGameEventLog(config.GameEventHistory),
 	}
 	// common
 	world.hud = entity.NewHud(world)
@@ -94,9 +116,10 @@ func NewDefaultWorld(clientProcessor common.ClientProcessor, id string) common.W
 		world.batch = pixel.NewBatch(&pixel.TrianglesData{}, animation.GetObjectSheet())
 		world.scope = entity.NewScope(world)
 		world.water = entity.NewWater(world)
-		world.fpsUpdateTime = ticktime.GetServerTime()
+		world.fpsUpdateTime = clock.GetServerTime()
 	} else {
 		// server
+		world.nextItemTime = world.GetTime()
 		world.createTrees()
 		world.createTerrains()
 		world.createBoundaries()
@@ -109,10 +132,34 @@ func (w *defaultWorld) GetID() string {
 	return w.id
 }
 
+func (w *defaultWorld) GetClock() *ticktime.Clock {
+	return w.clock
+}
+
+// GetTime returns the time the world is simulated at. The server moves it by
+// exactly a timestep per tick, so a game doesn't depend on when ticks run,
+// the client follows the server time.
+func (w *defaultWorld) GetTime() time.Time {
+	if w.isServer {
+		return w.clock.GetTickTime(w.tick)
+	}
+	return w.clock.GetServerTime()
+}
+
+// GetRand returns the random source of the world, it is seeded from the
+// config so the same seed and inputs replay the same game.
+func (w *defaultWorld) GetRand() *rand.Rand {
+	return w.rand
+}
+
 func (w *defaultWorld) GetType() int {
 	return config.DefaultWorld
 }
 
+func (w *defaultWorld) GetWorldConfig() *config.WorldConfig {
+	return w.cfg
+}
+
 func (w *defaultWorld) GetObjectDB() common.ObjectDB {
 	return w.objectDB
 }
@@ -170,9 +217,11 @@ func (w *defaultWorld) ServerUpdate(tick int64) bool {
 	}
 	w.tick = tick
 	// Item
-	if ticktime.GetServerTime().After(w.nextItemTime) {
+	if w.GetTime().After(w.nextItemTime) {
 		w.nextItemTime = w.spawnItem()
 	}
+	// Bot
+	w.updateBots()
 	// Snapshot
 	for _, o := range w.objectDB.SelectAll() {
 		o.ServerUpdate(tick)
@@ -189,6 +238,8 @@ func (w *defaultWorld) GetSnapshot(all bool) (int64, *protocol.WorldSnapshot) {
 		Type:             w.GetType(),
 		FieldWidth:       w.fieldWidth,
 		FieldHeight:      w.fieldHeight,
+		RespawnTimeMS:    int(w.cfg.RespawnTime.Seconds() * 1000),
+		InitTimeMS:       int(w.cfg.InitTime.Seconds() * 1000),
 		KillFeedSnapshot: w.hud.GetKillFeedSnapshot(),
 	}
 	for _, o := range w.objectDB.SelectAll() {
@@ -206,9 +257,153 @@ func (w *defaultWorld) GetSnapshot(all bool) (int64, *protocol.WorldSnapshot) {
 	return w.tick, snapshot
 }
 
+// FilterSnapshot returns what the player is allowed to see, enemies hidden
+// from the player only keep their scoreboard stats. Their bullets and weapons
+// are left out and what they carry is moved away, those would give away where
+// they are.
+func (w *defaultWorld) FilterSnapshot(playerID string, snapshot *protocol.WorldSnapshot) *protocol.WorldSnapshot {
+	var viewer common.Player
+	if o, exists := w.objectDB.SelectOne(playerID); exists {
+		viewer = o.(common.Player)
+	}
+	treeShapes := []pixel.Rect{}
+	for _, o := range w.objectDB.SelectAll() {
+		if o.GetType() == config.TreeObject {
+			treeShapes = append(treeShapes, o.GetShape())
+		}
+	}
+	hiddenMap := make(map[string]bool)
+	for _, ss := range snapshot.ObjectSnapshots {
+		if ss.Type == config.PlayerObject && ss.ID != playerID && !w.isVisibleTo(viewer, ss.ID, treeShapes) {
+			hiddenMap[ss.ID] = true
+		}
+	}
+	filtered := *snapshot
+	filtered.ObjectSnapshots = make([]*protocol.ObjectSnapshot, 0, len(snapshot.ObjectSnapshots))
+	for _, ss := range snapshot.ObjectSnapshots {
+		switch {
+		case hiddenMap[ss.ID]:
+			ss = getHiddenPlayerSnapshot(ss)
+		case ss.Bullet != nil && hiddenMap[ss.Bullet.PlayerID]:
+			continue
+		case ss.Weapon != nil && hiddenMap[getWeaponPlayerID(ss.Weapon)]:
+			continue
+		case ss.Item != nil && hiddenMap[getCarrierPlayerID(ss.Item)]:
+			ss = getHiddenItemSnapshot(ss)
+		}
+		filtered.ObjectSnapshots = append(filtered.ObjectSnapshots, ss)
+	}
+	return &filtered
+}
+
+func (w *defaultWorld) isVisibleTo(viewer common.Player, playerID string, treeShapes []pixel.Rect) bool {
+	o, exists := w.objectDB.SelectOne(playerID)
+	if !exists {
+		return false
+	}
+	player := o.(common.Player)
+	if viewer == nil {
+		return player.IsVisible()
+	}
+	if player.GetPivot().Sub(viewer.GetPivot()).Len() > defaultWorldAOIRange {
+		return false
+	}
+	// Dead players have no scope
+	if player.IsVisible() || !viewer.IsAlive() {
+		return true
+	}
+	cursorDir := viewer.GetCursorDir()
+	radius := viewer.GetScopeRadius(cursorDir.Len())
+	if radius == 0 {
+		return false
+	}
+	shape := player.GetShape()
+	circle := pixel.C(viewer.GetPivot().Add(cursorDir), radius+defaultWorldScopeMargin)
+	if circle.IntersectRect(shape).Eq(pixel.ZV) {
+		return false
+	}
+	// Trees in front of the player, overlapping trees may hide it together
+	covers := []pixel.Rect{}
+	for _, treeShape := range treeShapes {
+		if treeShape.Min.Y < shape.Min.Y && treeShape.Intersects(shape) {
+			covers = append(covers, treeShape)
+		}
+	}
+	return len(covers) == 0 || !util.IsRectCovered(shape, covers)
+}
+
+func getHiddenPlayerSnapshot(ss *protocol.ObjectSnapshot) *protocol.ObjectSnapshot {
+	return &protocol.ObjectSnapshot{
+		ID:   ss.ID,
+		Type: ss.Type,
+		Player: &protocol.PlayerSnapshot{
+			PlayerName:  ss.Player.PlayerName,
+			Kill:        ss.Player.Kill,
+			Death:       ss.Player.Death,
+			Streak:      ss.Player.Streak,
+			MaxStreak:   ss.Player.MaxStreak,
+			CursorDir:   util.ConvertVec(pixel.ZV),
+			Pos:         util.ConvertVec(util.GetHighVec()),
+			MoveDir:     util.ConvertVec(pixel.ZV),
+			RespawnTime: ss.Player.RespawnTime,
+			IsHidden:    true,
+			IsBot:       ss.Player.IsBot,
+		},
+	}
+}
+
+func getWeaponPlayerID(ss *protocol.WeaponSnapshot) string {
+	switch {
+	case ss.M4 != nil:
+		return ss.M4.PlayerID
+	case ss.Shotgun != nil:
+		return ss.Shotgun.PlayerID
+	case ss.Sniper != nil:
+		return ss.Sniper.PlayerID
+	case ss.Pistol != nil:
+		return ss.Pistol.PlayerID
+	case ss.SMG != nil:
+		return ss.SMG.PlayerID
+	case ss.Knife != nil:
+		return ss.Knife.PlayerID
+	}
+	return ""
+}
+
+// getCarrierPlayerID returns the player who carries the item, a placed land
+// mine stays where it is.
+func getCarrierPlayerID(ss *protocol.ItemSnapshot) string {
+	switch {
+	case ss.Skull != nil:
+		return ss.Skull.PlayerID
+	case ss.LandMine != nil && !ss.LandMine.IsActive:
+		return ss.LandMine.PlayerID
+	}
+	return ""
+}
+
+func getHiddenItemSnapshot(ss *protocol.ObjectSnapshot) *protocol.ObjectSnapshot {
+	item := *ss.Item
+	switch {
+	case item.Skull != nil:
+		skull := *item.Skull
+		skull.Pos = util.ConvertVec(util.GetHighVec())
+		item.Skull = &skull
+	case item.LandMine != nil:
+		landMine := *item.LandMine
+		landMine.Pos = util.ConvertVec(util.GetHighVec())
+		item.LandMine = &landMine
+	}
+	return &protocol.ObjectSnapshot{
+		ID:   ss.ID,
+		Type: ss.Type,
+		Item: &item,
+	}
+}
+
 func (w *defaultWorld) Destroy() {
 	w.destroyed = true
-	w.destroyTime = ticktime.GetServerTime()
+	w.destroyTime = w.GetTime()
 }
 
 func (w *defaultWorld) createBoundaries() {
@@ -240,7 +435,7 @@ func (w *defaultWorld) createSkull() {
 
 func (w *defaultWorld) getFreePos() pixel.Vec {
 	for i := 0; i < 10; i++ {
-		pos := util.RandomVec(w.getSizeRect())
+		pos := util.RandomVec(w.rand, w.getSizeRect())
 		rect := pixel.R(
 			-defaultWorldMinSpawnDist,
 			-defaultWorldMinSpawnDist,
@@ -283,10 +478,10 @@ func (w *defaultWorld) SpawnPlayer(playerID string, playerName string) {
 	w.objectDB.Set(player)
 }
 
-func (w *defaultWorld) SetInputSnapshot(playerID string, snapshot *protocol.InputSnapshot) {
+func (w *defaultWorld) SetInputSnapshot(playerID string, seq int64, snapshot *protocol.InputSnapshot) {
 	if o, exists := w.objectDB.SelectOne(playerID); exists && o.GetType() == config.PlayerObject {
 		player := o.(common.Player)
-		player.SetInput(snapshot)
+		player.AddInput(seq, snapshot)
 	}
 }
 
@@ -309,8 +504,13 @@ func (w *defaultWorld) spawnItem() (nextItemTime time.Time) {
 		logger.Debugf(context.Background(), "spawn_item:%s", item.GetID())
 	}
 	// Random next item time
-	n := rand.Int()%(maxNextItemPerd-minNextItemPerd) + minNextItemPerd
-	return ticktime.GetServerTime().Add(time.Duration(n) * time.Second)
+	minPeriod := w.cfg.MinNextItemPeriod.Duration
+	maxPeriod := w.cfg.MaxNextItemPeriod.Duration
+	period := minPeriod
+	if maxPeriod > minPeriod {
+		period += time.Duration(w.rand.Int63n(int64(maxPeriod - minPeriod)))
+	}
+	return w.GetTime().Add(period)
 }
 
 func (w *defaultWorld) spawnWeaponItem() common.Item {
@@ -340,39 +540,39 @@ func (w *defaultWorld) spawnLandMineItem() common.Item {
 // Props
 
 func (w *defaultWorld) createTrees() {
-	for i := 0; i < defaultWorldTreeAmount; i++ {
+	for i := 0; i < w.cfg.TreeAmount; i++ {
 		treeID := w.objectDB.GetAvailableID()
 		logger.Debugf(context.Background(), "create_tree:%s", treeID)
 		tree := entity.NewTree(w, treeID)
 		w.objectDB.Set(tree)
 		pos := w.getFreePos()
-		index := int(rand.Uint32()) % len(config.TreeTypes)
+		index := int(w.rand.Uint32()) % len(config.TreeTypes)
 		treeType := config.TreeTypes[index]
-		right := rand.Int()%2 != 0
+		right := w.rand.Int()%2 != 0
 		tree.SetState(pos, treeType, right)
 	}
 }
 
 func (w *defaultWorld) createTerrains() {
-	for i := 0; i < defaultWorldTerrainAmount; i++ {
+	for i := 0; i < w.cfg.TerrainAmount; i++ {
 		terrainID := w.objectDB.GetAvailableID()
 		logger.Debugf(context.Background(), "create_terrain:%s", terrainID)
 		terrain := entity.NewTerrain(w, terrainID)
 		w.objectDB.Set(terrain)
 		pos := w.getFreePos()
-		terrainType := int(rand.Uint32()) % config.TerrainTypeAmount
+		terrainType := int(w.rand.Uint32()) % config.TerrainTypeAmount
 		terrain.SetState(pos, terrainType)
 	}
 }
 
 // Client
 
-func (w *defaultWorld) GetWindow() *pixelgl.Window {
+func (w *defaultWorld) GetWindow() common.Window {
 	return w.win
 }
 
 func (w *defaultWorld) ClientUpdate() bool {
-	now := ticktime.GetServerTime()
+	now := w.clock.GetServerTime()
 	if w.destroyed {
 		return !(now.Sub(w.destroyTime) >= defaultWorldRestartCooldown)
 	}
@@ -381,6 +581,7 @@ func (w *defaultWorld) ClientUpdate() bool {
 	for _, o := range w.objectDB.SelectAll() {
 		o.ClientUpdate()
 	}
+	w.dispatchGameEvents()
 	w.hud.ClientUpdate()
 	w.scoreboard.ClientUpdate()
 	w.scope.Update()
@@ -466,13 +667,17 @@ func (w *defaultWorld) SetSnapshot(tick int64, snapshot *protocol.WorldSnapshot)
 	if snapshot.ID != w.GetID() {
 		if !w.destroyed {
 			w.destroyed = true
-			w.destroyTime = ticktime.GetServerTime()
+			w.destroyTime = w.clock.GetServerTime()
 		}
 		logger.Debugf(context.Background(), "get different world id, current_id=%s, new_id", w.GetID(), snapshot.ID)
 		return
 	}
 	w.fieldWidth = snapshot.FieldWidth
 	w.fieldHeight = snapshot.FieldHeight
+	w.cfg.FieldWidth = snapshot.FieldWidth
+	w.cfg.FieldHeight = snapshot.FieldHeight
+	w.cfg.RespawnTime.Duration = time.Duration(snapshot.RespawnTimeMS) * time.Millisecond
+	w.cfg.InitTime.Duration = time.Duration(snapshot.InitTimeMS) * time.Millisecond
 	existsMap := make(map[string]bool)
 	for _, ss := range snapshot.ObjectSnapshots {
 		existsMap[ss.ID] = true
@@ -538,9 +743,9 @@ func (w *defaultWorld) isInScreen(r pixel.Rect) bool {
 
 func (w *defaultWorld) updateFPS() {
 	w.frameCount++
-	now := ticktime.GetServerTime()
+	now := w.clock.GetServerTime()
 	if now.Sub(w.fpsUpdateTime) >= time.Second {
-		ticktime.SetFPS(w.frameCount)
+		w.clock.SetFPS(w.frameCount)
 		w.frameCount = 0
 		w.fpsUpdateTime = now
 	}
@@ -548,11 +753,18 @@ func (w *defaultWorld) updateFPS() {
 
 // Input
 
-func (w *defaultWorld) GetInputSnapshot() *protocol.InputSnapshot {
+func (w *defaultWorld) GetInputSnapshot() (seq int64, snapshot *protocol.InputSnapshot) {
 	player := w.GetMainPlayer()
 	if player == nil {
-		return nil
+		return 0, nil
+	}
+	now := w.clock.GetServerTime()
+	var duration time.Duration
+	if !w.clock.IsZeroTime(w.inputTime) {
+		duration = now.Sub(w.inputTime)
 	}
+	w.inputTime = now
+	w.inputSeq++
 	pivot := player.GetPivot().Sub(w.GetCameraViewPos())
 	inputSS := &protocol.InputSnapshot{
 		CursorDir:  util.ConvertVec(w.currRawInput.MousePos.Sub(pivot)),
@@ -567,37 +779,19 @@ func (w *defaultWorld) GetInputSnapshot() *protocol.InputSnapshot {
 		Use1stItem: !w.prevRawInput.PressedUse1stItemKey && w.currRawInput.PressedUse1stItemKey,
 		Use2ndItem: !w.prevRawInput.PressedUse2ndItemKey && w.currRawInput.PressedUse2ndItemKey,
 		Use3rdItem: !w.prevRawInput.PressedUse3rdItemKey && w.currRawInput.PressedUse3rdItemKey,
+		ViewTime:   w.clock.GetLerpTime().UnixNano(),
+		Duration:   duration.Nanoseconds(),
+		Time:       w.GetTime().UnixNano(),
 	}
 	w.prevRawInput = w.currRawInput
 	w.currRawInput = &common.RawInput{}
 	player.SetInput(inputSS)
-	return inputSS
-}
-
-func (w *defaultWorld) getRawInput() *common.RawInput {
-	return &common.RawInput{
-		MousePos:                w.win.MousePosition(),
-		PressedFireKey:          w.win.Pressed(config.FireKey),
-		PressedMeleeKey:         w.win.Pressed(config.MeleeKey),
-		PressedUpKey:            w.win.Pressed(config.UpKey),
-		PressedLeftKey:          w.win.Pressed(config.LeftKey),
-		PressedDownKey:          w.win.Pressed(config.DownKey),
-		PressedRightKey:         w.win.Pressed(config.RightKey),
-		PressedReloadKey:        w.win.Pressed(config.ReloadKey),
-		PressedDropKey:          w.win.Pressed(config.DropKey),
-		PressedUse1stItemKey:    w.win.Pressed(config.Use1stItemKey),
-		PressedUse2ndItemKey:    w.win.Pressed(config.Use2ndItemKey),
-		PressedUse3rdItemKey:    w.win.Pressed(config.Use3rdItemKey),
-		PressedToggleMuteKey:    w.win.Pressed(config.ToggleMuteKey),
-		PressedVolumeUpKey:      w.win.Pressed(config.VolumeUpKey),
-		PressedVolumeDownKey:    w.win.Pressed(config.VolumeDownKey),
-		PressedToggleFullScreen: w.win.Pressed(config.ToggleFullScreen),
-		PressedToggleFPSLimit:   w.win.Pressed(config.ToggleFPSLimit),
-	}
+	player.AddInput(w.inputSeq, inputSS)
+	return w.inputSeq, inputSS
 }
 
 func (w *defaultWorld) updateRawInput() {
-	rawInput := w.getRawInput()
+	rawInput := w.win.GetRawInput()
 	currRawInput := &common.RawInput{
 		MousePos:             rawInput.MousePos,
 		PressedFireKey:       rawInput.PressedFireKey || w.currRawInput.PressedFireKey,
@@ -617,7 +811,7 @@ func (w *defaultWorld) updateRawInput() {
 
 func (w *defaultWorld) updateSetting() {
 	w.prevSettingInput = w.currSettingInput
-	w.currSettingInput = w.getRawInput()
+	w.currSettingInput = w.win.GetRawInput()
 	// Settings
 	if !w.prevSettingInput.PressedToggleMuteKey && w.currSettingInput.PressedToggleMuteKey {
 		sound.ToggleMute()
@@ -629,11 +823,7 @@ func (w *defaultWorld) updateSetting() {
 		sound.VolumeDown()
 	}
 	if !w.prevSettingInput.PressedToggleFullScreen && w.currSettingInput.PressedToggleFullScreen {
-		if w.win.Monitor() != nil {
-			w.win.SetMonitor(nil)
-		} else {
-			w.win.SetMonitor(pixelgl.PrimaryMonitor())
-		}
+		w.win.ToggleFullScreen()
 	}
 	if !w.prevSettingInput.PressedToggleFPSLimit && w.currSettingInput.PressedToggleFPSLimit {
 		w.toggleFPSLimit()
@@ -0,0 +1,413 @@
+package world
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+	"github.com/mr-panta/go-logger"
+)
+
+const (
+	defaultWorldBotCellSize      = 32
+	defaultWorldBotColliderSize  = 40 // same as the player collider
+	defaultWorldBotColliderGap   = 4
+	defaultWorldBotBalancePeriod = time.Second
+	defaultWorldBotAimRange      = 500
+	defaultWorldBotAimError      = 24
+	defaultWorldBotMeleeRange    = 72
+	defaultWorldBotKeepRange     = 180
+	defaultWorldBotItemRange     = 480
+	defaultWorldBotReactionTime  = 300 * time.Millisecond
+	defaultWorldBotReplanPeriod  = time.Second
+	defaultWorldBotStuckTime     = 500 * time.Millisecond
+	defaultWorldBotStuckDist     = 4
+	defaultWorldBotMaxWanderTime = 10 * time.Second
+	defaultWorldBotArriveDist    = 8
+	defaultWorldBotDirThreshold  = 4
+)
+
+var defaultWorldBotNames = []string{
+	"ALPHA", "BRAVO", "CHARLIE", "DELTA", "ECHO", "FOXTROT",
+	"GOLF", "HOTEL", "INDIA", "JULIET", "KILO", "LIMA",
+}
+
+// defaultWorldBot controls a player on the server, it produces an input
+// every tick like a client would.
+type defaultWorldBot struct {
+	world      *defaultWorld
+	playerID   string
+	inputSeq   int64
+	inputTime  time.Time
+	goal       pixel.Vec
+	goalTime   time.Time
+	isWander   bool
+	path       []pixel.Vec
+	planTime   time.Time
+	lastPos    pixel.Vec
+	moveTime   time.Time
+	enemyID    string
+	enemyTime  time.Time
+	isStrafing bool
+}
+
+func (w *defaultWorld) SpawnBot() (playerID string) {
+	w.botLock.Lock()
+	defer w.botLock.Unlock()
+	name := defaultWorldBotNames[w.botCount%len(defaultWorldBotNames)]
+	if round := w.botCount / len(defaultWorldBotNames); round > 0 {
+		name = fmt.Sprintf("%s-%d", name, round+1)
+	}
+	w.botCount++
+	playerID = w.objectDB.GetAvailableID()
+	w.SpawnPlayer(playerID, name)
+	if o, exists := w.objectDB.SelectOne(playerID); exists {
+		o.(common.Player).SetBot()
+	}
+	w.botMap[playerID] = &defaultWorldBot{
+		world:    w,
+		playerID: playerID,
+	}
+	logger.Debugf(context.Background(), "spawn_bot:%s|name:%s", playerID, name)
+	return playerID
+}
+
+func (w *defaultWorld) RemoveBot(playerID string) {
+	w.botLock.Lock()
+	defer w.botLock.Unlock()
+	if _, exists := w.botMap[playerID]; !exists {
+		return
+	}
+	delete(w.botMap, playerID)
+	if o, exists := w.objectDB.SelectOne(playerID); exists {
+		o.(common.Player).Die("", "")
+	}
+	w.objectDB.Delete(playerID)
+	logger.Debugf(context.Background(), "remove_bot:%s", playerID)
+}
+
+func (w *defaultWorld) getBots() []*defaultWorldBot {
+	w.botLock.Lock()
+	defer w.botLock.Unlock()
+	bots := make([]*defaultWorldBot, 0, len(w.botMap))
+	for _, bot := range w.botMap {
+		bots = append(bots, bot)
+	}
+	// Map order is random, bots draw from the world rand in a fixed order
+	sort.Slice(bots, func(i, j int) bool {
+		return bots[i].playerID < bots[j].playerID
+	})
+	return bots
+}
+
+// updateBots feeds bot inputs and keeps at least MinPlayers players
+func (w *defaultWorld) updateBots() {
+	now := w.GetTime()
+	if now.After(w.nextBotBalanceTime) {
+		w.balanceBots()
+		w.nextBotBalanceTime = now.Add(defaultWorldBotBalancePeriod)
+	}
+	for _, bot := range w.getBots() {
+		bot.update(now)
+	}
+}
+
+func (w *defaultWorld) balanceBots() {
+	humanCount := 0
+	botIDs := []string{}
+	for _, o := range w.objectDB.SelectAll() {
+		if o.GetType() != config.PlayerObject {
+			continue
+		}
+		if player := o.(common.Player); player.IsBot() {
+			botIDs = append(botIDs, player.GetID())
+		} else {
+			humanCount++
+		}
+	}
+	botCount := w.cfg.MinPlayers - humanCount
+	if botCount < 0 {
+		botCount = 0
+	}
+	for i := len(botIDs); i < botCount; i++ {
+		w.SpawnBot()
+	}
+	for i := botCount; i < len(botIDs); i++ {
+		w.RemoveBot(botIDs[i])
+	}
+}
+
+// Navigation
+
+// getNavGrid marks cells where a player standing at the cell center would
+// hit a tree or a boundary, props never move so it's built once.
+func (w *defaultWorld) getNavGrid() (width, height int, blocked []bool) {
+	if w.navBlocked != nil {
+		return w.navWidth, w.navHeight, w.navBlocked
+	}
+	size := w.getSizeRect()
+	width = int(math.Ceil(size.W() / defaultWorldBotCellSize))
+	height = int(math.Ceil(size.H() / defaultWorldBotCellSize))
+	colliders := []pixel.Rect{}
+	for _, o := range w.objectDB.SelectAll() {
+		if o.GetType() != config.TreeObject && o.GetType() != config.BoundaryObject {
+			continue
+		}
+		if collider, exists := o.GetCollider(); exists {
+			colliders = append(colliders, collider)
+		}
+	}
+	blocked = make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rect := getBotCollider(getCellCenter(image.Pt(x, y)))
+			rect = rect.Resized(rect.Center(), rect.Size().Add(pixel.V(2, 2).Scaled(defaultWorldBotColliderGap)))
+			for _, collider := range colliders {
+				if collider.Intersects(rect) {
+					blocked[y*width+x] = true
+					break
+				}
+			}
+		}
+	}
+	w.navWidth, w.navHeight, w.navBlocked = width, height, blocked
+	return width, height, blocked
+}
+
+func (w *defaultWorld) findPath(from, to pixel.Vec) []pixel.Vec {
+	width, height, blocked := w.getNavGrid()
+	isBlocked := func(x, y int) bool {
+		return x < 0 || y < 0 || x >= width || y >= height || blocked[y*width+x]
+	}
+	cells := util.FindPath(width, height, isBlocked, getCell(from), getCell(to))
+	if cells == nil {
+		return nil
+	}
+	path := make([]pixel.Vec, 0, len(cells))
+	for _, cell := range cells {
+		path = append(path, getCellCenter(cell))
+	}
+	return path
+}
+
+func getCell(pos pixel.Vec) image.Point {
+	return image.Pt(
+		int(math.Floor(pos.X/defaultWorldBotCellSize)),
+		int(math.Floor(pos.Y/defaultWorldBotCellSize)),
+	)
+}
+
+func getCellCenter(cell image.Point) pixel.Vec {
+	return pixel.V(
+		(float64(cell.X)+0.5)*defaultWorldBotCellSize,
+		(float64(cell.Y)+0.5)*defaultWorldBotCellSize,
+	)
+}
+
+func getBotCollider(pos pixel.Vec) pixel.Rect {
+	return pixel.R(
+		pos.X-defaultWorldBotColliderSize/2,
+		pos.Y,
+		pos.X+defaultWorldBotColliderSize/2,
+		pos.Y+defaultWorldBotColliderSize,
+	)
+}
+
+// Controller
+
+func (b *defaultWorldBot) update(now time.Time) {
+	o, exists := b.world.objectDB.SelectOne(b.playerID)
+	if !exists {
+		return
+	}
+	input := b.getInput(o.(common.Player), now)
+	if !b.world.clock.IsZeroTime(b.inputTime) {
+		input.Duration = now.Sub(b.inputTime).Nanoseconds()
+	}
+	b.inputTime = now
+	b.inputSeq++
+	o.(common.Player).AddInput(b.inputSeq, input)
+}
+
+func (b *defaultWorldBot) getInput(me common.Player, now time.Time) *protocol.InputSnapshot {
+	input := &protocol.InputSnapshot{
+		CursorDir: util.ConvertVec(me.GetCursorDir()),
+	}
+	if !me.IsAlive() {
+		b.path = nil
+		b.goalTime = time.Time{}
+		return input
+	}
+	pos := me.GetPos()
+	enemy := b.findEnemy(me, now)
+	switch {
+	case enemy != nil:
+		b.fight(me, enemy, input, now)
+	case b.findItem(me, now):
+	default:
+		b.wander(pos, now)
+	}
+	// Follow path
+	dir := b.getMoveDir(pos, now)
+	if dir.X > defaultWorldBotDirThreshold {
+		input.Right = true
+	} else if dir.X < -defaultWorldBotDirThreshold {
+		input.Left = true
+	}
+	if dir.Y > defaultWorldBotDirThreshold {
+		input.Up = true
+	} else if dir.Y < -defaultWorldBotDirThreshold {
+		input.Down = true
+	}
+	if enemy == nil && dir.Len() > 0 {
+		input.CursorDir = util.ConvertVec(dir)
+	}
+	return input
+}
+
+// findEnemy picks the nearest player which the bot can see, it has to keep
+// seeing the same one for a moment before shooting.
+func (b *defaultWorldBot) findEnemy(me common.Player, now time.Time) common.Player {
+	treeShapes := []pixel.Rect{}
+	for _, o := range b.world.objectDB.SelectAll() {
+		if o.GetType() == config.TreeObject {
+			treeShapes = append(treeShapes, o.GetShape())
+		}
+	}
+	var enemy common.Player
+	enemyDist := math.Inf(1)
+	for _, o := range b.world.objectDB.SelectAll() {
+		if o.GetType() != config.PlayerObject || o.GetID() == b.playerID {
+			continue
+		}
+		player := o.(common.Player)
+		if !player.IsAlive() || !b.world.isVisibleTo(me, player.GetID(), treeShapes) {
+			continue
+		}
+		dist := player.GetPos().Sub(me.GetPos()).Len()
+		if dist < defaultWorldBotAimRange && dist < enemyDist {
+			enemy = player
+			enemyDist = dist
+		}
+	}
+	if enemy == nil {
+		b.enemyID = ""
+		return nil
+	}
+	if enemy.GetID() != b.enemyID {
+		b.enemyID = enemy.GetID()
+		b.enemyTime = now
+		b.isStrafing = b.world.rand.Intn(2) == 0
+	}
+	return enemy
+}
+
+func (b *defaultWorldBot) fight(me, enemy common.Player, input *protocol.InputSnapshot, now time.Time) {
+	pos := me.GetPos()
+	enemyPos := enemy.GetPos()
+	diff := enemyPos.Sub(pos)
+	aimError := pixel.V(b.world.rand.Float64()-0.5, b.world.rand.Float64()-0.5).Scaled(2 * defaultWorldBotAimError)
+	input.CursorDir = util.ConvertVec(diff.Add(aimError))
+	isReady := now.Sub(b.enemyTime) > defaultWorldBotReactionTime
+	weapon := me.GetWeapon()
+	if weapon == nil {
+		b.setGoal(enemyPos, now)
+		input.Melee = isReady && diff.Len() < defaultWorldBotMeleeRange
+		return
+	}
+	if diff.Len() > defaultWorldBotKeepRange {
+		b.setGoal(enemyPos, now)
+	} else {
+		// Circle around the enemy while shooting
+		side := diff.Normal()
+		if b.isStrafing {
+			side = side.Scaled(-1)
+		}
+		b.setGoal(pos.Add(side.Unit().Scaled(defaultWorldBotCellSize*2)), now)
+	}
+	mag, ammo := weapon.GetAmmo()
+	input.Reload = mag == 0 && ammo > 0
+	input.Fire = isReady && mag > 0
+}
+
+// findItem walks to the nearest item which is used by touching it
+func (b *defaultWorldBot) findItem(me common.Player, now time.Time) bool {
+	pos := me.GetPos()
+	var itemPos pixel.Vec
+	itemDist := math.Inf(1)
+	for _, o := range b.world.objectDB.SelectAll() {
+		if o.GetType() != config.ItemObject || o.(common.Item).GetItemType() != config.InstanceUsedItem {
+			continue
+		}
+		shape := o.GetShape()
+		p := pixel.V(shape.Center().X, shape.Min.Y)
+		if dist := p.Sub(pos).Len(); dist < defaultWorldBotItemRange && dist < itemDist {
+			itemPos = p
+			itemDist = dist
+		}
+	}
+	if math.IsInf(itemDist, 1) {
+		return false
+	}
+	b.setGoal(itemPos, now)
+	return true
+}
+
+func (b *defaultWorldBot) wander(pos pixel.Vec, now time.Time) {
+	if !b.isWander || b.goalTime.IsZero() ||
+		b.goal.Sub(pos).Len() < defaultWorldBotCellSize ||
+		now.Sub(b.goalTime) > defaultWorldBotMaxWanderTime {
+		b.setGoal(b.world.getFreePos(), now)
+	}
+	b.isWander = true
+}
+
+func (b *defaultWorldBot) setGoal(goal pixel.Vec, now time.Time) {
+	b.isWander = false
+	if getCell(goal) == getCell(b.goal) && !b.goalTime.IsZero() {
+		b.goal = goal
+		return
+	}
+	b.goal = goal
+	b.goalTime = now
+	b.path = nil
+	b.planTime = time.Time{}
+}
+
+// getMoveDir returns the direction to the next waypoint, the path is
+// planned again from time to time and when the bot gets stuck.
+func (b *defaultWorldBot) getMoveDir(pos pixel.Vec, now time.Time) pixel.Vec {
+	if pos.Sub(b.lastPos).Len() > defaultWorldBotStuckDist {
+		b.lastPos = pos
+		b.moveTime = now
+	}
+	isStuck := now.Sub(b.moveTime) > defaultWorldBotStuckTime
+	if b.planTime.IsZero() || isStuck || now.Sub(b.planTime) > defaultWorldBotReplanPeriod {
+		b.path = b.world.findPath(pos, b.goal)
+		b.planTime = now
+		b.moveTime = now
+		if b.path == nil && b.isWander {
+			// Goal can't be reached, pick another one
+			b.goalTime = time.Time{}
+		}
+	}
+	for len(b.path) > 0 && b.path[0].Sub(pos).Len() < defaultWorldBotArriveDist {
+		b.path = b.path[1:]
+	}
+	if len(b.path) == 0 {
+		// Same cell as the goal
+		if b.goal.Sub(pos).Len() < defaultWorldBotArriveDist {
+			return pixel.ZV
+		}
+		return b.goal.Sub(pos)
+	}
+	return b.path[0].Sub(pos)
+}
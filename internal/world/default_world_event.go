@@ -0,0 +1,63 @@
+package world
+
+import (
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+)
+
+// Server
+
+// AddGameEvent logs an event of the current tick, it's sent to clients with
+// the next snapshots.
+func (w *defaultWorld) AddGameEvent(event *protocol.GameEvent) {
+	event.Tick = w.tick
+	w.eventLog.Add(event)
+}
+
+func (w *defaultWorld) GetGameEvents(afterSeq int64) (events []*protocol.GameEvent, lastSeq int64) {
+	return w.eventLog.GetAfter(afterSeq)
+}
+
+// Client
+
+// SetGameEvents queues events which weren't received before, they may come
+// again with later snapshots until the ack reaches the server.
+func (w *defaultWorld) SetGameEvents(events []*protocol.GameEvent) {
+	w.eventLock.Lock()
+	defer w.eventLock.Unlock()
+	for _, event := range events {
+		if event.Seq <= w.lastEventSeq {
+			continue
+		}
+		w.pendingEvents = append(w.pendingEvents, event)
+		w.lastEventSeq = event.Seq
+	}
+}
+
+// dispatchGameEvents hands events to the hud and their objects once the
+// objects are rendered at the event tick. Events of the main player are
+// dispatched right away as it's rendered at the server time.
+func (w *defaultWorld) dispatchGameEvents() {
+	lerpTick := w.clock.GetTick(w.clock.GetLerpTime())
+	w.eventLock.Lock()
+	dueEvents := []*protocol.GameEvent{}
+	pendingEvents := []*protocol.GameEvent{}
+	for _, event := range w.pendingEvents {
+		isMainPlayer := event.PlayerID == w.mainPlayerID || event.ObjectID == w.mainPlayerID
+		if event.Tick <= lerpTick || isMainPlayer {
+			dueEvents = append(dueEvents, event)
+		} else {
+			pendingEvents = append(pendingEvents, event)
+		}
+	}
+	w.pendingEvents = pendingEvents
+	w.eventLock.Unlock()
+	for _, event := range dueEvents {
+		w.hud.HandleGameEvent(event)
+		if o, exists := w.objectDB.SelectOne(event.ObjectID); exists {
+			if handler, ok := o.(common.GameEventHandler); ok {
+				handler.HandleGameEvent(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package world
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/common"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/config"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/entity/weapon"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/protocol"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/ticktime"
+	"github.com/mr-panta/2d-multiplayer-shooting-game/internal/util"
+)
+
+func newTestServerWorld(seed int64) common.World {
+	cfg := config.NewWorldConfig()
+	cfg.Seed = seed
+	clock := ticktime.NewClock()
+	clock.SetServerStartTime(time.Unix(1600000000, 0))
+	return NewDefaultWorld(nil, clock, "world-1", cfg)
+}
+
+// collectVecs appends every vec reachable from v
+func collectVecs(v reflect.Value, vecs []pixel.Vec) []pixel.Vec {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return vecs
+		}
+		if vec, ok := v.Interface().(*protocol.Vec); ok {
+			return append(vecs, vec.Convert())
+		}
+		return collectVecs(v.Elem(), vecs)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			vecs = collectVecs(v.Field(i), vecs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			vecs = collectVecs(v.Index(i), vecs)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			vecs = collectVecs(v.MapIndex(key), vecs)
+		}
+	}
+	return vecs
+}
+
+func TestFilterSnapshotHidesPlayerPos(t *testing.T) {
+	w := newTestServerWorld(1)
+	viewerID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(viewerID, "viewer")
+	hiderID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(hiderID, "hider")
+	// Outside the field and the range of the viewer, nothing else is there
+	hiderPos := pixel.V(-5000, -5000)
+	o, _ := w.GetObjectDB().SelectOne(viewerID)
+	o.(common.Player).SetPos(pixel.V(200, 200))
+	o, _ = w.GetObjectDB().SelectOne(hiderID)
+	hider := o.(common.Player)
+	hider.SetPos(hiderPos)
+	knifeID := hider.GetMeleeWeapon().GetID()
+
+	_, snapshot := w.GetSnapshot(false)
+	snapshot.ObjectSnapshots = append(snapshot.ObjectSnapshots,
+		&protocol.ObjectSnapshot{
+			ID:   "bullet",
+			Type: config.BulletObject,
+			Bullet: &protocol.BulletSnapshot{
+				PlayerID: hiderID,
+				WeaponID: knifeID,
+				InitPos:  util.ConvertVec(hiderPos),
+				Dir:      util.ConvertVec(pixel.V(1, 0)),
+			},
+		},
+		&protocol.ObjectSnapshot{
+			ID:   "skull",
+			Type: config.ItemObject,
+			Item: &protocol.ItemSnapshot{
+				Skull: &protocol.ItemSkullSnapshot{
+					Pos:      util.ConvertVec(hiderPos),
+					PlayerID: hiderID,
+				},
+			},
+		},
+	)
+	events := []*protocol.GameEvent{
+		{Seq: 1, Type: protocol.GameEventFired, ObjectID: knifeID, PlayerID: hiderID, Pos: util.ConvertVec(hiderPos)},
+		{Seq: 2, Type: protocol.GameEventHit, ObjectID: "bullet", PlayerID: hiderID, Pos: util.ConvertVec(hiderPos)},
+		{Seq: 3, Type: protocol.GameEventDamaged, ObjectID: viewerID, PlayerID: hiderID, Value: 10},
+		{Seq: 4, Type: protocol.GameEventKilled, ObjectID: hiderID, PlayerID: viewerID, Pos: util.ConvertVec(hiderPos)},
+	}
+
+	filtered := w.FilterSnapshot(viewerID, snapshot)
+	filteredEvents := protocol.FilterGameEvents(events, filtered)
+	vecs := collectVecs(reflect.ValueOf(filtered), nil)
+	vecs = collectVecs(reflect.ValueOf(filteredEvents), vecs)
+	for _, vec := range vecs {
+		if vec.Sub(hiderPos).Len() < defaultWorldAOIRange {
+			t.Fatalf("%v gives away the hidden player at %v", vec, hiderPos)
+		}
+	}
+	for _, ss := range filtered.ObjectSnapshots {
+		if ss.ID == knifeID || ss.ID == "bullet" {
+			t.Fatalf("object %s of the hidden player was sent", ss.ID)
+		}
+		// The scoreboard still lists the hidden player
+		if ss.ID == hiderID && (ss.Player.PlayerName != "hider" || ss.Player.WeaponID != "" || ss.Player.MeleeWeaponID != "") {
+			t.Fatalf("hidden player was sent as %+v", ss.Player)
+		}
+	}
+	for _, event := range filteredEvents {
+		if event.PlayerID == hiderID {
+			t.Fatalf("event %d names the hidden player", event.Seq)
+		}
+	}
+	// The viewer still learns it was damaged and who it killed
+	if len(filteredEvents) != 2 || filteredEvents[0].Seq != 3 || filteredEvents[1].Seq != 4 {
+		t.Fatalf("got events %+v", filteredEvents)
+	}
+	// Events are shared by every client
+	if events[0].Pos == nil || events[3].Pos == nil || events[2].PlayerID != hiderID {
+		t.Fatal("filtering changed the events")
+	}
+}
+
+func TestTreesHidePlayer(t *testing.T) {
+	w := newTestServerWorld(1)
+	viewerID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(viewerID, "viewer")
+	hiderID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(hiderID, "hider")
+	// Players come alive after their first tick
+	w.ServerUpdate(1)
+	// Far from the trees of the world, inside the scope of the viewer
+	o, _ := w.GetObjectDB().SelectOne(viewerID)
+	viewer := o.(common.Player)
+	viewer.SetPos(pixel.V(-5000, -5000))
+	o, _ = w.GetObjectDB().SelectOne(hiderID)
+	o.(common.Player).SetPos(pixel.V(-4950, -5000))
+	shape := o.GetShape()
+	// tree makes the shape of a tree in front of the hider, from x0 to x1 of
+	// its shape
+	tree := func(x0, x1 float64) pixel.Rect {
+		return pixel.R(
+			shape.Min.X+x0*shape.W(), shape.Min.Y-10,
+			shape.Min.X+x1*shape.W(), shape.Max.Y+10,
+		)
+	}
+	cases := []struct {
+		name       string
+		treeShapes []pixel.Rect
+		visible    bool
+	}{
+		{"no tree", nil, true},
+		{"one tree", []pixel.Rect{tree(-0.5, 1.5)}, false},
+		{"tree behind", []pixel.Rect{tree(-0.5, 1.5).Moved(pixel.V(0, 20))}, true},
+		{"tree partly in front", []pixel.Rect{tree(-0.5, 0.5)}, true},
+		{"two overlapping trees", []pixel.Rect{tree(-0.5, 0.6), tree(0.4, 1.5)}, false},
+		{"two trees with a gap", []pixel.Rect{tree(-0.5, 0.4), tree(0.6, 1.5)}, true},
+		{"one of two trees behind", []pixel.Rect{tree(-0.5, 0.6), tree(0.4, 1.5).Moved(pixel.V(0, 20))}, true},
+	}
+	for _, c := range cases {
+		if visible := w.(*defaultWorld).isVisibleTo(viewer, hiderID, c.treeShapes); visible != c.visible {
+			t.Fatalf("%s: visible is %v, want %v", c.name, visible, c.visible)
+		}
+	}
+}
+
+// testTargetPosAt moves the target right at a constant speed, away from the
+// field so nothing else is hit.
+func testTargetPosAt(t time.Duration) pixel.Vec {
+	return pixel.V(-5000+800*t.Seconds(), -5000)
+}
+
+// fireAtRewoundTarget records a moving target for some ticks, then fires a
+// bullet at height above where the target was rewind ago by a shooter who
+// sees the world viewDelay late. It returns whether the bullet hit.
+func fireAtRewoundTarget(viewDelay, rewind time.Duration, height float64) bool {
+	const fireTick = 100
+	w := newTestServerWorld(1)
+	shooterID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(shooterID, "shooter")
+	targetID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(targetID, "target")
+	o, _ := w.GetObjectDB().SelectOne(shooterID)
+	shooter := o.(common.Player)
+	o, _ = w.GetObjectDB().SelectOne(targetID)
+	target := o.(common.Player)
+	update := func(tick int64) {
+		target.SetPos(testTargetPosAt(time.Duration(tick) * config.Timestep))
+		shooter.SetPos(pixel.V(5000, 5000))
+		w.ServerUpdate(tick)
+	}
+	for tick := int64(0); tick <= fireTick; tick++ {
+		update(tick)
+	}
+	now := w.GetClock().GetTickTime(fireTick)
+	shooter.SetInput(&protocol.InputSnapshot{
+		CursorDir: util.V(1, 0),
+		ViewTime:  now.Add(-viewDelay).UnixNano(),
+	})
+	// The bullet barely moves, it stays where it is fired
+	fireTime := fireTick*config.Timestep - rewind
+	bullet := weapon.NewBullet(w, w.GetObjectDB().GetAvailableID())
+	bullet.Fire(shooterID, "", testTargetPosAt(fireTime).Add(pixel.V(0, height)), pixel.V(1, 0), 1, 100, 0, 0)
+	w.GetObjectDB().Set(bullet)
+	update(fireTick + 1)
+	events, _ := w.GetGameEvents(0)
+	for _, event := range events {
+		if event.Type == protocol.GameEventHit && event.ObjectID == bullet.GetID() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBulletHitsRewoundTarget(t *testing.T) {
+	cases := []struct {
+		name      string
+		viewDelay time.Duration
+		rewind    time.Duration
+		height    float64
+		hit       bool
+	}{
+		{"seen target", 100 * time.Millisecond, 100 * time.Millisecond, 64, true},
+		{"target now", 100 * time.Millisecond, 0, 64, false},
+		{"collider of target now", 100 * time.Millisecond, 0, 20, false},
+		{"no view delay", 0, 100 * time.Millisecond, 64, false},
+		{"no view delay at target now", 0, 0, 64, true},
+		{"clamped view delay", time.Second, config.MaxRewindPeriod, 64, true},
+		{"older than max rewind", 300 * time.Millisecond, 300 * time.Millisecond, 64, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if hit := fireAtRewoundTarget(c.viewDelay, c.rewind, c.height); hit != c.hit {
+				t.Fatalf("hit is %v, want %v", hit, c.hit)
+			}
+		})
+	}
+}
+
+// playTestGame feeds a player the same inputs every run and returns the
+// encoded snapshots, pause stalls the ticks so the wall clock moves on.
+func playTestGame(t *testing.T, seed int64, pause bool) [][]byte {
+	cfg := config.NewWorldConfig()
+	cfg.Seed = seed
+	// Bots and items join in
+	cfg.MinPlayers = 4
+	cfg.MinNextItemPeriod = config.Duration{Duration: 100 * time.Millisecond}
+	cfg.MaxNextItemPeriod = config.Duration{Duration: 300 * time.Millisecond}
+	clock := ticktime.NewClock()
+	clock.SetServerStartTime(time.Unix(1600000000, 0))
+	rng := util.NewRand(seed)
+	w := NewDefaultWorld(nil, clock, util.GenerateIDFrom(rng), cfg)
+	playerID := w.GetObjectDB().GetAvailableID()
+	w.SpawnPlayer(playerID, "player")
+	codec := protocol.GetCodec(protocol.CodecBinary)
+	encoded := [][]byte{}
+	for tick := int64(0); tick < 600; tick++ {
+		if tick%3 == 0 {
+			w.SetInputSnapshot(playerID, tick, &protocol.InputSnapshot{
+				Right:     true,
+				Up:        tick%60 < 30,
+				Fire:      tick%10 == 0,
+				CursorDir: util.V(1, 0.5),
+				Duration:  int64(3 * config.Timestep),
+				ViewTime:  clock.GetTickTime(tick).UnixNano(),
+				Time:      clock.GetTickTime(tick).UnixNano(),
+			})
+		}
+		if pause && tick%50 == 0 {
+			time.Sleep(3 * time.Millisecond)
+		}
+		w.ServerUpdate(tick)
+		if tick%20 == 0 {
+			_, snapshot := w.GetSnapshot(true)
+			data, err := codec.Marshal(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded = append(encoded, data)
+		}
+	}
+	return encoded
+}
+
+func TestSameSeedPlaysSameGame(t *testing.T) {
+	a := playTestGame(t, 42, false)
+	b := playTestGame(t, 42, true)
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			t.Fatalf("snapshot %d differs", i)
+		}
+	}
+	c := playTestGame(t, 43, false)
+	if bytes.Equal(a[len(a)-1], c[len(c)-1]) {
+		t.Fatal("another seed played the same game")
+	}
+}
+
+// countTestPlayers counts the humans and the bots of a snapshot
+func countTestPlayers(snapshot *protocol.WorldSnapshot) (humanCount, botCount int) {
+	for _, ss := range snapshot.ObjectSnapshots {
+		if ss.Player == nil {
+			continue
+		}
+		if ss.Player.IsBot {
+			botCount++
+		} else {
+			humanCount++
+		}
+	}
+	return humanCount, botCount
+}
+
+func TestBotsFillMinPlayers(t *testing.T) {
+	const minPlayers = 3
+	cfg := config.NewWorldConfig()
+	cfg.Seed = 1
+	cfg.MinPlayers = minPlayers
+	clock := ticktime.NewClock()
+	clock.SetServerStartTime(time.Unix(1600000000, 0))
+	w := NewDefaultWorld(nil, clock, "world-1", cfg)
+	tick := int64(0)
+	// Bots are balanced once per period
+	update := func() {
+		end := tick + int64(defaultWorldBotBalancePeriod/config.Timestep) + 1
+		for ; tick <= end; tick++ {
+			w.ServerUpdate(tick)
+		}
+	}
+	humanIDs := []string{}
+	cases := []struct {
+		name       string
+		humanCount int
+		botCount   int
+	}{
+		{"empty world", 0, minPlayers},
+		{"humans join", 2, 1},
+		{"world is full", minPlayers, 0},
+		{"more humans than min players", 5, 0},
+		{"humans leave", 1, 2},
+	}
+	for _, c := range cases {
+		for len(humanIDs) < c.humanCount {
+			playerID := w.GetObjectDB().GetAvailableID()
+			w.SpawnPlayer(playerID, "human")
+			humanIDs = append(humanIDs, playerID)
+		}
+		for len(humanIDs) > c.humanCount {
+			w.GetObjectDB().Delete(humanIDs[0])
+			humanIDs = humanIDs[1:]
+		}
+		update()
+		_, snapshot := w.GetSnapshot(true)
+		if humanCount, botCount := countTestPlayers(snapshot); humanCount != c.humanCount || botCount != c.botCount {
+			t.Fatalf("%s: got %d humans and %d bots, want %d and %d",
+				c.name, humanCount, botCount, c.humanCount, c.botCount)
+		}
+	}
+}